@@ -1,16 +1,44 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"nwc_app/batches"
+	"nwc_app/bolt11"
+	"nwc_app/budget"
 	_ "nwc_app/docs"
+	"nwc_app/events"
+	"nwc_app/invoices"
+	"nwc_app/ledger"
+	"nwc_app/lnurl"
 	"nwc_app/middleware"
+	"nwc_app/monitor"
+	"nwc_app/nip47"
+	"nwc_app/notifications"
+	"nwc_app/payrequests"
+	"nwc_app/qr"
+	"nwc_app/rebalance"
+	"nwc_app/retry"
+	"nwc_app/schedules"
+	"nwc_app/store"
 	"nwc_app/wallet"
+	"nwc_app/webhooks"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -39,11 +67,86 @@ import (
 
 var walletURIs map[string]string
 
+// walletConfigs holds per wallet settings such as spending policies
+var walletConfigs map[string]wallet.Config
+
+// defaultFeePolicy is the fee ceiling for wallets that do not configure their own
+var defaultFeePolicy wallet.FeePolicy
+
+// retryPolicy decides how payments that failed for transient reasons are retried
+var retryPolicy retry.Policy
+
+// paymentLedger records every payment made through the API
+var paymentLedger *ledger.Ledger
+
+// invoiceStore tracks invoices created through the API until they are paid or expire
+var invoiceStore *invoices.Store
+
+// invoiceWatcher polls open invoices and announces when they are paid
+var invoiceWatcher *invoices.Watcher
+
+// eventBus distributes events such as paid invoices
+var eventBus *events.Bus
+
+// eventLog keeps recent events for clients resuming an event stream
+var eventLog *events.Log
+
+// apiRouter serves the API, including requests made over WebSocket connections
+var apiRouter *gin.Engine
+
+// lnurlClient fetches invoices from Lightning Address and LNURL services
+var lnurlClient *lnurl.Client
+
+// lightningAddresses maps the names served at /.well-known/lnurlp/{name} to wallet IDs
+var lightningAddresses map[string]string
+
+// publicURL is the configured public base URL of this server, if any
+var publicURL string
+
+// scheduleStore keeps recurring payments and the history of their runs
+var scheduleStore *schedules.Store
+
+// batchStore tracks batch payments and the outcome of each of their payments
+var batchStore *batches.Store
+
+// Store of hosted payment requests and how long the invoice quoted for one is valid
+var paymentRequests *payrequests.Store
+var paymentQuoteTTL time.Duration
+
+// Rebalancer keeping wallets within their configured balance range
+var rebalancer *rebalance.Rebalancer
+
+// Registered webhooks and the dispatcher delivering events to them
+var webhookStore *webhooks.Store
+var webhookDispatcher *webhooks.Dispatcher
+
+// minCustomTLVType is the lowest TLV record type applications may use (BOLT #1)
+const minCustomTLVType = 1 << 16
+
+// maxInvoiceExpiry is the longest expiry in seconds an invoice can be created with
+const maxInvoiceExpiry = 7 * 24 * 60 * 60
+
+// minScheduleInterval is the shortest interval a recurring payment may use
+const minScheduleInterval = time.Minute
+
+// Limits on batch payments
+const (
+	maxBatchItems           = 500
+	maxBatchCSVSize         = 1 << 20
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 16
+	maxSplitShares          = 20
+)
+
 // NwcPaymentRequest represents the data needed to make an NWC payment
 type NwcPaymentRequest struct {
-	Sender     string  `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	Sender string `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	// Recipient is a configured wallet ID or a Lightning Address (name@domain)
 	Recipient  string  `json:"recipient" binding:"required" example:"WALLET_VRATA_KRKE"`
 	EuroAmount float64 `json:"euro_amount" binding:"required" example:"0.000001"`
+	// Optional fee limits, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
 }
 
 // NwcPaymentResponse is the structure returned after making a payment
@@ -54,12 +157,111 @@ type NwcPaymentResponse struct {
 	AmountMsats      int     `json:"amount_msats"`
 	SenderBalance    int64   `json:"sender_balance"`
 	RecipientBalance int64   `json:"recipient_balance"`
-	FeesPaid         int64   `json:"fees_paid,omitempty"`
+	FeesPaid         int64   `json:"fees_paid"`
+	MaxFeeMsats      *int64  `json:"max_fee_msats,omitempty"`
+}
+
+// KeysendPaymentRequest is the variant of NwcPaymentRequest paying a node pubkey
+// directly instead of a wallet or Lightning Address
+type KeysendPaymentRequest struct {
+	Sender     string  `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	Pubkey     string  `json:"pubkey" binding:"required" example:"03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"`
+	EuroAmount float64 `json:"euro_amount" binding:"required" example:"0.000001"`
+	// Optional custom records with types of at least 65536 and hex encoded values
+	TLVRecords []nip47.TLVRecord `json:"tlv_records,omitempty"`
+	// Optional fee limits, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
+}
+
+// KeysendPaymentResponse is the structure returned after a keysend payment
+type KeysendPaymentResponse struct {
+	Success     bool    `json:"success"`
+	Message     string  `json:"message"`
+	EuroAmount  float64 `json:"euro_amount"`
+	AmountMsats int     `json:"amount_msats"`
+	PaymentHash string  `json:"payment_hash"`
+	Preimage    string  `json:"preimage"`
+	FeesPaid    int64   `json:"fees_paid"`
+	MaxFeeMsats *int64  `json:"max_fee_msats,omitempty"`
+}
+
+// BatchPaymentItem is one payment of a batch
+type BatchPaymentItem struct {
+	// Recipient is a configured wallet ID or a Lightning Address (name@domain)
+	Recipient  string  `json:"recipient" binding:"required" example:"WALLET_VRATA_KRKE"`
+	EuroAmount float64 `json:"euro_amount" binding:"required" example:"0.000001"`
+}
+
+// BatchPaymentRequest pays several recipients from one wallet
+type BatchPaymentRequest struct {
+	Sender string             `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	Items  []BatchPaymentItem `json:"items" binding:"required,dive"`
+	// Optional fee limits per payment, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
+	// Concurrency is how many payments are made at once, 4 by default
+	Concurrency int `json:"concurrency,omitempty" example:"4"`
+	// MultiPay pays all invoices with a single NIP-47 multi_pay_invoice request
+	MultiPay bool `json:"multi_pay,omitempty" example:"false"`
+}
+
+// SplitShare is one recipient's part of a split payment: a percentage or a fixed EUR amount
+type SplitShare struct {
+	// Recipient is a configured wallet ID or a Lightning Address (name@domain)
+	Recipient  string   `json:"recipient" binding:"required" example:"WALLET_VENUE"`
+	Percent    *float64 `json:"percent,omitempty" example:"90"`
+	EuroAmount *float64 `json:"euro_amount,omitempty" example:"0.5"`
+}
+
+// SplitPaymentRequest divides one payment across several recipients
+type SplitPaymentRequest struct {
+	Sender string `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	// EuroAmount is the total, which may be left out when every share is a fixed amount
+	EuroAmount float64      `json:"euro_amount,omitempty" example:"10"`
+	Shares     []SplitShare `json:"shares" binding:"required,dive"`
+	// Optional fee limits per share, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
+	// MultiPay pays all shares with a single NIP-47 multi_pay_invoice request
+	MultiPay bool `json:"multi_pay,omitempty" example:"false"`
+}
+
+// CreateScheduleRequest describes a recurring payment
+type CreateScheduleRequest struct {
+	Sender string `json:"sender" binding:"required" example:"WALLET_JOSIP"`
+	// Recipient is a configured wallet ID or a Lightning Address (name@domain)
+	Recipient string  `json:"recipient" binding:"required" example:"WALLET_VRATA_KRKE"`
+	Amount    float64 `json:"amount" binding:"required" example:"9.99"`
+	// Currency of the amount: EUR (default), SAT or MSAT
+	Currency string `json:"currency,omitempty" example:"EUR"`
+	// Either a five field cron expression in UTC or a Go duration such as 24h
+	Cron     string `json:"cron,omitempty" example:"0 9 1 * *"`
+	Interval string `json:"interval,omitempty" example:"720h"`
+	// StartsAt delays the first run; an interval schedule first runs at StartsAt
+	StartsAt *time.Time `json:"starts_at,omitempty" example:"2025-01-01T09:00:00Z"`
+	EndsAt   *time.Time `json:"ends_at,omitempty" example:"2025-12-31T23:59:59Z"`
+	// MissedRuns is skip (default) or run_once for runs that fell due while the service was down
+	MissedRuns string `json:"missed_runs,omitempty" example:"skip"`
+	// Optional fee limits, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
+}
+
+// ScheduleResponse is a schedule with the history of its runs
+type ScheduleResponse struct {
+	schedules.Schedule
+	Runs []schedules.Run `json:"runs"`
 }
 
 // ErrorResponse represents an error response
+// Code is one of the Code constants; Error repeats Message for clients written before codes existed
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string         `json:"error" example:"sender wallet 'WALLET_JOSIP' not found"`
+	Code      string         `json:"code" example:"wallet_not_found"`
+	Message   string         `json:"message" example:"sender wallet 'WALLET_JOSIP' not found"`
+	Details   map[string]any `json:"details,omitempty"`
+	Retriable bool           `json:"retriable" example:"false"`
 }
 
 // ConversionResponse represents a currency conversion response
@@ -68,6 +270,109 @@ type ConversionResponse struct {
 	MsatAmount int     `json:"msat_amount"`
 }
 
+// PayInvoiceRequest represents an external BOLT11 invoice to pay
+type PayInvoiceRequest struct {
+	Invoice string `json:"invoice" binding:"required" example:"lnbc10u1p..."`
+	// Optional checks against the decoded invoice
+	AmountMsats int64  `json:"amount_msats,omitempty" example:"1000000"`
+	Description string `json:"description,omitempty" example:"Order 1234"`
+	// Optional fee limits, which can only lower the configured ceiling
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty" example:"1000"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty" example:"0.5"`
+}
+
+// PayInvoiceResponse is the structure returned after paying an external invoice
+type PayInvoiceResponse struct {
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	PaymentHash string `json:"payment_hash"`
+	Preimage    string `json:"preimage"`
+	Payee       string `json:"payee"`
+	AmountMsats int64  `json:"amount_msats"`
+	FeesPaid    int64  `json:"fees_paid"`
+	MaxFeeMsats *int64 `json:"max_fee_msats,omitempty"`
+}
+
+// CreateInvoiceRequest represents an invoice to create on a configured wallet
+// Exactly one of AmountMsats and EuroAmount must be set
+type CreateInvoiceRequest struct {
+	AmountMsats   int64   `json:"amount_msats,omitempty" example:"1000000"`
+	EuroAmount    float64 `json:"euro_amount,omitempty" example:"0.5"`
+	Description   string  `json:"description" example:"Order 1234"`
+	ExpirySeconds int64   `json:"expiry_seconds,omitempty" example:"3600"`
+}
+
+// InvoiceResponse describes an invoice created on a configured wallet
+type InvoiceResponse struct {
+	Wallet      string    `json:"wallet"`
+	Invoice     string    `json:"invoice"`
+	PaymentHash string    `json:"payment_hash"`
+	AmountMsats int64     `json:"amount_msats"`
+	EuroAmount  float64   `json:"euro_amount,omitempty"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CreatePaymentRequestRequest asks for a hosted payment page collecting a Euro amount into a wallet
+type CreatePaymentRequestRequest struct {
+	Recipient   string  `json:"recipient" binding:"required" example:"WALLET_VRATA_KRKE"`
+	EuroAmount  float64 `json:"euro_amount" binding:"required" example:"15"`
+	Description string  `json:"description,omitempty" example:"Conference ticket"`
+	// ExpiresAt optionally ends the request; it can no longer be paid afterwards
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-06-30T18:00:00Z"`
+}
+
+// PaymentRequestResponse is a payment request with the link of its payment page
+type PaymentRequestResponse struct {
+	payrequests.Request
+	URL string `json:"url" example:"https://pay.example.com/pay/4f1c..."`
+}
+
+// CreateWebhookRequest registers an endpoint to receive events.
+// Events may be "*" to receive every event type.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required" example:"https://example.com/hooks/nwc"`
+	Events      []string `json:"events" binding:"required" example:"payment.succeeded,invoice.paid"`
+	Wallets     []string `json:"wallets,omitempty" example:"WALLET_VRATA_KRKE"`
+	Description string   `json:"description,omitempty" example:"Accounting"`
+}
+
+// DecodeRequest holds a BOLT11 invoice to decode
+type DecodeRequest struct {
+	Invoice string `json:"invoice" binding:"required" example:"lnbc10u1p..."`
+}
+
+// DecodeResponse describes a decoded BOLT11 invoice
+type DecodeResponse struct {
+	bolt11.Invoice
+	// EuroAmount is omitted when the invoice has no amount or no exchange rate is available
+	EuroAmount *float64 `json:"euro_amount,omitempty"`
+	Expired    bool     `json:"expired"`
+}
+
+// LNURLWithdrawRequest holds an LNURL-withdraw link to redeem into a wallet
+type LNURLWithdrawRequest struct {
+	LNURL string `json:"lnurl" binding:"required" example:"LNURL1DP68GURN8GHJ7..."`
+	// Optional amount, the most the service allows by default
+	AmountMsats int64 `json:"amount_msats,omitempty" example:"1000000"`
+}
+
+// LNURLWithdrawResponse describes a withdrawal handed to an LNURL-withdraw service
+type LNURLWithdrawResponse struct {
+	Invoice         invoices.Invoice `json:"invoice"`
+	MinWithdrawable int64            `json:"min_withdrawable_msats"`
+	MaxWithdrawable int64            `json:"max_withdrawable_msats"`
+}
+
+// BudgetResponse reports a wallet's spending policy usage
+type BudgetResponse struct {
+	Wallet          string         `json:"wallet"`
+	MaxPaymentMsats int64          `json:"max_payment_msats,omitempty"`
+	MaxPaymentEur   float64        `json:"max_payment_eur,omitempty"`
+	Windows         []budget.Usage `json:"windows"`
+}
+
 // HealthResponse represents a health check response
 type HealthResponse struct {
 	Status string            `json:"status"`
@@ -77,150 +382,201 @@ type HealthResponse struct {
 
 
 // @Summary      Make an NWC payment
-// @Description  Transfer funds from one wallet to another using EUR amount
+// @Description  Transfer funds from one wallet to another wallet or a Lightning Address using EUR amount
 // @Tags         payments
 // @Accept       json
 // @Produce      json
-// @Param        api_key   query   string  true  "API Key for authentication"
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
 // @Param        payment   body    NwcPaymentRequest  true  "Payment Information"
 // @Success      200      {object}  NwcPaymentResponse
 // @Failure      400      {object}  ErrorResponse
 // @Failure      401      {object}  ErrorResponse
+// @Failure      402      {object}  ErrorResponse  "Insufficient balance"
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Wallet not found"
+// @Failure      409      {object}  ErrorResponse  "Invoice already paid"
+// @Failure      422      {object}  ErrorResponse  "Validation failed, spending policy violation or unusable Lightning Address"
+// @Failure      429      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
+// @Failure      502      {object}  ErrorResponse  "Wallet or exchange rate service failed"
+// @Failure      504      {object}  ErrorResponse  "Wallet did not reply in time"
 // @Router       /nwc_payment [post]
 func nwcPaymentHandler(c *gin.Context) {
-	// Validate API key
-	requestAPIKey := c.Query("api_key")
-	if requestAPIKey == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error: "API key is required. Please provide it in the api_key query parameter",
-		})
-		return
-	}
-
-	// Get the API key from environment variable
-	apiKey := os.Getenv("NWC_API_KEY")
-
-	// Check if API key is valid
-	if requestAPIKey != apiKey {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error: "Invalid API key",
-		})
-		return
-	}
-	
 	// Process the request
 	var req NwcPaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: fmt.Sprintf("invalid request: %v", err),
-		})
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		respondError(c, validationFailed("fee limits must not be negative"))
 		return
 	}
 
 	// Convert Euro to msats
 	msatAmount, err := euroToMsats(req.EuroAmount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: fmt.Sprintf("failed to convert EUR to msats: %v", err),
-		})
+		respondError(c, fmt.Errorf("failed to convert EUR to msats: %w", err))
 		return
 	}
 	log.Printf("Converted %f EUR to %d msats", req.EuroAmount, msatAmount)
 
 	if msatAmount <= 0 {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "converted amount must be greater than 0",
-		})
+		respondError(c, validationFailed("converted amount must be greater than 0"))
 		return
 	}
 
-	// Make the payment
-	err = makePayment(walletURIs, req.Sender, req.Recipient, msatAmount)
+	// Make the payment, to a Lightning Address or another configured wallet
+	feeLimit := wallet.FeePolicy{
+		MaxFeeMsats:   req.MaxFeeMsats,
+		MaxFeePercent: req.MaxFeePercent,
+	}
+	isAddress := lnurl.IsAddress(req.Recipient)
+	
+	var result *PaymentResult
+	if isAddress {
+		result, err = payLightningAddress(walletURIs, req.Sender, req.Recipient, msatAmount, req.EuroAmount, feeLimit)
+	} else {
+		result, err = makePayment(walletURIs, req.Sender, req.Recipient, msatAmount, req.EuroAmount, feeLimit)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: err.Error(),
-		})
+		respondError(c, err)
 		return
 	}
 
-	// Get updated balances
-	senderClient, _ := nwc.NewClient(walletURIs[req.Sender])
-	recipientClient, _ := nwc.NewClient(walletURIs[req.Recipient])
-	
-	senderBalance, _ := senderClient.GetBalance()
-	recipientBalance, _ := recipientClient.GetBalance()
+	// Get updated balances; a Lightning Address recipient's balance is unknown, and a
+	// wallet failing to report one must not turn the payment it made into an error
+	var senderBalance, recipientBalance int64
+	if balance, err := walletBalance(c.Request.Context(), req.Sender); err == nil {
+		senderBalance = balance
+	}
+	if !isAddress {
+		if balance, err := walletBalance(c.Request.Context(), req.Recipient); err == nil {
+			recipientBalance = balance
+		}
+	}
 
 	c.JSON(http.StatusOK, NwcPaymentResponse{
 		Success:          true,
 		Message:          fmt.Sprintf("Successfully transferred %d msats (%.8f EUR) from %s to %s", msatAmount, req.EuroAmount, req.Sender, req.Recipient),
 		EuroAmount:       req.EuroAmount,
 		AmountMsats:      msatAmount,
-		SenderBalance:    senderBalance.Balance,
-		RecipientBalance: recipientBalance.Balance,
+		SenderBalance:    senderBalance,
+		RecipientBalance: recipientBalance,
+		FeesPaid:         result.FeesPaid,
+		MaxFeeMsats:      result.MaxFeeMsats,
 	})
 }
 
-// @Summary      Convert EUR to millisatoshis
-// @Description  Converts a Euro amount to millisatoshis using current exchange rate
-// @Tags         conversion
+// @Summary      Make a keysend payment
+// @Description  Push a EUR amount from a wallet to a Lightning node pubkey without an invoice, optionally with TLV records
+// @Tags         payments
+// @Accept       json
 // @Produce      json
-// @Param        amount    query  number  true  "Amount in EUR"
-// @Param        api_key   query  string  true  "API Key for authentication"
-// @Success      200  {object}  ConversionResponse
-// @Failure      400  {object}  ErrorResponse
-// @Failure      401  {object}  ErrorResponse
-// @Failure      500  {object}  ErrorResponse
-// @Router       /convert/eur-to-msats [get]
-func euroToMsatsHandler(c *gin.Context) {
-	// Validate API key
-	requestAPIKey := c.Query("api_key")
-	if requestAPIKey == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error: "API key is required. Please provide it in the api_key query parameter",
-		})
+// @Param        api_key   query   string                 false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        payment   body    KeysendPaymentRequest  true   "Keysend payment"
+// @Success      200      {object}  KeysendPaymentResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      402      {object}  ErrorResponse  "Insufficient balance"
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Wallet not found"
+// @Failure      422      {object}  ErrorResponse  "Validation failed or spending policy violation"
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      502      {object}  ErrorResponse  "Wallet or exchange rate service failed"
+// @Failure      504      {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /keysend [post]
+func keysendPaymentHandler(c *gin.Context) {
+	var req KeysendPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		respondError(c, validationFailed("fee limits must not be negative"))
+		return
+	}
+
+	pubkey := strings.ToLower(req.Pubkey)
+	if key, err := hex.DecodeString(pubkey); err != nil || len(key) != 33 || (key[0] != 2 && key[0] != 3) {
+		respondError(c, validationFailed("pubkey must be a hex encoded 33 byte compressed node public key"))
 		return
 	}
+	for _, record := range req.TLVRecords {
+		if record.Type < minCustomTLVType {
+			respondError(c, validationFailed("TLV record type %d is reserved, custom records start at %d", record.Type, minCustomTLVType))
+			return
+		}
+		if _, err := hex.DecodeString(record.Value); err != nil {
+			respondError(c, validationFailed("TLV record %d value must be hex encoded", record.Type))
+			return
+		}
+	}
 
-	// Get the API key from environment variable
-	apiKey, err := wallet.LoadAPIKey()
+	msatAmount, err := euroToMsats(req.EuroAmount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: "Failed to load API key",
-		})
+		respondError(c, fmt.Errorf("failed to convert EUR to msats: %w", err))
+		return
+	}
+	if msatAmount <= 0 {
+		respondError(c, validationFailed("converted amount must be greater than 0"))
 		return
 	}
 
-	// Check if API key is valid
-	if requestAPIKey != apiKey {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error: "Invalid API key",
-		})
+	result, err := payKeysend(walletURIs, req.Sender, pubkey, msatAmount, req.EuroAmount, req.TLVRecords, wallet.FeePolicy{
+		MaxFeeMsats:   req.MaxFeeMsats,
+		MaxFeePercent: req.MaxFeePercent,
+	})
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, KeysendPaymentResponse{
+		Success:     true,
+		Message:     fmt.Sprintf("Successfully sent %d msats (%.8f EUR) from %s to %s", msatAmount, req.EuroAmount, req.Sender, pubkey),
+		EuroAmount:  req.EuroAmount,
+		AmountMsats: msatAmount,
+		PaymentHash: result.PaymentHash,
+		Preimage:    result.Preimage,
+		FeesPaid:    result.FeesPaid,
+		MaxFeeMsats: result.MaxFeeMsats,
+	})
+}
+
+// @Summary      Convert EUR to millisatoshis
+// @Description  Converts a Euro amount to millisatoshis using current exchange rate
+// @Tags         conversion
+// @Produce      json
+// @Param        amount    query  number  true  "Amount in EUR"
+// @Param        api_key   query  string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200  {object}  ConversionResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Exchange rate unavailable"
+// @Router       /convert/eur-to-msats [get]
+func euroToMsatsHandler(c *gin.Context) {
 	// Process the request
 	amountStr := c.Query("amount")
 	if amountStr == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "amount parameter is required",
-		})
+		respondError(c, invalidRequest("amount parameter is required"))
 		return
 	}
 
 	euroAmount, err := strconv.ParseFloat(amountStr, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "invalid amount format",
-		})
+		respondError(c, invalidRequest("invalid amount format"))
 		return
 	}
 
 	msatAmount, err := euroToMsats(euroAmount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error: fmt.Sprintf("conversion failed: %v", err),
-		})
+		respondError(c, fmt.Errorf("conversion failed: %w", err))
 		return
 	}
 
@@ -230,6 +586,51 @@ func euroToMsatsHandler(c *gin.Context) {
 	})
 }
 
+// @Summary      Decode a BOLT11 invoice
+// @Description  Decodes and verifies a BOLT11 invoice and values its amount in EUR, e.g. to confirm a payment with the user before paying it
+// @Tags         conversion
+// @Accept       json
+// @Produce      json
+// @Param        api_key   query  string         false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        invoice   body   DecodeRequest  true   "Invoice to decode"
+// @Success      200  {object}  DecodeResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Router       /decode [post]
+func decodeHandler(c *gin.Context) {
+	var req DecodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	invoice, err := bolt11.Decode(req.Invoice)
+	if err != nil {
+		respondError(c, &InvoiceError{Reason: fmt.Sprintf("invalid invoice: %v", err)})
+		return
+	}
+
+	response := DecodeResponse{
+		Invoice: *invoice,
+		Expired: invoice.Expired(time.Now()),
+	}
+
+	// The decoded invoice is still useful without its fiat value
+	if invoice.AmountMsats > 0 {
+		euroAmount, err := msatsToEuro(invoice.AmountMsats)
+		if err != nil {
+			log.Printf("Could not value invoice in EUR: %v", err)
+		} else {
+			response.EuroAmount = &euroAmount
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // @Summary      Check health of wallet
 // @Description  Verifies connectivity to a specified wallet or all wallets if none specified
 // @Tags         health
@@ -249,9 +650,7 @@ func healthCheckHandler(c *gin.Context) {
 		// Check only the specified wallet
 		walletURI, exists := walletURIs[walletID]
 		if !exists || walletID == "" {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error: fmt.Sprintf("Wallet with ID '%s' not found", walletID),
-			})
+			respondError(c, &WalletNotFoundError{Wallet: walletID})
 			return
 		}
 		
@@ -281,61 +680,2327 @@ func healthCheckHandler(c *gin.Context) {
 	})
 }
 
-// InitializeAPI sets up the Gin router with all routes and middleware
-func InitializeAPI() (*gin.Engine, error) {
-	// Load wallet URIs using our wallet package
-	var err error
-	walletURIs, err = wallet.LoadWalletURIs()
+// @Summary      Pay a BOLT11 invoice
+// @Description  Pays an external Lightning invoice from a configured wallet after validating its network, expiry, amount and description
+// @Tags         payments
+// @Accept       json
+// @Produce      json
+// @Param        id        path   string             true   "Sender wallet ID"
+// @Param        api_key   query  string             false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        invoice   body   PayInvoiceRequest  true   "Invoice to pay"
+// @Success      200  {object}  PayInvoiceResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      402  {object}  ErrorResponse  "Insufficient balance"
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "Invoice already paid"
+// @Failure      422  {object}  ErrorResponse  "Invalid invoice or spending policy violation"
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/pay-invoice [post]
+func payInvoiceHandler(c *gin.Context) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return
+	}
+
+	var req PayInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		respondError(c, validationFailed("fee limits must not be negative"))
+		return
+	}
+
+	result, invoice, err := payInvoice(walletURIs, walletID, req.Invoice, req.AmountMsats, req.Description, wallet.FeePolicy{
+		MaxFeeMsats:   req.MaxFeeMsats,
+		MaxFeePercent: req.MaxFeePercent,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to load wallet URIs: %w", err)
+		respondError(c, err)
+		return
 	}
 
-	// Set Gin to release mode in production
-	if os.Getenv("GIN_MODE") != "debug" {
-		gin.SetMode(gin.ReleaseMode)
+	c.JSON(http.StatusOK, PayInvoiceResponse{
+		Success:     true,
+		Message:     fmt.Sprintf("Successfully paid %d msats from %s to %s", invoice.AmountMsats, walletID, invoice.Payee),
+		PaymentHash: invoice.PaymentHash,
+		Preimage:    result.Preimage,
+		Payee:       invoice.Payee,
+		AmountMsats: invoice.AmountMsats,
+		FeesPaid:    result.FeesPaid,
+		MaxFeeMsats: result.MaxFeeMsats,
+	})
+}
+
+// @Summary      Create an invoice
+// @Description  Creates a receive invoice on a configured wallet for a msat or EUR amount
+// @Tags         invoices
+// @Accept       json
+// @Produce      json
+// @Param        id        path   string                true   "Wallet ID"
+// @Param        api_key   query  string                false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        invoice   body   CreateInvoiceRequest  true   "Invoice details"
+// @Success      201  {object}  InvoiceResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet or exchange rate service failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/invoices [post]
+func createInvoiceHandler(c *gin.Context) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return
 	}
 
-	// Create a new Gin router
-	router := gin.New() // Use New() instead of Default() to avoid using the default logger
+	var req CreateInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
 
-	// Add essential middleware
-	router.Use(gin.Recovery())
-	router.Use(middleware.LoggingMiddleware())
-	router.Use(middleware.CORSMiddleware()) // Add CORS support
-	
-	// All routes in a single group
-	routes := router.Group("/")
-	{
-		// Health check endpoint - publicly accessible
-		routes.GET("/health", healthCheckHandler)
+	if (req.AmountMsats > 0) == (req.EuroAmount > 0) {
+		respondError(c, validationFailed("exactly one of amount_msats and euro_amount must be a positive amount"))
+		return
+	}
+	if req.ExpirySeconds < 0 || req.ExpirySeconds > maxInvoiceExpiry {
+		respondError(c, validationFailed("expiry_seconds must be between 0 and %d", maxInvoiceExpiry))
+		return
+	}
 
-		// Swagger UI endpoint
-		routes.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, 
-			ginSwagger.DefaultModelsExpandDepth(-1),
-			ginSwagger.DocExpansion("list"),
-			ginSwagger.PersistAuthorization(true)))
-		
-		// Payment endpoint - authentication handled in handler
-		routes.POST("/nwc_payment", nwcPaymentHandler)
-		
-		// EUR to msat conversion endpoint - authentication handled in handler
-		routes.GET("/convert/eur-to-msats", euroToMsatsHandler)
+	amountMsats := req.AmountMsats
+	if req.EuroAmount > 0 {
+		msats, err := euroToMsats(req.EuroAmount)
+		if err != nil {
+			respondError(c, fmt.Errorf("failed to convert EUR to msats: %w", err))
+			return
+		}
+		if msats <= 0 {
+			respondError(c, validationFailed("converted amount must be greater than 0"))
+			return
+		}
+		amountMsats = int64(msats)
 	}
 
-	// Print registered routes for debugging
-	log.Println("Registered routes:")
-	for _, route := range router.Routes() {
-		log.Printf("%s %s", route.Method, route.Path)
+	invoice, err := createInvoice(walletURIs, walletID, nip47.InvoiceParams{
+		AmountMsats: amountMsats,
+		Description: req.Description,
+		Expiry:      time.Duration(req.ExpirySeconds) * time.Second,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
-	// Log API security information
-	// Get the API key from environment variable
-	apiKey, _ := wallet.LoadAPIKey()
-	if apiKey == "" {
-		log.Println("WARNING: Using default API key. Set NWC_API_KEY environment variable for production use.")
-	} else {
-		log.Println("API Key authentication is enabled.")
+	// Keep the invoice so its settlement can be watched
+	trackInvoice(invoices.Invoice{Wallet: walletID, EuroAmount: req.EuroAmount}, invoice)
+
+	c.JSON(http.StatusCreated, InvoiceResponse{
+		Wallet:      walletID,
+		Invoice:     invoice.Raw,
+		PaymentHash: invoice.PaymentHash,
+		AmountMsats: invoice.AmountMsats,
+		EuroAmount:  req.EuroAmount,
+		Description: invoice.Description,
+		CreatedAt:   invoice.CreatedAt,
+		ExpiresAt:   invoice.ExpiresAt,
+	})
+}
+
+// @Summary      Redeem an LNURL-withdraw link
+// @Description  Creates an invoice on the wallet within the limits of an LNURL-withdraw link and submits it to the service. The invoice is watched until it is paid; poll its status to see when the funds arrive.
+// @Tags         invoices
+// @Accept       json
+// @Produce      json
+// @Param        id        path   string                true   "Wallet ID"
+// @Param        api_key   query  string                false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        withdraw  body   LNURLWithdrawRequest  true   "LNURL-withdraw link"
+// @Success      202  {object}  LNURLWithdrawResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse  "LNURL is invalid or was refused by the service"
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/lnurl-withdraw [post]
+func lnurlWithdrawHandler(c *gin.Context) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return
 	}
 
-	return router, nil
+	var req LNURLWithdrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+	if req.AmountMsats < 0 {
+		respondError(c, validationFailed("amount_msats must not be negative"))
+		return
+	}
+
+	invoice, params, err := withdrawLNURL(walletURIs, walletID, req.LNURL, req.AmountMsats)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, LNURLWithdrawResponse{
+		Invoice:         invoice,
+		MinWithdrawable: params.MinWithdrawable,
+		MaxWithdrawable: params.MaxWithdrawable,
+	})
+}
+
+// @Summary      Get invoice status
+// @Description  Looks up an invoice on its wallet and reports whether it is open, paid or expired
+// @Tags         invoices
+// @Produce      json
+// @Param        id            path   string  true   "Wallet ID"
+// @Param        payment_hash  path   string  true   "Payment hash of the invoice"
+// @Param        api_key       query  string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200  {object}  invoices.Invoice
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/invoices/{payment_hash} [get]
+func invoiceStatusHandler(c *gin.Context) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return
+	}
+
+	invoice, err := walletInvoice(c.Request.Context(), walletID, strings.ToLower(c.Param("payment_hash")))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
+// @Summary      Invoice QR code as PNG
+// @Description  Renders an open invoice as a QR code of its upper case lightning: URI, on the server without any external service
+// @Tags         invoices
+// @Produce      png
+// @Param        id            path   string   true   "Wallet ID"
+// @Param        payment_hash  path   string   true   "Payment hash of the invoice"
+// @Param        size          query  integer  false  "Width and height in pixels, 64 to 1024" default(256)
+// @Param        api_key       query  string   false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200  {file}    binary
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "Invoice is already paid or has expired"
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/invoices/{payment_hash}/qr.png [get]
+func invoiceQRPNGHandler(c *gin.Context) {
+	size := 256
+	if value := c.Query("size"); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size < 64 || size > 1024 {
+			respondError(c, invalidRequest("size must be between 64 and 1024 pixels"))
+			return
+		}
+	}
+
+	invoice, ok := payableInvoice(c)
+	if !ok {
+		return
+	}
+
+	png, err := qr.PNG(qr.LightningURI(invoice.Invoice), size)
+	if err != nil {
+		respondError(c, internalError("failed to render QR code: %v", err))
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// @Summary      Invoice QR code as SVG
+// @Description  Renders an open invoice as a scalable QR code of its upper case lightning: URI, on the server without any external service
+// @Tags         invoices
+// @Produce      image/svg+xml
+// @Param        id            path   string  true   "Wallet ID"
+// @Param        payment_hash  path   string  true   "Payment hash of the invoice"
+// @Param        api_key       query  string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200  {string}  string  "SVG image"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "Invoice is already paid or has expired"
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse  "Wallet failed"
+// @Failure      504  {object}  ErrorResponse  "Wallet did not reply in time"
+// @Router       /wallets/{id}/invoices/{payment_hash}/qr.svg [get]
+func invoiceQRSVGHandler(c *gin.Context) {
+	invoice, ok := payableInvoice(c)
+	if !ok {
+		return
+	}
+
+	svg, err := qr.SVG(qr.LightningURI(invoice.Invoice))
+	if err != nil {
+		respondError(c, internalError("failed to render QR code: %v", err))
+		return
+	}
+
+	c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
+// payableInvoice looks up the invoice a QR code is requested for and reports
+// an error unless it can still be paid. The image may be cached until the invoice expires.
+func payableInvoice(c *gin.Context) (invoices.Invoice, bool) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return invoices.Invoice{}, false
+	}
+
+	invoice, err := walletInvoice(c.Request.Context(), walletID, strings.ToLower(c.Param("payment_hash")))
+	if err != nil {
+		respondError(c, err)
+		return invoice, false
+	}
+	if invoice.Status != invoices.StatusOpen {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: CodeConflict,
+			Message: fmt.Sprintf("invoice %s is %s", invoice.PaymentHash, invoice.Status)})
+		return invoice, false
+	}
+	if invoice.Invoice == "" {
+		respondError(c, &WalletError{Wallet: walletID, Err: errors.New("wallet did not return the invoice")})
+		return invoice, false
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", max(int(time.Until(invoice.ExpiresAt).Seconds()), 0)))
+	return invoice, true
+}
+
+// walletInvoice returns an invoice of a configured wallet with its current status
+func walletInvoice(ctx context.Context, walletID, paymentHash string) (invoices.Invoice, error) {
+	invoiceNotFound := notFound("Invoice '%s' not found on wallet '%s'", paymentHash, walletID)
+
+	// Invoices created through the API are updated through the watcher so
+	// a payment noticed here is announced exactly once
+	if invoice, ok := invoiceStore.Get(paymentHash); ok {
+		if invoice.Wallet != walletID {
+			return invoice, invoiceNotFound
+		}
+
+		invoice, err := invoiceWatcher.Check(ctx, invoice)
+		if err != nil {
+			return invoice, fmt.Errorf("failed to look up invoice: %w", err)
+		}
+		return invoice, nil
+	}
+
+	// Other invoices of the wallet are reported as the wallet sees them
+	transaction, err := lookupInvoice(ctx, walletID, paymentHash)
+	if nip47.IsCode(err, nip47.CodeNotFound) {
+		return invoices.Invoice{}, invoiceNotFound
+	}
+	if err != nil {
+		return invoices.Invoice{}, fmt.Errorf("failed to look up invoice: %w", err)
+	}
+
+	invoice := invoices.Invoice{
+		PaymentHash: paymentHash,
+		Wallet:      walletID,
+		Invoice:     transaction.Invoice,
+		Status:      invoices.StatusOpen,
+		AmountMsats: transaction.Amount,
+		Description: transaction.Description,
+		Preimage:    transaction.Preimage,
+		CreatedAt:   time.Unix(transaction.CreatedAt, 0).UTC(),
+		ExpiresAt:   time.Unix(transaction.ExpiresAt, 0).UTC(),
+	}
+	if transaction.Settled() {
+		settledAt := time.Now().UTC()
+		if transaction.SettledAt > 0 {
+			settledAt = time.Unix(transaction.SettledAt, 0).UTC()
+		}
+		invoice.Status = invoices.StatusPaid
+		invoice.SettledAt = &settledAt
+	} else if transaction.ExpiresAt > 0 && !time.Now().Before(invoice.ExpiresAt) {
+		invoice.Status = invoices.StatusExpired
+	}
+
+	return invoice, nil
+}
+
+// @Summary      Lightning Address LNURL-pay endpoint
+// @Description  Returns the LNURL-pay parameters of a wallet's Lightning Address (LUD-06, LUD-16). Errors use the LNURL status format.
+// @Tags         lnurl
+// @Produce      json
+// @Param        name  path  string  true  "Name part of the Lightning Address"
+// @Success      200  {object}  lnurl.PayParams
+// @Failure      404  {object}  lnurl.Status
+// @Failure      429  {object}  ErrorResponse
+// @Router       /.well-known/lnurlp/{name} [get]
+func lnurlpHandler(c *gin.Context) {
+	name := strings.ToLower(c.Param("name"))
+	walletID, ok := lightningAddresses[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, lnurl.ErrorStatus(fmt.Sprintf("Lightning Address '%s' not found", name)))
+		return
+	}
+
+	config := walletConfigs[walletID].LightningAddress
+	baseURL := middleware.PublicBaseURL(c, publicURL)
+	minSendable, maxSendable := config.Sendable()
+
+	c.JSON(http.StatusOK, lnurl.PayParams{
+		Tag:            "payRequest",
+		Callback:       fmt.Sprintf("%s/lnurlp/%s/callback", baseURL, name),
+		MinSendable:    minSendable,
+		MaxSendable:    maxSendable,
+		Metadata:       addressMetadata(name, baseURL),
+		CommentAllowed: config.CommentAllowed,
+	})
+}
+
+// @Summary      Lightning Address LNURL-pay callback
+// @Description  Creates an invoice on the wallet behind a Lightning Address for the requested amount, committing to the address metadata. Errors use the LNURL status format.
+// @Tags         lnurl
+// @Produce      json
+// @Param        name     path   string   true   "Name part of the Lightning Address"
+// @Param        amount   query  integer  true   "Amount in millisatoshis"
+// @Param        comment  query  string   false  "Comment for the recipient (LUD-12)"
+// @Success      200  {object}  lnurl.InvoiceReply
+// @Failure      400  {object}  lnurl.Status
+// @Failure      404  {object}  lnurl.Status
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  lnurl.Status
+// @Router       /lnurlp/{name}/callback [get]
+func lnurlpCallbackHandler(c *gin.Context) {
+	name := strings.ToLower(c.Param("name"))
+	walletID, ok := lightningAddresses[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, lnurl.ErrorStatus(fmt.Sprintf("Lightning Address '%s' not found", name)))
+		return
+	}
+
+	config := walletConfigs[walletID].LightningAddress
+	minSendable, maxSendable := config.Sendable()
+
+	amount, err := strconv.ParseInt(c.Query("amount"), 10, 64)
+	if err != nil || amount < minSendable || amount > maxSendable {
+		c.JSON(http.StatusBadRequest, lnurl.ErrorStatus(fmt.Sprintf("amount must be between %d and %d msats", minSendable, maxSendable)))
+		return
+	}
+
+	comment := c.Query("comment")
+	if len(comment) > config.CommentAllowed {
+		c.JSON(http.StatusBadRequest, lnurl.ErrorStatus(fmt.Sprintf("comment must be at most %d characters", config.CommentAllowed)))
+		return
+	}
+
+	baseURL := middleware.PublicBaseURL(c, publicURL)
+	metadata := addressMetadata(name, baseURL)
+	metadataHash := sha256.Sum256([]byte(metadata))
+
+	invoice, err := createInvoice(walletURIs, walletID, nip47.InvoiceParams{
+		AmountMsats:     amount,
+		DescriptionHash: hex.EncodeToString(metadataHash[:]),
+	})
+	if err != nil {
+		log.Printf("Failed to create Lightning Address invoice for %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, lnurl.ErrorStatus("failed to create invoice"))
+		return
+	}
+
+	trackInvoice(invoices.Invoice{
+		Wallet:      walletID,
+		Description: addressDescription(name, baseURL),
+		Comment:     comment,
+	}, invoice)
+
+	c.JSON(http.StatusOK, lnurl.InvoiceReply{
+		PR:     invoice.Raw,
+		Routes: []any{},
+	})
+}
+
+// addressDescription is the text/plain metadata of a Lightning Address
+func addressDescription(name, baseURL string) string {
+	return "Payment to " + lightningAddress(name, baseURL)
+}
+
+// addressMetadata is the LNURL-pay metadata invoices for a Lightning Address commit to
+func addressMetadata(name, baseURL string) string {
+	return lnurl.Metadata(addressDescription(name, baseURL), lightningAddress(name, baseURL))
+}
+
+// lightningAddress returns name@domain for this server's domain
+func lightningAddress(name, baseURL string) string {
+	host := baseURL
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+	return name + "@" + host
+}
+
+// @Summary      Get remaining wallet budget
+// @Description  Reports the wallet's spending policy and how much of each daily, weekly and monthly budget is left
+// @Tags         wallets
+// @Produce      json
+// @Param        id        path   string  true   "Wallet ID"
+// @Param        api_key   query  string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200  {object}  BudgetResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Router       /wallets/{id}/budget [get]
+func walletBudgetHandler(c *gin.Context) {
+	walletID := strings.ToUpper(c.Param("id"))
+	if _, exists := walletURIs[walletID]; !exists {
+		respondError(c, &WalletNotFoundError{Wallet: walletID})
+		return
+	}
+
+	now := time.Now()
+	policy := walletConfigs[walletID].Spending
+	history := paymentLedger.Sent(walletID, budget.Earliest(policy, now))
+
+	c.JSON(http.StatusOK, BudgetResponse{
+		Wallet:          walletID,
+		MaxPaymentMsats: policy.MaxPaymentMsats,
+		MaxPaymentEur:   policy.MaxPaymentEur,
+		Windows:         budget.Report(policy, history, now),
+	})
+}
+
+// @Summary      Make a batch of payments
+// @Description  Pay several recipients from one wallet, given as JSON or as a CSV upload with recipient and euro_amount columns. For CSV the other fields are passed as query or form parameters. The batch is stored and paid in the background; its progress and results are fetched by its ID.
+// @Tags         payments
+// @Accept       json
+// @Accept       text/csv
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        api_key   query     string               false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        batch     body      BatchPaymentRequest  false  "Batch of payments"
+// @Param        file      formData  file                 false  "CSV file with recipient and euro_amount columns"
+// @Success      202      {object}  batches.Batch
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Sender wallet not found"
+// @Failure      413      {object}  ErrorResponse  "CSV larger than 1 MB"
+// @Failure      422      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /payments/batch [post]
+func batchPaymentHandler(c *gin.Context) {
+	req, err := bindBatchPaymentRequest(c)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		respondError(c, apiErr)
+		return
+	}
+	if err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	if err := validateBatchPaymentRequest(&req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	items := make([]batches.Item, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = batches.Item{Recipient: item.Recipient, EuroAmount: item.EuroAmount}
+	}
+
+	batch, err := batchStore.Create(batches.Batch{
+		Sender:   req.Sender,
+		MultiPay: req.MultiPay,
+		Items:    items,
+	})
+	if err != nil {
+		respondError(c, internalError("failed to store batch: %v", err))
+		return
+	}
+
+	log.Printf("Started batch %s of %d payments from %s", batch.ID, len(items), req.Sender)
+
+	// A batch can take minutes, so it runs after the response; its progress is
+	// stored as it goes and read through the status endpoint
+	go runBatch(walletURIs, batch, BatchOptions{
+		FeeLimit: wallet.FeePolicy{
+			MaxFeeMsats:   req.MaxFeeMsats,
+			MaxFeePercent: req.MaxFeePercent,
+		},
+		Concurrency: req.Concurrency,
+		MultiPay:    req.MultiPay,
+	})
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// @Summary      Get a batch of payments
+// @Description  Get the status, per payment results and summary of a batch, also while it is running
+// @Tags         payments
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Batch ID"
+// @Success      200      {object}  batches.Batch
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /payments/batch/{id} [get]
+func batchStatusHandler(c *gin.Context) {
+	batch, ok := batchStore.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("batch '%s' not found", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// bindBatchPaymentRequest reads a batch from a JSON body, a CSV body or an uploaded CSV file
+func bindBatchPaymentRequest(c *gin.Context) (BatchPaymentRequest, error) {
+	var req BatchPaymentRequest
+	var csvFile io.Reader
+
+	switch c.ContentType() {
+	case "text/csv":
+		csvFile = c.Request.Body
+	case "multipart/form-data":
+		header, err := c.FormFile("file")
+		if err != nil {
+			return req, errors.New("missing CSV file")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return req, err
+		}
+		defer file.Close()
+		csvFile = file
+	default:
+		err := c.ShouldBindJSON(&req)
+		return req, err
+	}
+
+	items, err := parseBatchCSV(csvFile)
+	if err != nil {
+		return req, err
+	}
+	req.Items = items
+
+	// Form values take precedence over the query string
+	option := func(name string) string {
+		if value, ok := c.GetPostForm(name); ok {
+			return value
+		}
+		return c.Query(name)
+	}
+
+	req.Sender = option("sender")
+	if req.Sender == "" {
+		return req, errors.New("sender is required")
+	}
+	if value := option("max_fee_msats"); value != "" {
+		maxFee, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return req, fmt.Errorf("invalid max_fee_msats %q", value)
+		}
+		req.MaxFeeMsats = &maxFee
+	}
+	if value := option("max_fee_percent"); value != "" {
+		maxFee, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return req, fmt.Errorf("invalid max_fee_percent %q", value)
+		}
+		req.MaxFeePercent = &maxFee
+	}
+	if value := option("concurrency"); value != "" {
+		if req.Concurrency, err = strconv.Atoi(value); err != nil {
+			return req, fmt.Errorf("invalid concurrency %q", value)
+		}
+	}
+	if value := option("multi_pay"); value != "" {
+		if req.MultiPay, err = strconv.ParseBool(value); err != nil {
+			return req, fmt.Errorf("invalid multi_pay %q", value)
+		}
+	}
+
+	return req, nil
+}
+
+// parseBatchCSV reads batch items from CSV with a header row naming the
+// recipient and euro_amount columns; other columns are ignored.
+// Uploads over maxBatchCSVSize are refused whole, as a cut off row could still parse.
+func parseBatchCSV(r io.Reader) ([]BatchPaymentItem, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBatchCSVSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(data) > maxBatchCSVSize {
+		return nil, bodyTooLarge("CSV is larger than %d bytes", maxBatchCSVSize)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	recipientColumn, amountColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "recipient":
+			recipientColumn = i
+		case "euro_amount":
+			amountColumn = i
+		}
+	}
+	if recipientColumn < 0 || amountColumn < 0 {
+		return nil, errors.New("CSV header must name the recipient and euro_amount columns")
+	}
+
+	var items []BatchPaymentItem
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if len(record) <= max(recipientColumn, amountColumn) {
+			return nil, fmt.Errorf("CSV line %d has too few columns", line)
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[amountColumn]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d has an invalid euro_amount %q", line, record[amountColumn])
+		}
+		items = append(items, BatchPaymentItem{
+			Recipient:  strings.TrimSpace(record[recipientColumn]),
+			EuroAmount: amount,
+		})
+	}
+
+	return items, nil
+}
+
+// validateBatchPaymentRequest checks a batch before any payment is made and applies defaults
+func validateBatchPaymentRequest(req *BatchPaymentRequest) error {
+	if _, ok := walletURIs[req.Sender]; !ok {
+		return &WalletNotFoundError{Role: "sender", Wallet: req.Sender}
+	}
+	if len(req.Items) == 0 {
+		return validationFailed("batch has no payments")
+	}
+	if len(req.Items) > maxBatchItems {
+		return validationFailed("batch has %d payments, at most %d are allowed", len(req.Items), maxBatchItems)
+	}
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		return validationFailed("fee limits must not be negative")
+	}
+
+	if req.Concurrency == 0 {
+		req.Concurrency = defaultBatchConcurrency
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxBatchConcurrency {
+		return validationFailed("concurrency must be between 1 and %d", maxBatchConcurrency)
+	}
+
+	for i, item := range req.Items {
+		if item.EuroAmount <= 0 {
+			return validationFailed("payment %d: euro_amount must be greater than 0", i)
+		}
+		if item.Recipient == req.Sender {
+			return validationFailed("payment %d: recipient must differ from the sender", i)
+		}
+		if _, ok := walletURIs[item.Recipient]; !ok && !lnurl.IsAddress(item.Recipient) {
+			return validationFailed("payment %d: recipient '%s' is neither a configured wallet nor a Lightning Address", i, item.Recipient)
+		}
+	}
+
+	return nil
+}
+
+// @Summary      Make a split payment
+// @Description  Divide one EUR payment across several recipients by percentage or fixed amounts. The total is converted once, every share's invoice is fetched and reserved before anything is paid, and the shares are paid as linked payments reported together.
+// @Tags         payments
+// @Accept       json
+// @Produce      json
+// @Param        api_key   query   string               false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        split     body    SplitPaymentRequest  true   "Split payment"
+// @Success      200      {object}  batches.Batch
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Sender wallet not found"
+// @Failure      422      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      502      {object}  ErrorResponse  "Exchange rate unavailable"
+// @Router       /payments/split [post]
+func splitPaymentHandler(c *gin.Context) {
+	var req SplitPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	if err := validateSplitPaymentRequest(req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Convert once so every share is valued at the same rate
+	btcPriceInEur, err := fetchBTCPriceEUR()
+	if err != nil {
+		respondError(c, fmt.Errorf("failed to convert EUR to msats: %w", err))
+		return
+	}
+
+	split, err := splitBatch(req, btcPriceInEur)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	split, err = batchStore.Create(split)
+	if err != nil {
+		respondError(c, internalError("failed to store split payment: %v", err))
+		return
+	}
+
+	log.Printf("Started split payment %s of %d msats from %s to %d recipients", split.ID, split.AmountMsats, req.Sender, len(split.Items))
+
+	split = runBatch(walletURIs, split, BatchOptions{
+		FeeLimit: wallet.FeePolicy{
+			MaxFeeMsats:   req.MaxFeeMsats,
+			MaxFeePercent: req.MaxFeePercent,
+		},
+		Concurrency:  len(split.Items),
+		MultiPay:     req.MultiPay,
+		AllOrNothing: true,
+	})
+
+	c.JSON(http.StatusOK, split)
+}
+
+// @Summary      Get a split payment
+// @Description  Get the status, per share results and summary of a split payment
+// @Tags         payments
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Split payment ID"
+// @Success      200      {object}  batches.Batch
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /payments/split/{id} [get]
+func splitStatusHandler(c *gin.Context) {
+	split, ok := batchStore.Get(c.Param("id"))
+	if !ok || split.Kind != batches.KindSplit {
+		respondError(c, notFound("split payment '%s' not found", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, split)
+}
+
+// validateSplitPaymentRequest checks the sender, recipients and shares of a split payment
+func validateSplitPaymentRequest(req SplitPaymentRequest) error {
+	if _, ok := walletURIs[req.Sender]; !ok {
+		return &WalletNotFoundError{Role: "sender", Wallet: req.Sender}
+	}
+	if len(req.Shares) == 0 {
+		return validationFailed("split has no shares")
+	}
+	if len(req.Shares) > maxSplitShares {
+		return validationFailed("split has %d shares, at most %d are allowed", len(req.Shares), maxSplitShares)
+	}
+	if req.EuroAmount < 0 {
+		return validationFailed("euro_amount must not be negative")
+	}
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		return validationFailed("fee limits must not be negative")
+	}
+
+	for i, share := range req.Shares {
+		if (share.Percent == nil) == (share.EuroAmount == nil) {
+			return validationFailed("share %d: set either percent or euro_amount", i)
+		}
+		if (share.Percent != nil && *share.Percent <= 0) || (share.EuroAmount != nil && *share.EuroAmount <= 0) {
+			return validationFailed("share %d: amount must be greater than 0", i)
+		}
+		if share.Recipient == req.Sender {
+			return validationFailed("share %d: recipient must differ from the sender", i)
+		}
+		if _, ok := walletURIs[share.Recipient]; !ok && !lnurl.IsAddress(share.Recipient) {
+			return validationFailed("share %d: recipient '%s' is neither a configured wallet nor a Lightning Address", i, share.Recipient)
+		}
+	}
+
+	return nil
+}
+
+// splitBatch divides the total of a split payment across its shares at the given BTC price in EUR
+func splitBatch(req SplitPaymentRequest, btcPriceInEur float64) (batches.Batch, error) {
+	shares := make([]batches.Share, len(req.Shares))
+	var fixedEuro float64
+	var fixedMsats int64
+	allFixed := true
+	for i, share := range req.Shares {
+		if share.Percent != nil {
+			shares[i].Percent = *share.Percent
+			allFixed = false
+			continue
+		}
+		shares[i].FixedMsats = int64(euroToMsatsAt(*share.EuroAmount, btcPriceInEur))
+		if shares[i].FixedMsats <= 0 {
+			return batches.Batch{}, validationFailed("share %d: converted amount must be greater than 0", i)
+		}
+		fixedEuro += *share.EuroAmount
+		fixedMsats += shares[i].FixedMsats
+	}
+
+	// Without percentages the total is the sum of the fixed amounts
+	totalEuro, totalMsats := req.EuroAmount, int64(euroToMsatsAt(req.EuroAmount, btcPriceInEur))
+	if allFixed {
+		if req.EuroAmount > 0 && math.Abs(req.EuroAmount-fixedEuro) > 1e-9 {
+			return batches.Batch{}, validationFailed("shares add up to %g EUR instead of %g EUR", fixedEuro, req.EuroAmount)
+		}
+		totalEuro, totalMsats = fixedEuro, fixedMsats
+	} else if totalMsats <= 0 {
+		return batches.Batch{}, validationFailed("euro_amount is required and must convert to more than 0 msats when shares are percentages")
+	}
+
+	parts, err := batches.SplitAmount(totalMsats, shares)
+	if err != nil {
+		return batches.Batch{}, validationFailed("%v", err)
+	}
+
+	items := make([]batches.Item, len(parts))
+	for i, part := range parts {
+		items[i] = batches.Item{
+			Recipient:   req.Shares[i].Recipient,
+			Percent:     shares[i].Percent,
+			AmountMsats: part,
+			EuroAmount:  math.Round(totalEuro*float64(part)/float64(totalMsats)*1e8) / 1e8,
+		}
+		if req.Shares[i].EuroAmount != nil {
+			items[i].EuroAmount = *req.Shares[i].EuroAmount
+		}
+	}
+
+	return batches.Batch{
+		Kind:        batches.KindSplit,
+		Sender:      req.Sender,
+		MultiPay:    req.MultiPay,
+		EuroAmount:  totalEuro,
+		AmountMsats: totalMsats,
+		Items:       items,
+	}, nil
+}
+
+// @Summary      Create a recurring payment
+// @Description  Schedule a payment from a wallet to another wallet or a Lightning Address, due on a cron expression or at a fixed interval until an optional end date
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param        api_key   query   string                 false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        schedule  body    CreateScheduleRequest  true   "Recurring payment"
+// @Success      201      {object}  ScheduleResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Sender wallet not found"
+// @Failure      422      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /schedules [post]
+func createScheduleHandler(c *gin.Context) {
+	var req CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	schedule, err := newSchedule(req, time.Now())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	schedule, err = scheduleStore.Create(schedule)
+	if err != nil {
+		respondError(c, internalError("failed to store schedule: %v", err))
+		return
+	}
+
+	log.Printf("Scheduled payment %s from %s to %s, first run at %s", schedule.ID, schedule.Sender, schedule.Recipient, schedule.NextRunAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusCreated, ScheduleResponse{Schedule: schedule, Runs: []schedules.Run{}})
+}
+
+// @Summary      List recurring payments
+// @Description  List all recurring payments with their status and next run
+// @Tags         schedules
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200      {array}   schedules.Schedule
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /schedules [get]
+func listSchedulesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, scheduleStore.List())
+}
+
+// @Summary      Get a recurring payment
+// @Description  Get a recurring payment and the history of its runs, each linked to its ledger entry
+// @Tags         schedules
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Schedule ID"
+// @Success      200      {object}  ScheduleResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /schedules/{id} [get]
+func scheduleHandler(c *gin.Context) {
+	schedule, ok := scheduleStore.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("schedule '%s' not found", c.Param("id")))
+		return
+	}
+
+	runs := scheduleStore.Runs(schedule.ID)
+	if runs == nil {
+		runs = []schedules.Run{}
+	}
+
+	c.JSON(http.StatusOK, ScheduleResponse{Schedule: schedule, Runs: runs})
+}
+
+// @Summary      Cancel a recurring payment
+// @Description  Stop a recurring payment; its history is kept
+// @Tags         schedules
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Schedule ID"
+// @Success      200      {object}  schedules.Schedule
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse  "Schedule has already ended or been cancelled"
+// @Failure      429      {object}  ErrorResponse
+// @Router       /schedules/{id} [delete]
+func cancelScheduleHandler(c *gin.Context) {
+	schedule, err := scheduleStore.Cancel(c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(c, notFound("schedule '%s' not found", c.Param("id")))
+		return
+	}
+	if errors.Is(err, schedules.ErrNotActive) {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		respondError(c, internalError("failed to cancel schedule: %v", err))
+		return
+	}
+
+	log.Printf("Cancelled scheduled payment %s", schedule.ID)
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary      Rebalance wallets
+// @Description  Move funds from wallets above their configured balance range to wallets below it.
+// @Description  Transfers are paid one after another and recorded in the ledger with kind rebalance.
+// @Description  A dry run only returns the transfers that would be made. Runs that moved funds are kept.
+// @Tags         rebalancing
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        dry_run   query   bool    false  "Only plan the transfers"
+// @Success      200      {object}  rebalance.Run
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse  "A rebalance is already running"
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /rebalances [post]
+func rebalanceHandler(c *gin.Context) {
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, invalidRequest("invalid dry_run %q", value))
+			return
+		}
+	}
+
+	run, err := rebalancer.RunOnce(c.Request.Context(), dryRun)
+	if errors.Is(err, rebalance.ErrRunning) {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error(), Retriable: true})
+		return
+	}
+	if err != nil {
+		respondError(c, internalError("failed to store rebalance: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// @Summary      List rebalances
+// @Description  List the rebalances that moved funds, newest first
+// @Tags         rebalancing
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200      {array}   rebalance.Run
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /rebalances [get]
+func listRebalancesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, rebalancer.Store.List())
+}
+
+// @Summary      Get a rebalance
+// @Description  Get a rebalance with the balances it started from and its transfers, each linked to its ledger entry
+// @Tags         rebalancing
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Rebalance ID"
+// @Success      200      {object}  rebalance.Run
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /rebalances/{id} [get]
+func rebalanceStatusHandler(c *gin.Context) {
+	run, ok := rebalancer.Store.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("rebalance '%s' not found", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// @Summary      Create a payment request
+// @Description  Creates a hosted payment request for a Euro amount into a configured wallet and returns the link of its payment page.
+// @Description  The page shows an invoice quoted at the current rate, quotes again when the quote expires and reports when the request is paid.
+// @Tags         payment-requests
+// @Accept       json
+// @Produce      json
+// @Param        api_key   query   string                       false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        request   body    CreatePaymentRequestRequest  true   "Payment request"
+// @Success      201      {object}  PaymentRequestResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse  "Recipient wallet not found"
+// @Failure      422      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /payment-requests [post]
+func createPaymentRequestHandler(c *gin.Context) {
+	var req CreatePaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	recipient := strings.ToUpper(req.Recipient)
+	if _, exists := walletURIs[recipient]; !exists {
+		respondError(c, &WalletNotFoundError{Role: "recipient", Wallet: recipient})
+		return
+	}
+	if req.EuroAmount <= 0 || math.IsInf(req.EuroAmount, 0) || math.IsNaN(req.EuroAmount) {
+		respondError(c, validationFailed("euro_amount must be greater than 0"))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		respondError(c, validationFailed("expires_at must be in the future"))
+		return
+	}
+
+	request := payrequests.Request{
+		Recipient:   recipient,
+		EuroAmount:  req.EuroAmount,
+		Description: req.Description,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		request.ExpiresAt = &expiresAt
+	}
+
+	request, err := paymentRequests.Create(request)
+	if err != nil {
+		respondError(c, internalError("failed to store payment request: %v", err))
+		return
+	}
+
+	log.Printf("Created payment request %s for %.2f EUR into %s", request.ID, request.EuroAmount, request.Recipient)
+
+	c.JSON(http.StatusCreated, paymentRequestResponse(c, request))
+}
+
+// @Summary      List payment requests
+// @Description  List all hosted payment requests with their status, newest first
+// @Tags         payment-requests
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200      {array}   PaymentRequestResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /payment-requests [get]
+func listPaymentRequestsHandler(c *gin.Context) {
+	responses := []PaymentRequestResponse{}
+	for _, request := range paymentRequests.List() {
+		responses = append(responses, paymentRequestResponse(c, request))
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// @Summary      Get a payment request
+// @Description  Get a hosted payment request with its current quote, or when and how much it was paid
+// @Tags         payment-requests
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Payment request ID"
+// @Success      200      {object}  PaymentRequestResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /payment-requests/{id} [get]
+func paymentRequestHandler(c *gin.Context) {
+	request, ok := paymentRequests.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("payment request '%s' not found", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, paymentRequestResponse(c, request))
+}
+
+// paymentRequestResponse adds the link of its payment page to a payment request
+func paymentRequestResponse(c *gin.Context, request payrequests.Request) PaymentRequestResponse {
+	return PaymentRequestResponse{
+		Request: request,
+		URL:     middleware.PublicBaseURL(c, publicURL) + "/pay/" + request.ID,
+	}
+}
+
+// @Summary      Register a webhook
+// @Description  Registers an endpoint that receives the given events as signed JSON POST requests.
+// @Description  The secret signing the deliveries is only returned here. Events may be "*" for every event type: payment.succeeded, payment.failed, invoice.paid, invoice.expired, payment_request.paid, wallet.unhealthy, wallet.healthy and balance.low.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        api_key   query   string                false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        webhook   body    CreateWebhookRequest  true   "Webhook to register"
+// @Success      201      {object}  webhooks.Webhook
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      422      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /webhooks [post]
+func createWebhookHandler(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, invalidRequest("invalid request: %v", err))
+		return
+	}
+
+	endpoint, err := url.Parse(req.URL)
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		respondError(c, validationFailed("url must be an absolute http or https URL"))
+		return
+	}
+	if len(req.Events) == 0 {
+		respondError(c, validationFailed("events must list at least one event type"))
+		return
+	}
+	for _, eventType := range req.Events {
+		if eventType != webhooks.AllEvents && !slices.Contains(events.Types, eventType) {
+			respondError(c, validationFailed("unknown event type %q", eventType))
+			return
+		}
+	}
+
+	webhook := webhooks.Webhook{
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+	}
+	for _, walletID := range req.Wallets {
+		walletID = strings.ToUpper(walletID)
+		if _, exists := walletURIs[walletID]; !exists {
+			respondError(c, &WalletNotFoundError{Wallet: walletID})
+			return
+		}
+		webhook.Wallets = append(webhook.Wallets, walletID)
+	}
+
+	webhook, err = webhookStore.Create(webhook)
+	if err != nil {
+		respondError(c, internalError("failed to store webhook: %v", err))
+		return
+	}
+
+	log.Printf("Registered webhook %s for %s at %s", webhook.ID, strings.Join(webhook.Events, ", "), endpoint.Host)
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// @Summary      List webhooks
+// @Description  List the registered webhooks without their secrets, newest first
+// @Tags         webhooks
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      200      {array}   webhooks.Webhook
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /webhooks [get]
+func listWebhooksHandler(c *gin.Context) {
+	list := []webhooks.Webhook{}
+	for _, webhook := range webhookStore.List() {
+		webhook.Secret = ""
+		list = append(list, webhook)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary      Get a webhook
+// @Description  Get a registered webhook without its secret
+// @Tags         webhooks
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Webhook ID"
+// @Success      200      {object}  webhooks.Webhook
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /webhooks/{id} [get]
+func webhookHandler(c *gin.Context) {
+	webhook, ok := webhookStore.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("webhook '%s' not found", c.Param("id")))
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// @Summary      Delete a webhook
+// @Description  Removes a webhook together with its deliveries, including those still waiting for a retry
+// @Tags         webhooks
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Webhook ID"
+// @Success      204
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /webhooks/{id} [delete]
+func deleteWebhookHandler(c *gin.Context) {
+	err := webhookStore.Delete(c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(c, notFound("webhook '%s' not found", c.Param("id")))
+		return
+	}
+	if err != nil {
+		respondError(c, internalError("failed to delete webhook: %v", err))
+		return
+	}
+
+	log.Printf("Deleted webhook %s", c.Param("id"))
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary      List webhook deliveries
+// @Description  List the events queued for a webhook, newest first, with every attempt at delivering them and the HTTP status the endpoint answered with
+// @Tags         webhooks
+// @Produce      json
+// @Param        api_key   query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id        path    string  true   "Webhook ID"
+// @Param        status    query   string  false  "Only deliveries with this status"  Enums(pending, delivered, failed)
+// @Success      200      {array}   webhooks.Delivery
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
+// @Router       /webhooks/{id}/deliveries [get]
+func webhookDeliveriesHandler(c *gin.Context) {
+	if _, ok := webhookStore.Get(c.Param("id")); !ok {
+		respondError(c, notFound("webhook '%s' not found", c.Param("id")))
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != webhooks.StatusPending && status != webhooks.StatusDelivered && status != webhooks.StatusFailed {
+		respondError(c, invalidRequest("invalid status %q", status))
+		return
+	}
+
+	deliveries := []webhooks.Delivery{}
+	for _, delivery := range webhookStore.Deliveries(c.Param("id")) {
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// @Summary      Replay a webhook delivery
+// @Description  Queues the payload of a delivered or failed delivery again as a new delivery, which is sent right away and retried like any other
+// @Tags         webhooks
+// @Produce      json
+// @Param        api_key      query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        id           path    string  true   "Webhook ID"
+// @Param        delivery_id  path    string  true   "Delivery ID"
+// @Success      202      {object}  webhooks.Delivery
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse  "Delivery is still pending"
+// @Failure      429      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/replay [post]
+func replayWebhookDeliveryHandler(c *gin.Context) {
+	original, ok := webhookStore.Delivery(c.Param("delivery_id"))
+	if !ok || original.WebhookID != c.Param("id") {
+		respondError(c, notFound("delivery '%s' of webhook '%s' not found", c.Param("delivery_id"), c.Param("id")))
+		return
+	}
+
+	delivery, err := webhookStore.Replay(original.ID)
+	if errors.Is(err, webhooks.ErrPending) {
+		respondError(c, &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		respondError(c, internalError("failed to queue delivery: %v", err))
+		return
+	}
+	webhookDispatcher.Wake()
+
+	log.Printf("Replaying delivery %s of webhook %s as %s", original.ID, original.WebhookID, delivery.ID)
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// newSchedule validates a schedule request and works out its first run after now
+func newSchedule(req CreateScheduleRequest, now time.Time) (schedules.Schedule, error) {
+	schedule := schedules.Schedule{
+		Sender:        req.Sender,
+		Recipient:     req.Recipient,
+		Amount:        req.Amount,
+		Currency:      strings.ToUpper(req.Currency),
+		Cron:          strings.TrimSpace(req.Cron),
+		Interval:      req.Interval,
+		MissedRuns:    req.MissedRuns,
+		MaxFeeMsats:   req.MaxFeeMsats,
+		MaxFeePercent: req.MaxFeePercent,
+	}
+	if schedule.Currency == "" {
+		schedule.Currency = schedules.CurrencyEUR
+	}
+	if schedule.MissedRuns == "" {
+		schedule.MissedRuns = schedules.MissedSkip
+	}
+
+	if _, ok := walletURIs[req.Sender]; !ok {
+		return schedule, &WalletNotFoundError{Role: "sender", Wallet: req.Sender}
+	}
+	if req.Recipient == req.Sender {
+		return schedule, validationFailed("recipient must differ from the sender")
+	}
+	if _, ok := walletURIs[req.Recipient]; !ok && !lnurl.IsAddress(req.Recipient) {
+		return schedule, validationFailed("recipient '%s' is neither a configured wallet nor a Lightning Address", req.Recipient)
+	}
+
+	switch schedule.Currency {
+	case schedules.CurrencyEUR, schedules.CurrencySAT:
+	case schedules.CurrencyMSAT:
+		if req.Amount != math.Trunc(req.Amount) {
+			return schedule, validationFailed("MSAT amounts must be whole numbers")
+		}
+	default:
+		return schedule, validationFailed("currency must be EUR, SAT or MSAT, not %q", req.Currency)
+	}
+	if req.Amount <= 0 {
+		return schedule, validationFailed("amount must be greater than 0")
+	}
+	if (req.MaxFeeMsats != nil && *req.MaxFeeMsats < 0) || (req.MaxFeePercent != nil && *req.MaxFeePercent < 0) {
+		return schedule, validationFailed("fee limits must not be negative")
+	}
+	if schedule.MissedRuns != schedules.MissedSkip && schedule.MissedRuns != schedules.MissedRunOnce {
+		return schedule, validationFailed("missed_runs must be %s or %s", schedules.MissedSkip, schedules.MissedRunOnce)
+	}
+
+	if (schedule.Cron == "") == (schedule.Interval == "") {
+		return schedule, validationFailed("set either cron or interval")
+	}
+	if schedule.Cron != "" {
+		if _, err := schedules.ParseCron(schedule.Cron); err != nil {
+			return schedule, validationFailed("%v", err)
+		}
+	} else {
+		interval, err := time.ParseDuration(schedule.Interval)
+		if err != nil {
+			return schedule, validationFailed("invalid interval %q: use a duration such as 24h", schedule.Interval)
+		}
+		if interval < minScheduleInterval {
+			return schedule, validationFailed("interval must be at least %s", minScheduleInterval)
+		}
+	}
+
+	if req.EndsAt != nil {
+		endsAt := req.EndsAt.UTC()
+		schedule.EndsAt = &endsAt
+	}
+
+	// An interval schedule first runs at its start, a cron schedule at its first match after it
+	var first time.Time
+	var err error
+	switch {
+	case req.StartsAt != nil && req.StartsAt.After(now) && schedule.Interval != "":
+		first = req.StartsAt.UTC()
+		if schedule.EndsAt != nil && first.After(*schedule.EndsAt) {
+			first = time.Time{}
+		}
+	case req.StartsAt != nil && req.StartsAt.After(now):
+		first, err = schedule.Next(req.StartsAt.Add(-time.Minute))
+	default:
+		first, err = schedule.Next(now)
+	}
+	if err != nil {
+		return schedule, validationFailed("%v", err)
+	}
+	if first.IsZero() {
+		return schedule, validationFailed("schedule has no upcoming run")
+	}
+	schedule.NextRunAt = &first
+
+	return schedule, nil
+}
+
+// InitializeAPI sets up the Gin router with all routes and middleware
+func InitializeAPI() (*gin.Engine, error) {
+	// Load wallet URIs using our wallet package
+	var err error
+	walletURIs, err = wallet.LoadWalletURIs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet URIs: %w", err)
+	}
+
+	// Load per wallet settings and the payment ledger
+	walletConfigs, err = wallet.LoadWalletConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultFeePolicy, err = wallet.LoadFeePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err = loadRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	paymentLedger, err = ledger.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payment ledger: %w", err)
+	}
+
+	// Move old entries out of the ledger so it does not grow forever
+	ledgerArchiver, err := loadLedgerArchiver()
+	if err != nil {
+		return nil, err
+	}
+	go ledgerArchiver.Run(context.Background())
+
+	scheduleStore, err = schedules.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schedule store: %w", err)
+	}
+
+	batchStore, err = batches.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch store: %w", err)
+	}
+
+	// Watch the invoices created through the API until they settle
+	invoiceStore, err = invoices.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open invoice store: %w", err)
+	}
+
+	pollInterval, err := loadInvoicePollInterval()
+	if err != nil {
+		return nil, err
+	}
+
+	eventLog, err = loadEventLog()
+	if err != nil {
+		return nil, err
+	}
+
+	eventBus = events.NewBus(eventLog)
+	invoiceWatcher = &invoices.Watcher{
+		Store:    invoiceStore,
+		Lookup:   lookupInvoice,
+		Events:   eventBus,
+		Interval: pollInterval,
+	}
+	go invoiceWatcher.Run(context.Background())
+
+	// Run recurring payments when they fall due
+	scheduler := &schedules.Scheduler{
+		Store:    scheduleStore,
+		Pay:      paySchedule,
+		Interval: 15 * time.Second,
+	}
+	go scheduler.Run(context.Background())
+
+	// Move funds between wallets to keep them within their balance range
+	rebalancer, err = loadRebalancer()
+	if err != nil {
+		return nil, err
+	}
+	go rebalancer.Run(context.Background())
+
+	// Keep hosted payment requests, marking them paid as their invoices settle
+	paymentRequests, err = payrequests.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payment request store: %w", err)
+	}
+
+	paymentQuoteTTL, err = loadPaymentQuoteTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	paidInvoices, _ := eventBus.Subscribe(100)
+	go settlePaymentRequests(paidInvoices)
+
+	// Deliver events to registered webhooks, resuming the deliveries queued before a restart
+	webhookDispatcher, err = loadWebhookDispatcher()
+	if err != nil {
+		return nil, err
+	}
+	webhookStore = webhookDispatcher.Store
+	webhookEvents, _ := eventBus.Subscribe(100)
+	go webhookDispatcher.Listen(webhookEvents)
+	go webhookDispatcher.Run(context.Background())
+
+	// Report wallets that stop answering or run low on funds
+	walletMonitor, err := loadWalletMonitor()
+	if err != nil {
+		return nil, err
+	}
+	go walletMonitor.Run(context.Background())
+
+	// Record and announce the payments the wallets report, including ones made elsewhere
+	go loadNotificationListener().Run(context.Background())
+
+	lnurlClient = lnurl.NewClient(wallet.LoadSetting("NWC_LNURL_ALLOW_HTTP") == "true")
+
+	// Serve a Lightning Address for every wallet that does not opt out
+	publicURL = wallet.LoadSetting("NWC_PUBLIC_URL")
+	lightningAddresses, err = loadLightningAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure the accepted authentication schemes
+	authSchemes, err := loadAuthSchemes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure per route rate limits
+	rateLimit, err := loadRateLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	// Set Gin to release mode in production
+	if os.Getenv("GIN_MODE") != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Create a new Gin router
+	router := gin.New() // Use New() instead of Default() to avoid using the default logger
+	apiRouter = router
+
+	// Only believe the client IP forwarded by configured proxies
+	if err := router.SetTrustedProxies(loadTrustedProxies()); err != nil {
+		return nil, fmt.Errorf("invalid NWC_TRUSTED_PROXIES: %w", err)
+	}
+
+	// Add essential middleware
+	router.Use(gin.Recovery())
+	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.CORSMiddleware()) // Add CORS support
+	
+	// All routes in a single group
+	routes := router.Group("/")
+	{
+		// Health check endpoint - publicly accessible
+		routes.GET("/health", healthCheckHandler)
+
+		// Lightning Address endpoints - publicly accessible so anyone can pay our wallets
+		routes.GET("/.well-known/lnurlp/:name",
+			rateLimit("lnurlp", middleware.ByClientIP),
+			lnurlpHandler)
+		routes.GET("/lnurlp/:name/callback",
+			rateLimit("lnurlp", middleware.ByClientIP),
+			lnurlpCallbackHandler)
+
+		// Hosted payment pages - publicly accessible so payers need no API key
+		routes.GET("/pay/:id",
+			rateLimit("pay", middleware.ByClientIP),
+			payPageHandler)
+		routes.GET("/pay/:id/status",
+			rateLimit("pay", middleware.ByClientIP),
+			payPageStatusHandler)
+
+		// Swagger UI endpoint
+		routes.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, 
+			ginSwagger.DefaultModelsExpandDepth(-1),
+			ginSwagger.DocExpansion("list"),
+			ginSwagger.PersistAuthorization(true)))
+		
+	}
+
+	// Authenticated routes accept the API key, an HMAC signature or a NIP-98 event
+	authenticated := routes.Group("/", middleware.AuthMiddleware(authSchemes...))
+	{
+		// Payment endpoint
+		authenticated.POST("/nwc_payment",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByBodyField("sender")),
+			nwcPaymentHandler)
+
+		// Batch payment endpoints
+		authenticated.POST("/payments/batch",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByBodyField("sender")),
+			batchPaymentHandler)
+		authenticated.GET("/payments/batch/:id",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			batchStatusHandler)
+
+		// Recurring payment endpoints
+		authenticated.POST("/schedules",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByBodyField("sender")),
+			createScheduleHandler)
+		authenticated.GET("/schedules",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			listSchedulesHandler)
+		authenticated.GET("/schedules/:id",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			scheduleHandler)
+		authenticated.DELETE("/schedules/:id",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			cancelScheduleHandler)
+
+		// Hosted payment request endpoints
+		authenticated.POST("/payment-requests",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("invoices", middleware.ByPrincipal, middleware.ByClientIP),
+			createPaymentRequestHandler)
+		authenticated.GET("/payment-requests",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			listPaymentRequestsHandler)
+		authenticated.GET("/payment-requests/:id",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			paymentRequestHandler)
+
+		// Webhooks are administration, not granted with any other permission
+		authenticated.POST("/webhooks",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			createWebhookHandler)
+		authenticated.GET("/webhooks",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			listWebhooksHandler)
+		authenticated.GET("/webhooks/:id",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			webhookHandler)
+		authenticated.DELETE("/webhooks/:id",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			deleteWebhookHandler)
+		authenticated.GET("/webhooks/:id/deliveries",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			webhookDeliveriesHandler)
+		authenticated.POST("/webhooks/:id/deliveries/:delivery_id/replay",
+			middleware.RequirePermission(middleware.PermissionAdmin),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			replayWebhookDeliveryHandler)
+
+		// Live event stream, limited to the event types the caller's permissions cover
+		authenticated.GET("/events",
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			eventStreamHandler)
+
+		// WebSocket connection calling the API and receiving events with the credentials it opened with
+		authenticated.GET("/ws",
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			webSocketHandler)
+
+		// Rebalancing endpoints
+		authenticated.POST("/rebalances",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP),
+			rebalanceHandler)
+		authenticated.GET("/rebalances",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			listRebalancesHandler)
+		authenticated.GET("/rebalances/:id",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			rebalanceStatusHandler)
+
+		// Split payment endpoints
+		authenticated.POST("/payments/split",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByBodyField("sender")),
+			splitPaymentHandler)
+		authenticated.GET("/payments/split/:id",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			splitStatusHandler)
+
+		// Keysend payment endpoint
+		authenticated.POST("/keysend",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByBodyField("sender")),
+			keysendPaymentHandler)
+
+		// EUR to msat conversion endpoint
+		authenticated.GET("/convert/eur-to-msats",
+			middleware.RequirePermission(middleware.PermissionConvert),
+			rateLimit("convert", middleware.ByPrincipal, middleware.ByClientIP),
+			euroToMsatsHandler)
+
+		// BOLT11 decode endpoint
+		authenticated.POST("/decode",
+			middleware.RequirePermission(middleware.PermissionConvert),
+			rateLimit("convert", middleware.ByPrincipal, middleware.ByClientIP),
+			decodeHandler)
+
+		// External invoice payment endpoint
+		authenticated.POST("/wallets/:id/pay-invoice",
+			middleware.RequirePermission(middleware.PermissionPayments),
+			rateLimit("payment", middleware.ByPrincipal, middleware.ByClientIP, middleware.ByPathParam("sender", "id")),
+			payInvoiceHandler)
+
+		// Invoice creation endpoint
+		authenticated.POST("/wallets/:id/invoices",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("invoices", middleware.ByPrincipal, middleware.ByClientIP),
+			createInvoiceHandler)
+
+		// LNURL-withdraw endpoint
+		authenticated.POST("/wallets/:id/lnurl-withdraw",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("invoices", middleware.ByPrincipal, middleware.ByClientIP),
+			lnurlWithdrawHandler)
+
+		// Invoice status endpoint
+		authenticated.GET("/wallets/:id/invoices/:payment_hash",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			invoiceStatusHandler)
+
+		// Invoice QR code endpoints
+		authenticated.GET("/wallets/:id/invoices/:payment_hash/qr.png",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			invoiceQRPNGHandler)
+		authenticated.GET("/wallets/:id/invoices/:payment_hash/qr.svg",
+			middleware.RequirePermission(middleware.PermissionInvoices),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			invoiceQRSVGHandler)
+
+		// Wallet budget endpoint
+		authenticated.GET("/wallets/:id/budget",
+			middleware.RequirePermission(middleware.PermissionWallets),
+			rateLimit("wallets", middleware.ByPrincipal, middleware.ByClientIP),
+			walletBudgetHandler)
+	}
+
+	// Print registered routes for debugging
+	log.Println("Registered routes:")
+	for _, route := range router.Routes() {
+		log.Printf("%s %s", route.Method, route.Path)
+	}
+
+	return router, nil
+}
+
+// dataDir returns the directory persistent data is stored in
+func dataDir() string {
+	if dir := wallet.LoadSetting("NWC_DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "data"
+}
+
+// loadInvoicePollInterval returns how often open invoices are checked,
+// set by NWC_INVOICE_POLL_INTERVAL (15s by default)
+func loadInvoicePollInterval() (time.Duration, error) {
+	value := wallet.LoadSetting("NWC_INVOICE_POLL_INTERVAL")
+	if value == "" {
+		return 15 * time.Second, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid NWC_INVOICE_POLL_INTERVAL %q", value)
+	}
+	return interval, nil
+}
+
+// loadLedgerArchiver keeps NWC_LEDGER_RETENTION_DAYS (90 by default, 0 keeps every entry)
+// of payments in the ledger, archiving older ones once a day. The retention must cover
+// the longest budget window, a calendar month, so budgets keep counting every payment.
+func loadLedgerArchiver() (*ledger.Archiver, error) {
+	days := 90
+	if value := wallet.LoadSetting("NWC_LEDGER_RETENTION_DAYS"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 || (days > 0 && days < 32) {
+			return nil, fmt.Errorf("invalid NWC_LEDGER_RETENTION_DAYS %q, expected 0 or at least 32", value)
+		}
+	}
+
+	return &ledger.Archiver{
+		Ledger:    paymentLedger,
+		Retention: time.Duration(days) * 24 * time.Hour,
+		Interval:  24 * time.Hour,
+	}, nil
+}
+
+// loadEventLog opens the log of the last NWC_EVENT_LOG_SIZE events (1000 by default)
+// that event streams resume from
+func loadEventLog() (*events.Log, error) {
+	size := 1000
+	if value := wallet.LoadSetting("NWC_EVENT_LOG_SIZE"); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid NWC_EVENT_LOG_SIZE %q", value)
+		}
+	}
+
+	history, err := events.OpenLog(dataDir(), size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	return history, nil
+}
+
+// loadPaymentQuoteTTL returns how long the invoice of a hosted payment request is
+// valid before it is quoted again, set by NWC_PAYMENT_REQUEST_QUOTE_TTL (10m by default)
+func loadPaymentQuoteTTL() (time.Duration, error) {
+	value := wallet.LoadSetting("NWC_PAYMENT_REQUEST_QUOTE_TTL")
+	if value == "" {
+		return 10 * time.Minute, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 30*time.Second {
+		return 0, fmt.Errorf("invalid NWC_PAYMENT_REQUEST_QUOTE_TTL %q, must be at least 30s", value)
+	}
+	return ttl, nil
+}
+
+// loadRetryPolicy reads how often failed payments are retried from NWC_PAYMENT_RETRIES
+// and how long to wait before the first retry from NWC_PAYMENT_RETRY_DELAY
+func loadRetryPolicy() (retry.Policy, error) {
+	policy := retry.Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+	if value := wallet.LoadSetting("NWC_PAYMENT_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return policy, fmt.Errorf("invalid NWC_PAYMENT_RETRIES %q", value)
+		}
+		policy.MaxAttempts = retries + 1
+	}
+
+	if value := wallet.LoadSetting("NWC_PAYMENT_RETRY_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			return policy, fmt.Errorf("invalid NWC_PAYMENT_RETRY_DELAY %q", value)
+		}
+		policy.BaseDelay = delay
+	}
+
+	return policy, nil
+}
+
+// loadRebalancer configures the rebalancer from the wallet ranges in the wallet config file.
+// NWC_REBALANCE_INTERVAL turns on periodic runs, which NWC_REBALANCE_DRY_RUN limits to logging
+// their plan. A run makes at most NWC_REBALANCE_MAX_TRANSFERS transfers (10 by default) moving
+// at most NWC_REBALANCE_MAX_MSATS in total, none smaller than NWC_REBALANCE_MIN_TRANSFER_MSATS (1000 by default).
+func loadRebalancer() (*rebalance.Rebalancer, error) {
+	runs, err := rebalance.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rebalance store: %w", err)
+	}
+
+	r := &rebalance.Rebalancer{
+		Store:     runs,
+		Ranges:    make(map[string]wallet.RebalanceRange),
+		Balance:   walletBalance,
+		Pay:       payRebalance,
+		ErrorCode: func(err error) string { return toAPIError(err).Code },
+		Limits:    rebalance.Limits{MaxTransfers: 10, MinTransferMsats: 1_000},
+		DryRun:    wallet.LoadSetting("NWC_REBALANCE_DRY_RUN") == "true",
+	}
+
+	for walletID, config := range walletConfigs {
+		if config.Rebalance.IsZero() {
+			continue
+		}
+		if err := config.Rebalance.Validate(); err != nil {
+			return nil, fmt.Errorf("wallet %s: %w", walletID, err)
+		}
+		if _, ok := walletURIs[walletID]; !ok {
+			return nil, fmt.Errorf("wallet %s has a rebalance range but is not configured", walletID)
+		}
+		r.Ranges[walletID] = config.Rebalance
+	}
+
+	if value := wallet.LoadSetting("NWC_REBALANCE_INTERVAL"); value != "" {
+		r.Interval, err = time.ParseDuration(value)
+		if err != nil || r.Interval <= 0 {
+			return nil, fmt.Errorf("invalid NWC_REBALANCE_INTERVAL %q", value)
+		}
+	}
+	if value := wallet.LoadSetting("NWC_REBALANCE_MAX_TRANSFERS"); value != "" {
+		r.Limits.MaxTransfers, err = strconv.Atoi(value)
+		if err != nil || r.Limits.MaxTransfers <= 0 {
+			return nil, fmt.Errorf("invalid NWC_REBALANCE_MAX_TRANSFERS %q", value)
+		}
+	}
+	if value := wallet.LoadSetting("NWC_REBALANCE_MAX_MSATS"); value != "" {
+		r.Limits.MaxRunMsats, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || r.Limits.MaxRunMsats < 0 {
+			return nil, fmt.Errorf("invalid NWC_REBALANCE_MAX_MSATS %q", value)
+		}
+	}
+	if value := wallet.LoadSetting("NWC_REBALANCE_MIN_TRANSFER_MSATS"); value != "" {
+		r.Limits.MinTransferMsats, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || r.Limits.MinTransferMsats < 0 {
+			return nil, fmt.Errorf("invalid NWC_REBALANCE_MIN_TRANSFER_MSATS %q", value)
+		}
+	}
+
+	if r.Interval > 0 && len(r.Ranges) > 0 {
+		log.Printf("Rebalancing %d wallets every %s (dry run: %t)", len(r.Ranges), r.Interval, r.DryRun)
+	}
+
+	return r, nil
+}
+
+// loadWebhookDispatcher configures webhook deliveries. A failed delivery is tried up to
+// NWC_WEBHOOK_MAX_ATTEMPTS times (8 by default), waiting NWC_WEBHOOK_RETRY_DELAY (30s by default)
+// after the first failure and twice as long after each further one, up to an hour.
+// NWC_WEBHOOK_TIMEOUT (10s by default) limits how long an endpoint may take to answer.
+func loadWebhookDispatcher() (*webhooks.Dispatcher, error) {
+	hooks, err := webhooks.Open(dataDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook store: %w", err)
+	}
+
+	d := &webhooks.Dispatcher{
+		Store:    hooks,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Policy:   retry.Policy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: time.Hour},
+		Interval: 5 * time.Second,
+	}
+
+	if value := wallet.LoadSetting("NWC_WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		d.Policy.MaxAttempts, err = strconv.Atoi(value)
+		if err != nil || d.Policy.MaxAttempts <= 0 {
+			return nil, fmt.Errorf("invalid NWC_WEBHOOK_MAX_ATTEMPTS %q", value)
+		}
+	}
+	if value := wallet.LoadSetting("NWC_WEBHOOK_RETRY_DELAY"); value != "" {
+		d.Policy.BaseDelay, err = time.ParseDuration(value)
+		if err != nil || d.Policy.BaseDelay <= 0 {
+			return nil, fmt.Errorf("invalid NWC_WEBHOOK_RETRY_DELAY %q", value)
+		}
+	}
+	if value := wallet.LoadSetting("NWC_WEBHOOK_TIMEOUT"); value != "" {
+		d.Client.Timeout, err = time.ParseDuration(value)
+		if err != nil || d.Client.Timeout <= 0 {
+			return nil, fmt.Errorf("invalid NWC_WEBHOOK_TIMEOUT %q", value)
+		}
+	}
+
+	return d, nil
+}
+
+// loadWalletMonitor configures the checks of every wallet, run every
+// NWC_WALLET_CHECK_INTERVAL (1m by default, 0 turns them off), with the
+// low balance thresholds from the wallet config file
+func loadWalletMonitor() (*monitor.Monitor, error) {
+	m := &monitor.Monitor{
+		LowBalance: make(map[string]int64),
+		Balance:    walletBalance,
+		Events:     eventBus,
+		Interval:   time.Minute,
+	}
+
+	for walletID := range walletURIs {
+		m.Wallets = append(m.Wallets, walletID)
+	}
+	for walletID, config := range walletConfigs {
+		if config.Alerts.LowBalanceMsats < 0 {
+			return nil, fmt.Errorf("wallet %s: alerts low_balance_msats must not be negative", walletID)
+		}
+		if config.Alerts.LowBalanceMsats > 0 {
+			m.LowBalance[walletID] = config.Alerts.LowBalanceMsats
+		}
+	}
+
+	if value := wallet.LoadSetting("NWC_WALLET_CHECK_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid NWC_WALLET_CHECK_INTERVAL %q", value)
+		}
+		m.Interval = interval
+	}
+
+	return m, nil
+}
+
+// loadNotificationListener subscribes to the payment notifications of every wallet,
+// unless NWC_WALLET_NOTIFICATIONS is false
+func loadNotificationListener() *notifications.Listener {
+	l := &notifications.Listener{
+		Listen: listenNotifications,
+		Handle: handleNotification,
+		Retry:  retry.Policy{BaseDelay: 5 * time.Second, MaxDelay: 5 * time.Minute},
+	}
+
+	if wallet.LoadSetting("NWC_WALLET_NOTIFICATIONS") != "false" {
+		for walletID := range walletURIs {
+			l.Wallets = append(l.Wallets, walletID)
+		}
+	}
+
+	return l
+}
+
+// loadLightningAddresses maps the Lightning Address names of the configured
+// wallets to their wallet IDs
+func loadLightningAddresses() (map[string]string, error) {
+	addresses := make(map[string]string)
+	for walletID := range walletURIs {
+		config := walletConfigs[walletID].LightningAddress
+		if config.Disabled {
+			continue
+		}
+
+		name := config.AddressName(walletID)
+		if !validAddressName(name) {
+			return nil, fmt.Errorf("invalid Lightning Address name %q for wallet %s", name, walletID)
+		}
+		if other, taken := addresses[name]; taken {
+			return nil, fmt.Errorf("wallets %s and %s share the Lightning Address name %q", other, walletID, name)
+		}
+		addresses[name] = walletID
+
+		log.Printf("Wallet %s receives payments at %s@<domain>", walletID, name)
+	}
+
+	return addresses, nil
+}
+
+// validAddressName reports whether name only uses the characters LUD-16 allows
+func validAddressName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, char := range name {
+		if !(char >= 'a' && char <= 'z' || char >= '0' && char <= '9' || strings.ContainsRune("-_.+", char)) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadAuthSchemes builds the authentication schemes enabled by configuration.
+// Plain API keys are always accepted; HMAC signing is enabled by NWC_HMAC_KEYS
+// and NIP-98 Nostr authentication by NWC_NIP98_PUBKEYS.
+func loadAuthSchemes() ([]middleware.AuthScheme, error) {
+	// Log API security information
+	apiKey, _ := wallet.LoadAPIKey()
+	if apiKey == "" {
+		log.Println("WARNING: NWC_API_KEY is not set, API key authentication will reject all requests.")
+	} else {
+		log.Println("API Key authentication is enabled.")
+	}
+
+	// Requests made over a WebSocket connection carry the principal it was opened with
+	schemes := []middleware.AuthScheme{middleware.ContextAuth(), middleware.APIKeyAuth(apiKey)}
+
+	hmacKeys, err := middleware.ParseHMACKeys(wallet.LoadSetting("NWC_HMAC_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load HMAC keys: %w", err)
+	}
+	if len(hmacKeys) > 0 {
+		maxSkew := 5 * time.Minute
+		if value := wallet.LoadSetting("NWC_HMAC_MAX_SKEW"); value != "" {
+			maxSkew, err = time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid NWC_HMAC_MAX_SKEW: %w", err)
+			}
+		}
+
+		schemes = append(schemes, middleware.HMACAuth(middleware.HMACConfig{
+			Secrets: hmacKeys,
+			MaxSkew: maxSkew,
+		}))
+		log.Printf("HMAC request signing is enabled for %d key(s).", len(hmacKeys))
+	}
+
+	nip98Permissions, err := middleware.ParseNIP98Permissions(wallet.LoadSetting("NWC_NIP98_PUBKEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load NIP-98 pubkeys: %w", err)
+	}
+	if len(nip98Permissions) > 0 {
+		schemes = append(schemes, middleware.NIP98Auth(middleware.NIP98Config{
+			Permissions: nip98Permissions,
+			BaseURL:     wallet.LoadSetting("NWC_PUBLIC_URL"),
+		}))
+		log.Printf("NIP-98 authentication is enabled for %d pubkey(s).", len(nip98Permissions))
+	}
+
+	return schemes, nil
+}
+
+// defaultRateLimits are the limits of each group of routes used when NWC_RATE_LIMIT_<GROUP> is not set
+var defaultRateLimits = map[string]string{
+	"payment":  "10/m",
+	"convert":  "60/m",
+	"wallets":  "60/m",
+	"invoices": "30/m",
+	"lnurlp":   "60/m",
+	"pay":      "60/m",
+}
+
+// loadRateLimits loads the rate limits of each group of routes and returns a function
+// building the rate limiting middleware for a route of a group. Every route keeps its own buckets.
+// Buckets are kept in memory unless NWC_RATE_LIMIT_REDIS points to a Redis compatible server.
+func loadRateLimits() (func(group string, keys ...middleware.RateLimitKey) gin.HandlerFunc, error) {
+	var store middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if redisURL := wallet.LoadSetting("NWC_RATE_LIMIT_REDIS"); redisURL != "" {
+		redisStore, err := middleware.NewRedisRateLimitStore(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure rate limit store: %w", err)
+		}
+		store = redisStore
+		log.Println("Rate limits are stored in Redis.")
+	}
+
+	limits := make(map[string]middleware.RateLimit)
+	for group, value := range defaultRateLimits {
+		if setting := wallet.LoadSetting("NWC_RATE_LIMIT_" + strings.ToUpper(group)); setting != "" {
+			value = setting
+		}
+
+		limit, err := middleware.ParseRateLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %s: %w", group, err)
+		}
+		limits[group] = limit
+	}
+
+	return func(group string, keys ...middleware.RateLimitKey) gin.HandlerFunc {
+		limit, ok := limits[group]
+		if !ok {
+			panic(fmt.Sprintf("no rate limit configured for group %q", group))
+		}
+		return middleware.RateLimitMiddleware(store, limit, keys...)
+	}, nil
+}
+
+// loadTrustedProxies returns the proxies, as IP addresses or CIDR ranges in NWC_TRUSTED_PROXIES,
+// whose X-Forwarded-For and X-Real-IP headers are believed. By default none are, so the client
+// IP that rate limits use is the address of the connection and cannot be set by the client.
+func loadTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(wallet.LoadSetting("NWC_TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
 }
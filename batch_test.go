@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []BatchPaymentItem
+		wantErr string
+	}{
+		{
+			name: "columns in any order",
+			csv:  "notes,Euro_Amount, recipient\nrent,12.50,WALLET_A\n\"a, b\", 3 ,WALLET_B\n",
+			want: []BatchPaymentItem{{Recipient: "WALLET_A", EuroAmount: 12.5}, {Recipient: "WALLET_B", EuroAmount: 3}},
+		},
+		{
+			name: "header only",
+			csv:  "recipient,euro_amount\n",
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: "failed to read CSV header",
+		},
+		{
+			name:    "missing recipient column",
+			csv:     "wallet,euro_amount\nWALLET_A,1\n",
+			wantErr: "must name the recipient and euro_amount columns",
+		},
+		{
+			name:    "missing amount column",
+			csv:     "recipient,amount\nWALLET_A,1\n",
+			wantErr: "must name the recipient and euro_amount columns",
+		},
+		{
+			name:    "too few columns",
+			csv:     "recipient,notes,euro_amount\nWALLET_A,1\n",
+			wantErr: "line 2 has too few columns",
+		},
+		{
+			name:    "bad amount",
+			csv:     "recipient,euro_amount\nWALLET_A,1\nWALLET_B,1,50\nWALLET_C,ten\n",
+			wantErr: `line 4 has an invalid euro_amount "ten"`,
+		},
+		{
+			name:    "empty amount",
+			csv:     "recipient,euro_amount\nWALLET_A,\n",
+			wantErr: "line 2 has an invalid euro_amount",
+		},
+		{
+			name:    "unterminated quote",
+			csv:     "recipient,euro_amount\n\"WALLET_A,1\n",
+			wantErr: "failed to read CSV",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := parseBatchCSV(strings.NewReader(test.csv))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("parseBatchCSV() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBatchCSV() error = %v", err)
+			}
+			if !reflect.DeepEqual(items, test.want) {
+				t.Fatalf("parseBatchCSV() = %+v, want %+v", items, test.want)
+			}
+		})
+	}
+}
+
+func TestParseBatchCSVTooLarge(t *testing.T) {
+	// The last row is cut off by the limit, which would read 125.50 as 12
+	var csv strings.Builder
+	csv.WriteString("recipient,euro_amount,notes\n")
+	for csv.Len() < maxBatchCSVSize-30 {
+		csv.WriteString("WALLET_A,1," + strings.Repeat("x", 100) + "\n")
+	}
+	csv.WriteString("WALLET_B,125.50,last row\n")
+
+	_, err := parseBatchCSV(strings.NewReader(csv.String()))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("parseBatchCSV() error = %v, want 413", err)
+	}
+}
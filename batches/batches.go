@@ -0,0 +1,196 @@
+// Package batches keeps track of batch payments, which pay many recipients
+// from one wallet and are reported on as a whole
+package batches
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Batch kinds
+const (
+	KindBatch = "batch"
+	// KindSplit is one payment divided across several recipients
+	KindSplit = "split"
+)
+
+// Batch statuses
+const (
+	StatusRunning         = "running"
+	StatusCompleted       = "completed"
+	StatusPartiallyFailed = "partially_failed"
+	StatusFailed          = "failed"
+)
+
+// Item statuses
+const (
+	ItemPending   = "pending"
+	ItemSucceeded = "succeeded"
+	ItemFailed    = "failed"
+)
+
+// Batch is a set of payments from one sender.
+// MultiPay is set when the invoices were paid with a single multi_pay_invoice request.
+// A split also records the total that was divided across its items.
+type Batch struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Sender      string    `json:"sender"`
+	Status      string    `json:"status"`
+	MultiPay    bool      `json:"multi_pay"`
+	EuroAmount  float64   `json:"euro_amount,omitempty"`
+	AmountMsats int64     `json:"amount_msats,omitempty"`
+	Items       []Item    `json:"items"`
+	Summary     Summary   `json:"summary"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Item is a single payment of a batch
+type Item struct {
+	Index       int     `json:"index"`
+	Recipient   string  `json:"recipient"`
+	Percent     float64 `json:"percent,omitempty"`
+	EuroAmount  float64 `json:"euro_amount"`
+	AmountMsats int64   `json:"amount_msats"`
+	Status      string  `json:"status"`
+	LedgerID    string  `json:"ledger_id,omitempty"`
+	PaymentHash string  `json:"payment_hash,omitempty"`
+	Preimage    string  `json:"preimage,omitempty"`
+	FeesPaid    int64   `json:"fees_paid"`
+	Error       string  `json:"error,omitempty"`
+	// ErrorCode is the API error code of Error
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Summary adds up the items of a batch
+type Summary struct {
+	Total       int     `json:"total"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	Pending     int     `json:"pending"`
+	AmountMsats int64   `json:"amount_msats"`
+	FeesMsats   int64   `json:"fees_msats"`
+	EuroAmount  float64 `json:"euro_amount"`
+}
+
+// Summarize adds up items; amounts only include the items paid
+func Summarize(items []Item) Summary {
+	summary := Summary{Total: len(items)}
+	for _, item := range items {
+		switch item.Status {
+		case ItemSucceeded:
+			summary.Succeeded++
+			summary.AmountMsats += item.AmountMsats
+			summary.FeesMsats += item.FeesPaid
+			summary.EuroAmount += item.EuroAmount
+		case ItemFailed:
+			summary.Failed++
+		default:
+			summary.Pending++
+		}
+	}
+	// Keep float sums from showing rounding noise
+	summary.EuroAmount = math.Round(summary.EuroAmount*1e8) / 1e8
+	return summary
+}
+
+// Store is the persistent list of batches
+type Store struct {
+	records *store.Collection[Batch]
+}
+
+// Open loads the batches stored in dir.
+// Batches still running when the service stopped are finished with their pending items failed,
+// since their payments can no longer be followed up.
+func Open(dir string) (*Store, error) {
+	records, err := store.Open[Batch](dir, "batches")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{records: records}
+	for _, batch := range records.List() {
+		if batch.Status != StatusRunning {
+			continue
+		}
+		if _, err := records.Update(batch.ID, func(batch *Batch) error {
+			batch.Items = slices.Clone(batch.Items)
+			for i := range batch.Items {
+				if batch.Items[i].Status == ItemPending {
+					batch.Items[i].Status = ItemFailed
+					batch.Items[i].Error = "interrupted by a restart, check the ledger entry before retrying"
+				}
+			}
+			finish(batch)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Create stores a new running batch with all items pending
+func (s *Store) Create(batch Batch) (Batch, error) {
+	batch.ID = store.NewID()
+	if batch.Kind == "" {
+		batch.Kind = KindBatch
+	}
+	batch.Status = StatusRunning
+	for i := range batch.Items {
+		batch.Items[i].Index = i
+		batch.Items[i].Status = ItemPending
+	}
+	batch.Summary = Summarize(batch.Items)
+	batch.CreatedAt = time.Now().UTC()
+	batch.UpdatedAt = batch.CreatedAt
+
+	return batch, s.records.Put(batch.ID, batch)
+}
+
+// Get returns the batch with the given ID
+func (s *Store) Get(id string) (Batch, bool) {
+	return s.records.Get(id)
+}
+
+// UpdateItem stores the outcome of one item, so progress can be followed while the batch runs
+func (s *Store) UpdateItem(id string, item Item) (Batch, error) {
+	return s.records.Update(id, func(batch *Batch) error {
+		if item.Index < 0 || item.Index >= len(batch.Items) {
+			return store.ErrNotFound
+		}
+		// Copy the items so batches handed out by Get are never changed underneath their readers
+		batch.Items = slices.Clone(batch.Items)
+		batch.Items[item.Index] = item
+		batch.Summary = Summarize(batch.Items)
+		batch.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Finish sets the final status of a batch from the outcome of its items
+func (s *Store) Finish(id string) (Batch, error) {
+	return s.records.Update(id, func(batch *Batch) error {
+		finish(batch)
+		return nil
+	})
+}
+
+// finish derives the status of a batch whose items are all done
+func finish(batch *Batch) {
+	batch.Summary = Summarize(batch.Items)
+	switch {
+	case batch.Summary.Failed == 0:
+		batch.Status = StatusCompleted
+	case batch.Summary.Succeeded == 0:
+		batch.Status = StatusFailed
+	default:
+		batch.Status = StatusPartiallyFailed
+	}
+	batch.UpdatedAt = time.Now().UTC()
+}
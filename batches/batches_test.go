@@ -0,0 +1,123 @@
+package batches
+
+import (
+	"errors"
+	"testing"
+
+	"nwc_app/store"
+)
+
+func TestSummarize(t *testing.T) {
+	items := []Item{
+		{Status: ItemSucceeded, AmountMsats: 1000, FeesPaid: 3, EuroAmount: 0.1},
+		{Status: ItemSucceeded, AmountMsats: 2000, FeesPaid: 4, EuroAmount: 0.2},
+		{Status: ItemFailed, AmountMsats: 4000, FeesPaid: 5, EuroAmount: 0.4},
+		{Status: ItemPending, AmountMsats: 8000, EuroAmount: 0.8},
+	}
+
+	got := Summarize(items)
+	want := Summary{Total: 4, Succeeded: 2, Failed: 1, Pending: 1, AmountMsats: 3000, FeesMsats: 7, EuroAmount: 0.3}
+	if got != want {
+		t.Fatalf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{"all succeeded", []string{ItemSucceeded, ItemSucceeded}, StatusCompleted},
+		{"some failed", []string{ItemSucceeded, ItemFailed}, StatusPartiallyFailed},
+		{"all failed", []string{ItemFailed, ItemFailed}, StatusFailed},
+	}
+
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			batch, err := s.Create(Batch{Sender: "WALLET", Items: make([]Item, len(test.statuses))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batch.Kind != KindBatch || batch.Status != StatusRunning || batch.Summary.Pending != len(test.statuses) {
+				t.Fatalf("created batch = %+v, want a running batch with all items pending", batch)
+			}
+
+			for i, status := range test.statuses {
+				if _, err := s.UpdateItem(batch.ID, Item{Index: i, Status: status, AmountMsats: 1000}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			batch, err = s.Finish(batch.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batch.Status != test.want || batch.Summary.Pending != 0 {
+				t.Fatalf("finished batch = %+v, want %s", batch, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := s.Create(Batch{Sender: "WALLET", Items: []Item{{Recipient: "A"}}})
+
+	if _, err := s.UpdateItem(created.ID, Item{Index: 1, Status: ItemSucceeded}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("UpdateItem() of a missing item error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.UpdateItem(created.ID, Item{Index: 0, Recipient: "A", Status: ItemSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	// Batches already handed out keep the items they were read with
+	if created.Items[0].Status != ItemPending {
+		t.Fatalf("created batch item = %+v, want it unchanged", created.Items[0])
+	}
+	if batch, _ := s.Get(created.ID); batch.Items[0].Status != ItemSucceeded || batch.Summary.Succeeded != 1 {
+		t.Fatalf("batch = %+v, want the item succeeded", batch)
+	}
+}
+
+func TestOpenFinishesInterruptedBatches(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	running, _ := s.Create(Batch{Sender: "WALLET", Items: make([]Item, 3)})
+	s.UpdateItem(running.ID, Item{Index: 0, Status: ItemSucceeded, AmountMsats: 1000})
+	s.UpdateItem(running.ID, Item{Index: 1, Status: ItemFailed, Error: "no route"})
+	finished, _ := s.Create(Batch{Sender: "WALLET", Items: make([]Item, 1)})
+	s.UpdateItem(finished.ID, Item{Index: 0, Status: ItemSucceeded})
+	finished, _ = s.Finish(finished.ID)
+	s.records.Close()
+
+	// The service stops while the first batch is still paying its last item
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, _ := reopened.Get(running.ID)
+	if batch.Status != StatusPartiallyFailed || batch.Summary.Pending != 0 || batch.Summary.Failed != 2 {
+		t.Fatalf("interrupted batch = %+v, want it partially failed", batch)
+	}
+	if batch.Items[0].Status != ItemSucceeded || batch.Items[1].Error != "no route" {
+		t.Fatalf("items = %+v, want the finished items unchanged", batch.Items)
+	}
+	if batch.Items[2].Status != ItemFailed || batch.Items[2].Error == "" {
+		t.Fatalf("pending item = %+v, want it failed with an explanation", batch.Items[2])
+	}
+
+	if batch, _ := reopened.Get(finished.ID); batch.Status != StatusCompleted || !batch.UpdatedAt.Equal(finished.UpdatedAt) {
+		t.Fatalf("finished batch = %+v, want it untouched", batch)
+	}
+}
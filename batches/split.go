@@ -0,0 +1,73 @@
+package batches
+
+import (
+	"fmt"
+	"math"
+)
+
+// Share is one recipient's part of a split payment, either a fixed amount or a percentage
+type Share struct {
+	FixedMsats int64
+	Percent    float64
+}
+
+// SplitAmount divides totalMsats across shares. Fixed shares are taken first and the
+// percentages divide what remains, so they must add up to 100; without percentage
+// shares the fixed amounts must add up to the total. Msats lost to rounding go to the
+// first percentage share so the parts always add up to the total.
+func SplitAmount(totalMsats int64, shares []Share) ([]int64, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("a split needs at least one share")
+	}
+
+	parts := make([]int64, len(shares))
+	remaining := totalMsats
+	var percentTotal float64
+	first := -1
+	for i, share := range shares {
+		switch {
+		case share.Percent > 0 && share.FixedMsats > 0:
+			return nil, fmt.Errorf("share %d has both a percentage and a fixed amount", i)
+		case share.Percent > 0:
+			percentTotal += share.Percent
+			if first < 0 {
+				first = i
+			}
+		case share.FixedMsats > 0:
+			parts[i] = share.FixedMsats
+			remaining -= share.FixedMsats
+		default:
+			return nil, fmt.Errorf("share %d needs a positive percentage or fixed amount", i)
+		}
+	}
+
+	if remaining < 0 {
+		return nil, fmt.Errorf("fixed shares add up to %d msats, more than the total of %d msats", totalMsats-remaining, totalMsats)
+	}
+	if first < 0 {
+		if remaining != 0 {
+			return nil, fmt.Errorf("fixed shares add up to %d msats instead of the total of %d msats", totalMsats-remaining, totalMsats)
+		}
+		return parts, nil
+	}
+	if math.Abs(percentTotal-100) > 1e-9 {
+		return nil, fmt.Errorf("percentage shares add up to %g%% instead of 100%%", percentTotal)
+	}
+
+	divided := int64(0)
+	for i, share := range shares {
+		if share.Percent > 0 {
+			parts[i] = int64(math.Floor(float64(remaining) * share.Percent / 100))
+			divided += parts[i]
+		}
+	}
+	parts[first] += remaining - divided
+
+	for i, part := range parts {
+		if part <= 0 {
+			return nil, fmt.Errorf("share %d comes to %d msats, the total is too small to split", i, part)
+		}
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,62 @@
+package batches
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitAmount(t *testing.T) {
+	third := 100.0 / 3
+
+	tests := []struct {
+		name       string
+		totalMsats int64
+		shares     []Share
+		want       []int64
+		wantErr    bool
+	}{
+		{"even percentages", 1000, []Share{{Percent: 50}, {Percent: 50}}, []int64{500, 500}, false},
+		// The msat lost to rounding goes to the first percentage share
+		{"thirds", 1000, []Share{{Percent: third}, {Percent: third}, {Percent: third}}, []int64{334, 333, 333}, false},
+		{"thirds of a few msats", 10, []Share{{Percent: third}, {Percent: third}, {Percent: third}}, []int64{4, 3, 3}, false},
+		{"rounding after a fixed share", 1001, []Share{{FixedMsats: 1}, {Percent: 70}, {Percent: 30}}, []int64{1, 700, 300}, false},
+		{"remainder to the first percentage share", 1003, []Share{{FixedMsats: 500}, {Percent: 25}, {Percent: 75}}, []int64{500, 126, 377}, false},
+		{"fixed shares first", 10000, []Share{{Percent: 50}, {FixedMsats: 1000}, {Percent: 50}}, []int64{4500, 1000, 4500}, false},
+		{"fixed shares only", 1000, []Share{{FixedMsats: 400}, {FixedMsats: 600}}, []int64{400, 600}, false},
+		{"fixed shares short of the total", 1000, []Share{{FixedMsats: 400}, {FixedMsats: 500}}, nil, true},
+		{"fixed shares over the total", 1000, []Share{{FixedMsats: 800}, {Percent: 100}, {FixedMsats: 300}}, nil, true},
+		{"nothing left for the percentages", 1000, []Share{{FixedMsats: 1000}, {Percent: 100}}, nil, true},
+		{"percentages under 100", 1000, []Share{{Percent: 50}, {Percent: 49}}, nil, true},
+		{"percentages over 100", 1000, []Share{{Percent: 50}, {Percent: 51}}, nil, true},
+		{"too small to split", 2, []Share{{Percent: third}, {Percent: third}, {Percent: third}}, nil, true},
+		{"percentage and fixed amount", 1000, []Share{{Percent: 100, FixedMsats: 1}}, nil, true},
+		{"empty share", 1000, []Share{{Percent: 100}, {}}, nil, true},
+		{"no shares", 1000, nil, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := SplitAmount(test.totalMsats, test.shares)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("SplitAmount() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SplitAmount() error = %v", err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("SplitAmount() = %v, want %v", got, test.want)
+			}
+
+			var sum int64
+			for _, part := range got {
+				sum += part
+			}
+			if sum != test.totalMsats {
+				t.Fatalf("parts add up to %d, want %d", sum, test.totalMsats)
+			}
+		})
+	}
+}
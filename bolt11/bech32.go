@@ -0,0 +1,164 @@
+package bolt11
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// charset is the bech32 alphabet
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// decodeBech32 splits a bech32 string into its human readable part and 5 bit data words,
+// verifying the checksum. Unlike BIP-173 there is no length limit, as invoices are long.
+func decodeBech32(value string) (string, []byte, error) {
+	separator := strings.LastIndexByte(value, '1')
+	if separator < 1 || separator+7 > len(value) {
+		return "", nil, errors.New("invalid bech32 string")
+	}
+
+	hrp := value[:separator]
+	words := make([]byte, 0, len(value)-separator-1)
+	for _, char := range value[separator+1:] {
+		word := strings.IndexRune(charset, char)
+		if word < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", char)
+		}
+		words = append(words, byte(word))
+	}
+
+	if polymod(append(expandHRP(hrp), words...)) != 1 {
+		return "", nil, errors.New("invalid bech32 checksum")
+	}
+
+	return hrp, words[:len(words)-6], nil
+}
+
+// DecodeBech32 decodes a bech32 string of any length into its human readable part and bytes
+func DecodeBech32(value string) (string, []byte, error) {
+	hrp, words, err := decodeBech32(strings.ToLower(value))
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := wordsToBytes(words, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
+
+// EncodeBech32 encodes bytes as a bech32 string with the given human readable part
+func EncodeBech32(hrp string, data []byte) string {
+	return encodeBech32(hrp, bytesToWords(data))
+}
+
+// encodeBech32 encodes 5 bit words as a bech32 string with the given human readable part
+func encodeBech32(hrp string, words []byte) string {
+	checksum := polymod(append(append(expandHRP(hrp), words...), 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte('1')
+	for _, word := range words {
+		encoded.WriteByte(charset[word])
+	}
+	for i := 0; i < 6; i++ {
+		encoded.WriteByte(charset[(checksum>>uint(5*(5-i)))&31])
+	}
+
+	return encoded.String()
+}
+
+// polymod computes the bech32 checksum
+func polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator[i]
+			}
+		}
+	}
+	return checksum
+}
+
+// expandHRP prepares the human readable part for checksum computation
+func expandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// wordsToBytes regroups 5 bit words into bytes.
+// With pad set the trailing bits are zero padded to a full byte,
+// otherwise they are dropped.
+func wordsToBytes(words []byte, pad bool) ([]byte, error) {
+	var (
+		accumulator uint32
+		bits        uint
+		result      = make([]byte, 0, len(words)*5/8+1)
+	)
+	for _, word := range words {
+		if word > 31 {
+			return nil, errors.New("invalid 5 bit word")
+		}
+		accumulator = accumulator<<5 | uint32(word)
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			result = append(result, byte(accumulator>>bits))
+		}
+	}
+	if pad && bits > 0 {
+		result = append(result, byte(accumulator<<(8-bits)))
+	}
+	return result, nil
+}
+
+// bytesToWords regroups bytes into 5 bit words, zero padding the last word
+func bytesToWords(data []byte) []byte {
+	var (
+		accumulator uint32
+		bits        uint
+		words       = make([]byte, 0, len(data)*8/5+1)
+	)
+	for _, b := range data {
+		accumulator = accumulator<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			words = append(words, byte(accumulator>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		words = append(words, byte(accumulator<<(5-bits))&31)
+	}
+	return words
+}
+
+// wordsToUint reads big endian 5 bit words as an unsigned integer
+func wordsToUint(words []byte) uint64 {
+	var value uint64
+	for _, word := range words {
+		value = value<<5 | uint64(word)
+	}
+	return value
+}
+
+// wordsToHex converts words to bytes and hex encodes them
+func wordsToHex(words []byte) string {
+	data, _ := wordsToBytes(words, false)
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,357 @@
+// Package bolt11 decodes Lightning Network payment requests as specified in BOLT #11
+package bolt11
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Networks an invoice can be issued for
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkSignet  = "signet"
+	NetworkRegtest = "regtest"
+)
+
+// DefaultExpiry applies when an invoice has no expiry field
+const DefaultExpiry = time.Hour
+
+// maxExpiry caps longer expiries, which could not be added to a time otherwise
+const maxExpiry = 100 * 365 * 24 * time.Hour
+
+// maxUintWords is the longest integer field accepted, in 5 bit words, the length of the timestamp
+const maxUintWords = 7
+
+// networkPrefixes maps the currency part of the human readable prefix to a network.
+// Longer prefixes come first so "bcrt" is not mistaken for "bc".
+var networkPrefixes = []struct {
+	prefix  string
+	network string
+}{
+	{"bcrt", NetworkRegtest},
+	{"tbs", NetworkSignet},
+	{"bc", NetworkMainnet},
+	{"tb", NetworkTestnet},
+}
+
+// Field types of tagged fields
+const (
+	fieldPaymentHash     = 1
+	fieldRouteHint       = 3
+	fieldExpiry          = 6
+	fieldFallback        = 9
+	fieldDescription     = 13
+	fieldPaymentSecret   = 16
+	fieldPayee           = 19
+	fieldDescriptionHash = 23
+	fieldMinFinalCLTV    = 24
+	fieldFeatures        = 5
+	fieldMetadata        = 27
+)
+
+// signatureWords is the length of the recoverable signature in 5 bit words
+const signatureWords = 104
+
+// Invoice is a decoded payment request
+type Invoice struct {
+	Raw             string      `json:"-"`
+	Network         string      `json:"network"`
+	AmountMsats     int64       `json:"amount_msats"`
+	CreatedAt       time.Time   `json:"created_at"`
+	Expiry          int64       `json:"expiry"`
+	ExpiresAt       time.Time   `json:"expires_at"`
+	PaymentHash     string      `json:"payment_hash"`
+	PaymentSecret   string      `json:"payment_secret,omitempty"`
+	Payee           string      `json:"payee"`
+	Description     string      `json:"description,omitempty"`
+	DescriptionHash string      `json:"description_hash,omitempty"`
+	MinFinalCLTV    int64       `json:"min_final_cltv_expiry"`
+	RouteHints      []RouteHint `json:"route_hints,omitempty"`
+	Features        []int       `json:"features,omitempty"`
+}
+
+// RouteHint is a private route to the payee
+type RouteHint []HopHint
+
+// HopHint is a single channel of a route hint
+type HopHint struct {
+	PubKey                    string `json:"pubkey"`
+	ShortChannelID            string `json:"short_channel_id"`
+	FeeBaseMsat               uint32 `json:"fee_base_msat"`
+	FeeProportionalMillionths uint32 `json:"fee_proportional_millionths"`
+	CLTVExpiryDelta           uint16 `json:"cltv_expiry_delta"`
+}
+
+// Expired reports whether the invoice can no longer be paid at the given time
+func (i *Invoice) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// Decode parses and verifies a BOLT11 payment request.
+// The "lightning:" URI prefix is accepted.
+func Decode(invoice string) (*Invoice, error) {
+	raw := strings.TrimSpace(invoice)
+	if len(raw) > 10 && strings.EqualFold(raw[:10], "lightning:") {
+		raw = raw[10:]
+	}
+	if raw != strings.ToLower(raw) && raw != strings.ToUpper(raw) {
+		return nil, errors.New("invoice mixes upper and lower case")
+	}
+	raw = strings.ToLower(raw)
+
+	hrp, words, err := decodeBech32(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) < 7+signatureWords {
+		return nil, errors.New("invoice is too short")
+	}
+
+	result := &Invoice{Raw: raw}
+	if err := result.parsePrefix(hrp); err != nil {
+		return nil, err
+	}
+
+	data := words[:len(words)-signatureWords]
+	signature, err := wordsToBytes(words[len(words)-signatureWords:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	result.CreatedAt = time.Unix(int64(wordsToUint(data[:7])), 0).UTC()
+	result.Expiry = int64(DefaultExpiry / time.Second)
+	result.MinFinalCLTV = 18
+
+	if err := result.parseFields(data[7:]); err != nil {
+		return nil, err
+	}
+	result.ExpiresAt = result.CreatedAt.Add(time.Duration(result.Expiry) * time.Second)
+
+	if result.PaymentHash == "" {
+		return nil, errors.New("invoice has no payment hash")
+	}
+	if err := result.verifySignature(hrp, data, signature); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parsePrefix reads the network and amount from the human readable part
+func (i *Invoice) parsePrefix(hrp string) error {
+	if !strings.HasPrefix(hrp, "ln") {
+		return errors.New("invoice does not start with ln")
+	}
+	rest := hrp[2:]
+
+	for _, candidate := range networkPrefixes {
+		if strings.HasPrefix(rest, candidate.prefix) {
+			i.Network = candidate.network
+			rest = rest[len(candidate.prefix):]
+			break
+		}
+	}
+	if i.Network == "" {
+		return fmt.Errorf("unknown invoice network in prefix %q", hrp)
+	}
+
+	if rest == "" {
+		return nil
+	}
+
+	amount, err := parseAmount(rest)
+	if err != nil {
+		return err
+	}
+	i.AmountMsats = amount
+
+	return nil
+}
+
+// parseAmount converts a BOLT11 amount with optional multiplier to millisatoshis
+func parseAmount(value string) (int64, error) {
+	multiplier := value[len(value)-1]
+	digits := value
+	if multiplier >= 'a' && multiplier <= 'z' {
+		digits = value[:len(value)-1]
+	} else {
+		multiplier = 0
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || amount <= 0 || (len(digits) > 1 && digits[0] == '0') {
+		return 0, fmt.Errorf("invalid invoice amount %q", value)
+	}
+
+	// Millisatoshis per unit of each multiplier (1 BTC = 10^11 msat)
+	var unit int64
+	switch multiplier {
+	case 0:
+		unit = 100_000_000_000
+	case 'm':
+		unit = 100_000_000
+	case 'u':
+		unit = 100_000
+	case 'n':
+		unit = 100
+	case 'p':
+		if amount%10 != 0 {
+			return 0, fmt.Errorf("invoice amount %q is not a whole number of millisatoshis", value)
+		}
+		return amount / 10, nil
+	default:
+		return 0, fmt.Errorf("invalid invoice amount multiplier %q", string(multiplier))
+	}
+
+	if amount > math.MaxInt64/unit {
+		return 0, fmt.Errorf("invoice amount %q is too large", value)
+	}
+	return amount * unit, nil
+}
+
+// parseFields reads the tagged fields. Unknown fields are skipped as BOLT11 requires.
+func (i *Invoice) parseFields(words []byte) error {
+	for len(words) > 0 {
+		if len(words) < 3 {
+			return errors.New("truncated invoice field")
+		}
+		fieldType := words[0]
+		length := int(words[1])<<5 | int(words[2])
+		words = words[3:]
+		if len(words) < length {
+			return errors.New("truncated invoice field")
+		}
+		value := words[:length]
+		words = words[length:]
+
+		switch fieldType {
+		case fieldPaymentHash:
+			// Fields with an unexpected length must be skipped
+			if length == 52 && i.PaymentHash == "" {
+				i.PaymentHash = wordsToHex(value)
+			}
+		case fieldPaymentSecret:
+			if length == 52 {
+				i.PaymentSecret = wordsToHex(value)
+			}
+		case fieldDescriptionHash:
+			if length == 52 {
+				i.DescriptionHash = wordsToHex(value)
+			}
+		case fieldPayee:
+			if length == 53 {
+				i.Payee = wordsToHex(value)
+			}
+		case fieldDescription:
+			description, err := wordsToBytes(value, false)
+			if err != nil {
+				return err
+			}
+			i.Description = string(description)
+		case fieldExpiry:
+			if length > maxUintWords {
+				return errors.New("invoice expiry field is too long")
+			}
+			i.Expiry = min(int64(wordsToUint(value)), int64(maxExpiry/time.Second))
+		case fieldMinFinalCLTV:
+			if length > maxUintWords {
+				return errors.New("invoice min_final_cltv_expiry field is too long")
+			}
+			i.MinFinalCLTV = int64(wordsToUint(value))
+		case fieldFeatures:
+			i.Features = featureBits(value)
+		case fieldRouteHint:
+			hint, err := parseRouteHint(value)
+			if err != nil {
+				return err
+			}
+			i.RouteHints = append(i.RouteHints, hint)
+		case fieldFallback, fieldMetadata:
+			// Not needed by this service
+		}
+	}
+
+	return nil
+}
+
+// featureBits lists the feature bits set in a big endian bit field
+func featureBits(words []byte) []int {
+	var bits []int
+	for i := range words {
+		word := words[len(words)-1-i]
+		for bit := 0; bit < 5; bit++ {
+			if word&(1<<bit) != 0 {
+				bits = append(bits, i*5+bit)
+			}
+		}
+	}
+	return bits
+}
+
+// parseRouteHint decodes the hops of an r field
+func parseRouteHint(words []byte) (RouteHint, error) {
+	data, err := wordsToBytes(words, false)
+	if err != nil {
+		return nil, err
+	}
+
+	const hopLength = 33 + 8 + 4 + 4 + 2
+	if len(data)%hopLength != 0 {
+		return nil, errors.New("invalid route hint length")
+	}
+
+	var hint RouteHint
+	for ; len(data) > 0; data = data[hopLength:] {
+		channel := binary.BigEndian.Uint64(data[33:41])
+		hint = append(hint, HopHint{
+			PubKey:                    hex.EncodeToString(data[:33]),
+			ShortChannelID:            fmt.Sprintf("%dx%dx%d", channel>>40, (channel>>16)&0xffffff, channel&0xffff),
+			FeeBaseMsat:               binary.BigEndian.Uint32(data[41:45]),
+			FeeProportionalMillionths: binary.BigEndian.Uint32(data[45:49]),
+			CLTVExpiryDelta:           binary.BigEndian.Uint16(data[49:51]),
+		})
+	}
+
+	return hint, nil
+}
+
+// verifySignature recovers the signing key and checks it against the payee field
+func (i *Invoice) verifySignature(hrp string, data []byte, signature []byte) error {
+	if len(signature) != 65 || signature[64] > 3 {
+		return errors.New("invalid invoice signature")
+	}
+
+	message, err := wordsToBytes(data, true)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(append([]byte(hrp), message...))
+
+	// RecoverCompact expects the recovery flag first, offset by 27 + 4 for compressed keys
+	compact := make([]byte, 65)
+	compact[0] = 27 + 4 + signature[64]
+	copy(compact[1:], signature[:64])
+
+	pubkey, _, err := ecdsa.RecoverCompact(compact, hash[:])
+	if err != nil {
+		return fmt.Errorf("invalid invoice signature: %w", err)
+	}
+	recovered := hex.EncodeToString(pubkey.SerializeCompressed())
+
+	if i.Payee != "" && i.Payee != recovered {
+		return errors.New("invoice signature does not match the payee")
+	}
+	i.Payee = recovered
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package bolt11
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vectors of BOLT #11, all signed by the same key
+const (
+	specPayee       = "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"
+	specPaymentHash = "0001020304050607080900010203040506070809000102030405060708090102"
+	specSecret      = "1111111111111111111111111111111111111111111111111111111111111111"
+	specHash        = "3925b6f67e2c340036ed12093dd44e0368df1b6ea26c53dbe4811f58fd5db8c1"
+
+	donation  = "lnbc1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq9qrsgq357wnc5r2ueh7ck6q93dj32dlqnls087fxdwk8qakdyafkq3yap9us6v52vjjsrvywa6rt52cm9r9zqt8r2t7mlcwspyetp5h2tztugp9lfyql"
+	coffee    = "lnbc2500u1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpu9qrsgquk0rl77nj30yxdy8j9vdx85fkpmdla2087ne0xh8nhedh8w27kyke0lp53ut353s06fv3qfegext0eh0ymjpf39tuven09sam30g4vgpfna3rh"
+	nonsense  = "lnbc2500u1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpquwpc4curk03c9wlrswe78q4eyqc7d8d0xqzpu9qrsgqhtjpauu9ur7fw2thcl4y9vfvh4m9wlfyz2gem29g5ghe2aak2pm3ps8fdhtceqsaagty2vph7utlgj48u0ged6a337aewvraedendscp573dxr"
+	hashed    = "lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs9qrsgq7ea976txfraylvgzuxs8kgcw23ezlrszfnh8r6qtfpr6cxga50aj6txm9rxrydzd06dfeawfk6swupvz4erwnyutnjq7x39ymw6j38gp7ynn44"
+	testnet   = "lntb20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygshp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqfpp3x9et2e20v6pu37c5d9vax37wxq72un989qrsgqdj545axuxtnfemtpwkc45hx9d2ft7x04mt8q7y6t0k2dge9e7h8kpy9p34ytyslj3yu569aalz2xdk8xkd7ltxqld94u8h2esmsmacgpghe9k8"
+	routed    = "lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqsfpp3qjmp7lwpagxun9pygexvgpjdc4jdj85fr9yq20q82gphp2nflc7jtzrcazrra7wwgzxqc8u7754cdlpfrmccae92qgzqvzq2ps8pqqqqqqpqqqqq9qqqvpeuqafqxu92d8lr6fvg0r5gv0heeeqgcrqlnm6jhphu9y00rrhy4grqszsvpcgpy9qqqqqqgqqqqq7qqzq9qrsgqdfjcdk6w3ak5pca9hwfwfh63zrrz06wwfya0ydlzpgzxkn5xagsqz7x9j4jwe7yj7vaf2k9lqsdk45kts2fd0fkr28am0u4w95tt2nsq76cqw0"
+	picoStore = "lnbc9678785340p1pwmna7lpp5gc3xfm08u9qy06djf8dfflhugl6p7lgza6dsjxq454gxhj9t7a0sd8dgfkx7cmtwd68yetpd5s9xar0wfjn5gpc8qhrsdfq24f5ggrxdaezqsnvda3kkum5wfjkzmfqf3jkgem9wgsyuctwdus9xgrcyqcjcgpzgfskx6eqf9hzqnteypzxz7fzypfhg6trddjhygrcyqezcgpzfysywmm5ypxxjemgw3hxjmn8yptk7untd9hxwg3q2d6xjcmtv4ezq7pqxgsxzmnyyqcjqmt0wfjjq6t5v4khxsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygsxqyjw5qcqp2rzjq0gxwkzc8w6323m55m4jyxcjwmy7stt9hwkwe2qxmy8zpsgg7jcuwz87fcqqeuqqqyqqqqlgqqqqn3qq9q9qrsgqrvgkpnmps664wgkp43l22qsgdw4ve24aca4nymnxddlnp8vh9v2sdxlu5ywdxefsfvm0fq3sesf08uf6q9a2ke0hc9j6z6wlxg5z5kqpu2v9wz"
+)
+
+var specCreatedAt = time.Unix(1496314658, 0).UTC()
+
+func TestDecodeSpecVectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		invoice string
+		want    Invoice
+	}{
+		{"donation of any amount", donation, Invoice{
+			Network: NetworkMainnet, CreatedAt: specCreatedAt, Expiry: 3600,
+			PaymentHash: specPaymentHash, PaymentSecret: specSecret,
+			Description: "Please consider supporting this project", MinFinalCLTV: 18,
+		}},
+		{"coffee within one minute", coffee, Invoice{
+			Network: NetworkMainnet, AmountMsats: 250_000_000, CreatedAt: specCreatedAt, Expiry: 60,
+			PaymentHash: specPaymentHash, PaymentSecret: specSecret,
+			Description: "1 cup coffee", MinFinalCLTV: 18,
+		}},
+		{"utf-8 description", nonsense, Invoice{
+			Network: NetworkMainnet, AmountMsats: 250_000_000, CreatedAt: specCreatedAt, Expiry: 60,
+			PaymentHash: specPaymentHash, PaymentSecret: specSecret,
+			Description: "ナンセンス 1杯", MinFinalCLTV: 18,
+		}},
+		{"hashed description", hashed, Invoice{
+			Network: NetworkMainnet, AmountMsats: 2_000_000_000, CreatedAt: specCreatedAt, Expiry: 3600,
+			PaymentHash: specPaymentHash, PaymentSecret: specSecret,
+			DescriptionHash: specHash, MinFinalCLTV: 18,
+		}},
+		{"testnet with fallback", testnet, Invoice{
+			Network: NetworkTestnet, AmountMsats: 2_000_000_000, CreatedAt: specCreatedAt, Expiry: 3600,
+			PaymentHash: specPaymentHash, PaymentSecret: specSecret,
+			DescriptionHash: specHash, MinFinalCLTV: 18,
+		}},
+		{"pico amount", picoStore, Invoice{
+			Network: NetworkMainnet, AmountMsats: 967_878_534, CreatedAt: time.Unix(1572468703, 0).UTC(), Expiry: 604800,
+			PaymentHash:   "462264ede7e14047e9b249da94fefc47f41f7d02ee9b091815a5506bc8abf75f",
+			PaymentSecret: specSecret,
+			Description:   `Blockstream Store: 88.85 USD for Blockstream Ledger Nano S x 1, "Back In My Day" Sticker x 2, "I Got Lightning Working" Sticker x 2 and 1 more items`,
+			MinFinalCLTV:  10,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode(test.invoice)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			want := test.want
+			want.Raw = test.invoice
+			want.Payee = specPayee
+			want.ExpiresAt = want.CreatedAt.Add(time.Duration(want.Expiry) * time.Second)
+			if got.Network != want.Network || got.AmountMsats != want.AmountMsats ||
+				!got.CreatedAt.Equal(want.CreatedAt) || got.Expiry != want.Expiry || !got.ExpiresAt.Equal(want.ExpiresAt) ||
+				got.PaymentHash != want.PaymentHash || got.PaymentSecret != want.PaymentSecret || got.Payee != want.Payee ||
+				got.Description != want.Description || got.DescriptionHash != want.DescriptionHash ||
+				got.MinFinalCLTV != want.MinFinalCLTV || got.Raw != want.Raw {
+				t.Fatalf("Decode() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeRouteHints(t *testing.T) {
+	got, err := Decode(routed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RouteHint{
+		{PubKey: "029e03a901b85534ff1e92c43c74431f7ce72046060fcf7a95c37e148f78c77255", ShortChannelID: "66051x263430x1800", FeeBaseMsat: 1, FeeProportionalMillionths: 20, CLTVExpiryDelta: 3},
+		{PubKey: "039e03a901b85534ff1e92c43c74431f7ce72046060fcf7a95c37e148f78c77255", ShortChannelID: "197637x395016x2314", FeeBaseMsat: 2, FeeProportionalMillionths: 30, CLTVExpiryDelta: 4},
+	}
+	if len(got.RouteHints) != 1 || len(got.RouteHints[0]) != len(want) {
+		t.Fatalf("RouteHints = %+v, want %+v", got.RouteHints, want)
+	}
+	for i, hop := range got.RouteHints[0] {
+		if hop != want[i] {
+			t.Errorf("hop %d = %+v, want %+v", i, hop, want[i])
+		}
+	}
+}
+
+func TestDecodeAcceptsURIAndUpperCase(t *testing.T) {
+	for _, invoice := range []string{"lightning:" + coffee, strings.ToUpper(coffee), " " + coffee + "\n"} {
+		got, err := Decode(invoice)
+		if err != nil {
+			t.Fatalf("Decode(%.20q) error = %v", invoice, err)
+		}
+		if got.Raw != coffee {
+			t.Fatalf("Decode(%.20q) Raw = %.20q, want the invoice in lower case", invoice, got.Raw)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		invoice string
+		want    string
+	}{
+		{"bad checksum", coffee[:len(coffee)-1] + "q", "checksum"},
+		{"invalid character", strings.Replace(coffee, "pvjluez", "pvjlbez", 1), "character"},
+		{"mixed case", "LNBC2500u" + coffee[9:], "mixes upper and lower case"},
+		{"no separator", "lnbc2500u", "invalid bech32"},
+		{"too short", reencode(t, coffee, "lnbc2500u", func(words []byte) []byte { return words[:7+signatureWords-1] }), "too short"},
+		{"unknown network", reencode(t, coffee, "lnxy2500u", nil), "unknown invoice network"},
+		{"not lightning", reencode(t, coffee, "xxbc2500u", nil), "does not start with ln"},
+		{"invalid multiplier", reencode(t, coffee, "lnbc2500x", nil), "invalid invoice amount"},
+		{"zero amount", reencode(t, coffee, "lnbc0u", nil), "invalid invoice amount"},
+		{"leading zero", reencode(t, coffee, "lnbc02500u", nil), "invalid invoice amount"},
+		{"sub-millisatoshi amount", reencode(t, coffee, "lnbc2500000001p", nil), "whole number of millisatoshis"},
+		{"amount overflows", reencode(t, coffee, "lnbc92233721", nil), "too large"},
+		{"milli amount overflows", reencode(t, coffee, "lnbc92233720369m", nil), "too large"},
+		{"expiry field too long", reencode(t, coffee, "lnbc2500u", withField(fieldExpiry, 8)), "expiry field is too long"},
+		{"cltv field too long", reencode(t, coffee, "lnbc2500u", withField(fieldMinFinalCLTV, 8)), "min_final_cltv_expiry field is too long"},
+		{"truncated field", reencode(t, coffee, "lnbc2500u", func(words []byte) []byte {
+			data := append([]byte{}, words[:7]...)
+			data = append(data, fieldDescription, 31, 31)
+			return append(data, words[len(words)-signatureWords:]...)
+		}), "truncated invoice field"},
+		{"changed amount", reencode(t, coffee, "lnbc2501u", nil), ""},
+		{"invalid recovery flag", reencode(t, coffee, "lnbc2500u", func(words []byte) []byte {
+			words[len(words)-1] = 31
+			return words
+		}), "invalid invoice signature"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode(test.invoice)
+			if test.want == "" {
+				// A changed invoice still recovers a key, just not the payee's
+				if err == nil && got.Payee == specPayee {
+					t.Fatal("Decode() accepted a changed invoice as signed by the payee")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Decode() = %+v, want an error", *got)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Decode() error = %v, want it to mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodePayeeMismatch(t *testing.T) {
+	other := "029e03a901b85534ff1e92c43c74431f7ce72046060fcf7a95c37e148f78c77255"
+	invoice := reencode(t, coffee, "lnbc2500u", func(words []byte) []byte {
+		payee := append([]byte{fieldPayee, 1, 21}, bytesToWords(mustHex(t, other))...)
+		data := append(append([]byte{}, words[:7]...), payee...)
+		return append(data, words[7:]...)
+	})
+
+	if _, err := Decode(invoice); err == nil || !strings.Contains(err.Error(), "does not match the payee") {
+		t.Fatalf("Decode() error = %v, want a payee mismatch", err)
+	}
+}
+
+func TestParseFieldsCapsExpiry(t *testing.T) {
+	words := []byte{fieldExpiry, 0, maxUintWords, 31, 31, 31, 31, 31, 31, 31}
+
+	var invoice Invoice
+	if err := invoice.parseFields(words); err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Expiry != int64(maxExpiry/time.Second) {
+		t.Fatalf("Expiry = %d, want it capped at %d", invoice.Expiry, int64(maxExpiry/time.Second))
+	}
+
+	invoice.CreatedAt = time.Now()
+	if invoice.CreatedAt.Add(time.Duration(invoice.Expiry) * time.Second).Before(invoice.CreatedAt) {
+		t.Fatal("capped expiry still overflows")
+	}
+}
+
+func TestBech32RoundTrip(t *testing.T) {
+	data := []byte("any length of data, longer than the 90 characters BIP-173 allows for a bech32 string")
+
+	hrp, decoded, err := DecodeBech32(EncodeBech32("lnurl", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hrp != "lnurl" || string(decoded) != string(data) {
+		t.Fatalf("DecodeBech32() = %q, %q, want %q, %q", hrp, decoded, "lnurl", data)
+	}
+}
+
+// reencode replaces the human readable part of invoice and, when mutate is set,
+// its words, and returns it with a valid checksum
+func reencode(t *testing.T, invoice string, hrp string, mutate func(words []byte) []byte) string {
+	t.Helper()
+
+	_, words, err := decodeBech32(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words = append([]byte{}, words...)
+	if mutate != nil {
+		words = mutate(words)
+	}
+	return encodeBech32(hrp, words)
+}
+
+// withField inserts a field of the given type and length in words after the timestamp
+func withField(fieldType byte, length int) func(words []byte) []byte {
+	return func(words []byte) []byte {
+		field := append([]byte{fieldType, byte(length >> 5), byte(length & 31)}, make([]byte, length)...)
+		field[3] = 1
+		data := append(append([]byte{}, words[:7]...), field...)
+		return append(data, words[7:]...)
+	}
+}
+
+func mustHex(t *testing.T, value string) []byte {
+	t.Helper()
+
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,177 @@
+// Package budget enforces wallet spending policies against the payment ledger
+package budget
+
+import (
+	"fmt"
+	"time"
+
+	"nwc_app/ledger"
+	"nwc_app/wallet"
+)
+
+// Violation is returned when a payment would break a wallet's spending policy
+type Violation struct {
+	Wallet  string `json:"wallet"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("spending policy violation for wallet '%s': %s", v.Wallet, v.Message)
+}
+
+// Window is a calendar period a budget applies to, in UTC
+type Window struct {
+	Name   string
+	Budget wallet.Budget
+	Start  time.Time
+	End    time.Time
+}
+
+// Windows returns the daily, weekly and monthly windows containing now.
+// Weeks start on Monday.
+func Windows(policy wallet.SpendingPolicy, now time.Time) []Window {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	week := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return []Window{
+		{Name: "daily", Budget: policy.Daily, Start: day, End: day.AddDate(0, 0, 1)},
+		{Name: "weekly", Budget: policy.Weekly, Start: week, End: week.AddDate(0, 0, 7)},
+		{Name: "monthly", Budget: policy.Monthly, Start: month, End: month.AddDate(0, 1, 0)},
+	}
+}
+
+// Earliest returns the start of the oldest window containing now.
+// Entries older than this never count against any budget.
+func Earliest(policy wallet.SpendingPolicy, now time.Time) time.Time {
+	earliest := now
+	for _, window := range Windows(policy, now) {
+		if window.Start.Before(earliest) {
+			earliest = window.Start
+		}
+	}
+	return earliest
+}
+
+// Spent sums the entries of a window that count against the budget
+func Spent(window Window, history []ledger.Entry) (msats int64, eur float64, payments int) {
+	for _, entry := range history {
+		if !entry.Counts() || entry.CreatedAt.Before(window.Start) || !entry.CreatedAt.Before(window.End) {
+			continue
+		}
+		msats += entry.AmountMsats + entry.FeesMsats
+		eur += entry.EuroAmount
+		payments++
+	}
+	return msats, eur, payments
+}
+
+// Check verifies that a payment of amountMsats (worth euroAmount) fits the policy
+// given the wallet's payment history. It returns a *Violation when it does not.
+func Check(walletID string, policy wallet.SpendingPolicy, history []ledger.Entry, amountMsats int64, euroAmount float64, now time.Time) error {
+	if policy.MaxPaymentMsats > 0 && amountMsats > policy.MaxPaymentMsats {
+		return &Violation{
+			Wallet:  walletID,
+			Rule:    "max_payment_msats",
+			Message: fmt.Sprintf("payment of %d msats exceeds the maximum of %d msats per payment", amountMsats, policy.MaxPaymentMsats),
+		}
+	}
+	if policy.MaxPaymentEur > 0 && euroAmount > policy.MaxPaymentEur {
+		return &Violation{
+			Wallet:  walletID,
+			Rule:    "max_payment_eur",
+			Message: fmt.Sprintf("payment of %.8f EUR exceeds the maximum of %.8f EUR per payment", euroAmount, policy.MaxPaymentEur),
+		}
+	}
+
+	for _, window := range Windows(policy, now) {
+		if window.Budget.IsZero() {
+			continue
+		}
+
+		spentMsats, spentEur, payments := Spent(window, history)
+		limit := window.Budget
+		resets := window.End.Format(time.RFC3339)
+
+		if limit.MaxPayments > 0 && payments+1 > limit.MaxPayments {
+			return &Violation{
+				Wallet:  walletID,
+				Rule:    window.Name + ".max_payments",
+				Message: fmt.Sprintf("%s limit of %d payments reached, resets at %s", window.Name, limit.MaxPayments, resets),
+			}
+		}
+		if limit.MaxMsats > 0 && spentMsats+amountMsats > limit.MaxMsats {
+			return &Violation{
+				Wallet: walletID,
+				Rule:   window.Name + ".max_msats",
+				Message: fmt.Sprintf("%s budget of %d msats would be exceeded, %d msats remaining until %s",
+					window.Name, limit.MaxMsats, max(limit.MaxMsats-spentMsats, 0), resets),
+			}
+		}
+		if limit.MaxEur > 0 && spentEur+euroAmount > limit.MaxEur {
+			return &Violation{
+				Wallet: walletID,
+				Rule:   window.Name + ".max_eur",
+				Message: fmt.Sprintf("%s budget of %.8f EUR would be exceeded, %.8f EUR remaining until %s",
+					window.Name, limit.MaxEur, max(limit.MaxEur-spentEur, 0), resets),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Usage reports how much of a window's budget has been used
+type Usage struct {
+	Window            string    `json:"window"`
+	StartsAt          time.Time `json:"starts_at"`
+	ResetsAt          time.Time `json:"resets_at"`
+	SpentMsats        int64     `json:"spent_msats"`
+	SpentEur          float64   `json:"spent_eur"`
+	Payments          int       `json:"payments"`
+	MaxMsats          int64     `json:"max_msats,omitempty"`
+	MaxEur            float64   `json:"max_eur,omitempty"`
+	MaxPayments       int       `json:"max_payments,omitempty"`
+	RemainingMsats    *int64    `json:"remaining_msats,omitempty"`
+	RemainingEur      *float64  `json:"remaining_eur,omitempty"`
+	RemainingPayments *int      `json:"remaining_payments,omitempty"`
+}
+
+// Report returns the usage of every window of the policy.
+// Remaining amounts are only set for limits the policy defines.
+func Report(policy wallet.SpendingPolicy, history []ledger.Entry, now time.Time) []Usage {
+	var usages []Usage
+	for _, window := range Windows(policy, now) {
+		spentMsats, spentEur, payments := Spent(window, history)
+		usage := Usage{
+			Window:      window.Name,
+			StartsAt:    window.Start,
+			ResetsAt:    window.End,
+			SpentMsats:  spentMsats,
+			SpentEur:    spentEur,
+			Payments:    payments,
+			MaxMsats:    window.Budget.MaxMsats,
+			MaxEur:      window.Budget.MaxEur,
+			MaxPayments: window.Budget.MaxPayments,
+		}
+
+		if limit := window.Budget.MaxMsats; limit > 0 {
+			remaining := max(limit-spentMsats, 0)
+			usage.RemainingMsats = &remaining
+		}
+		if limit := window.Budget.MaxEur; limit > 0 {
+			remaining := max(limit-spentEur, 0)
+			usage.RemainingEur = &remaining
+		}
+		if limit := window.Budget.MaxPayments; limit > 0 {
+			remaining := max(limit-payments, 0)
+			usage.RemainingPayments = &remaining
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages
+}
@@ -0,0 +1,181 @@
+package budget
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"nwc_app/ledger"
+	"nwc_app/wallet"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestWindows(t *testing.T) {
+	tests := []struct {
+		name                   string
+		now                    time.Time
+		day, week, month, next time.Time
+	}{
+		{"sunday night", date(2025, 1, 19, 23, 59), date(2025, 1, 19, 0, 0), date(2025, 1, 13, 0, 0), date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0)},
+		{"monday midnight", date(2025, 1, 20, 0, 0), date(2025, 1, 20, 0, 0), date(2025, 1, 20, 0, 0), date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0)},
+		{"last of the month", date(2025, 1, 31, 23, 59), date(2025, 1, 31, 0, 0), date(2025, 1, 27, 0, 0), date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0)},
+		{"first of the month", date(2025, 2, 1, 0, 0), date(2025, 2, 1, 0, 0), date(2025, 1, 27, 0, 0), date(2025, 2, 1, 0, 0), date(2025, 3, 1, 0, 0)},
+		{"year end", date(2024, 12, 31, 12, 0), date(2024, 12, 31, 0, 0), date(2024, 12, 30, 0, 0), date(2024, 12, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"leap day", date(2024, 2, 29, 12, 0), date(2024, 2, 29, 0, 0), date(2024, 2, 26, 0, 0), date(2024, 2, 1, 0, 0), date(2024, 3, 1, 0, 0)},
+		// Monday 00:30 in Zagreb is still Sunday in UTC
+		{"other time zone", time.Date(2025, 1, 20, 0, 30, 0, 0, time.FixedZone("CET", 3600)), date(2025, 1, 19, 0, 0), date(2025, 1, 13, 0, 0), date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			windows := Windows(wallet.SpendingPolicy{}, test.now)
+			if len(windows) != 3 {
+				t.Fatalf("Windows() returned %d windows, want 3", len(windows))
+			}
+
+			daily, weekly, monthly := windows[0], windows[1], windows[2]
+			if !daily.Start.Equal(test.day) || !daily.End.Equal(test.day.AddDate(0, 0, 1)) {
+				t.Errorf("daily = %s to %s, want the day of %s", daily.Start, daily.End, test.day)
+			}
+			if !weekly.Start.Equal(test.week) || !weekly.End.Equal(test.week.AddDate(0, 0, 7)) {
+				t.Errorf("weekly = %s to %s, want the week from %s", weekly.Start, weekly.End, test.week)
+			}
+			if !monthly.Start.Equal(test.month) || !monthly.End.Equal(test.next) {
+				t.Errorf("monthly = %s to %s, want %s to %s", monthly.Start, monthly.End, test.month, test.next)
+			}
+			earliest := test.week
+			if test.month.Before(earliest) {
+				earliest = test.month
+			}
+			if got := Earliest(wallet.SpendingPolicy{}, test.now); !got.Equal(earliest) {
+				t.Errorf("Earliest() = %s, want %s", got, earliest)
+			}
+		})
+	}
+}
+
+func TestSpent(t *testing.T) {
+	window := Window{Start: date(2025, 1, 13, 0, 0), End: date(2025, 1, 20, 0, 0)}
+	history := []ledger.Entry{
+		{Status: ledger.StatusSucceeded, AmountMsats: 1000, FeesMsats: 10, EuroAmount: 1, CreatedAt: date(2025, 1, 13, 0, 0)},
+		// Pending payments count so concurrent ones cannot overspend
+		{Status: ledger.StatusPending, AmountMsats: 2000, EuroAmount: 2, CreatedAt: date(2025, 1, 19, 23, 59)},
+		{Status: ledger.StatusFailed, AmountMsats: 4000, EuroAmount: 4, CreatedAt: date(2025, 1, 15, 0, 0)},
+		{Status: ledger.StatusSucceeded, AmountMsats: 8000, EuroAmount: 8, CreatedAt: date(2025, 1, 12, 23, 59)},
+		{Status: ledger.StatusSucceeded, AmountMsats: 16000, EuroAmount: 16, CreatedAt: date(2025, 1, 20, 0, 0)},
+	}
+
+	msats, eur, payments := Spent(window, history)
+	if msats != 3010 || eur != 3 || payments != 2 {
+		t.Fatalf("Spent() = %d msats, %.2f EUR, %d payments, want 3010, 3, 2", msats, eur, payments)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := date(2025, 1, 15, 12, 0)
+	spent := func(amountMsats int64, euroAmount float64, createdAt time.Time) ledger.Entry {
+		return ledger.Entry{Status: ledger.StatusSucceeded, AmountMsats: amountMsats, EuroAmount: euroAmount, CreatedAt: createdAt}
+	}
+	today := spent(5000, 5, now.Add(-time.Hour))
+	monday := spent(5000, 5, date(2025, 1, 13, 9, 0))
+	lastMonth := spent(50000, 50, date(2024, 12, 31, 9, 0))
+	pending := ledger.Entry{Status: ledger.StatusPending, AmountMsats: 5000, EuroAmount: 5, CreatedAt: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name        string
+		policy      wallet.SpendingPolicy
+		history     []ledger.Entry
+		amountMsats int64
+		euroAmount  float64
+		want        string
+	}{
+		{"no policy", wallet.SpendingPolicy{}, []ledger.Entry{today, lastMonth}, 1 << 40, 1e9, ""},
+		{"max payment msats", wallet.SpendingPolicy{MaxPaymentMsats: 1000}, nil, 1001, 1, "max_payment_msats"},
+		{"max payment msats reached exactly", wallet.SpendingPolicy{MaxPaymentMsats: 1000}, nil, 1000, 1, ""},
+		{"max payment eur", wallet.SpendingPolicy{MaxPaymentEur: 1}, nil, 1000, 1.5, "max_payment_eur"},
+		{"daily max msats", wallet.SpendingPolicy{Daily: wallet.Budget{MaxMsats: 6000}}, []ledger.Entry{today}, 1001, 1, "daily.max_msats"},
+		{"daily max msats fits", wallet.SpendingPolicy{Daily: wallet.Budget{MaxMsats: 6000}}, []ledger.Entry{today, monday}, 1000, 1, ""},
+		{"daily max eur", wallet.SpendingPolicy{Daily: wallet.Budget{MaxEur: 6}}, []ledger.Entry{today}, 1000, 1.5, "daily.max_eur"},
+		{"daily max payments", wallet.SpendingPolicy{Daily: wallet.Budget{MaxPayments: 1}}, []ledger.Entry{today}, 1, 0, "daily.max_payments"},
+		{"weekly max msats", wallet.SpendingPolicy{Weekly: wallet.Budget{MaxMsats: 10000}}, []ledger.Entry{today, monday}, 1, 0, "weekly.max_msats"},
+		{"weekly max eur", wallet.SpendingPolicy{Weekly: wallet.Budget{MaxEur: 10}}, []ledger.Entry{today, monday}, 1, 0.01, "weekly.max_eur"},
+		{"weekly max payments", wallet.SpendingPolicy{Weekly: wallet.Budget{MaxPayments: 2}}, []ledger.Entry{today, monday}, 1, 0, "weekly.max_payments"},
+		{"monthly max msats", wallet.SpendingPolicy{Monthly: wallet.Budget{MaxMsats: 10000}}, []ledger.Entry{today, monday, lastMonth}, 1, 0, "monthly.max_msats"},
+		{"monthly max eur", wallet.SpendingPolicy{Monthly: wallet.Budget{MaxEur: 10}}, []ledger.Entry{today, monday, lastMonth}, 1, 0.01, "monthly.max_eur"},
+		{"monthly max payments", wallet.SpendingPolicy{Monthly: wallet.Budget{MaxPayments: 2}}, []ledger.Entry{today, monday, lastMonth}, 1, 0, "monthly.max_payments"},
+		{"last month does not count", wallet.SpendingPolicy{Monthly: wallet.Budget{MaxMsats: 20000}}, []ledger.Entry{today, monday, lastMonth}, 10000, 0, ""},
+		{"pending payments count", wallet.SpendingPolicy{Daily: wallet.Budget{MaxMsats: 10000}}, []ledger.Entry{today, pending}, 1, 0, "daily.max_msats"},
+		{"failed payments do not count", wallet.SpendingPolicy{Daily: wallet.Budget{MaxPayments: 1}}, []ledger.Entry{{Status: ledger.StatusFailed, CreatedAt: now}}, 1, 0, ""},
+		// The shortest window is checked first
+		{"daily before monthly", wallet.SpendingPolicy{Daily: wallet.Budget{MaxMsats: 1000}, Monthly: wallet.Budget{MaxMsats: 1000}}, nil, 2000, 0, "daily.max_msats"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check("WALLET", test.policy, test.history, test.amountMsats, test.euroAmount, now)
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v, want none", err)
+				}
+				return
+			}
+
+			var violation *Violation
+			if !errors.As(err, &violation) {
+				t.Fatalf("Check() error = %v, want a violation of %s", err, test.want)
+			}
+			if violation.Rule != test.want || violation.Wallet != "WALLET" {
+				t.Fatalf("Check() violated %s of %s, want %s of WALLET", violation.Rule, violation.Wallet, test.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	now := date(2025, 1, 15, 12, 0)
+	policy := wallet.SpendingPolicy{
+		Daily:   wallet.Budget{MaxMsats: 4000, MaxEur: 4, MaxPayments: 1},
+		Monthly: wallet.Budget{MaxMsats: 100000},
+	}
+	// The fees pushed the day over its budget
+	history := []ledger.Entry{
+		{Status: ledger.StatusSucceeded, AmountMsats: 4000, FeesMsats: 100, EuroAmount: 4.5, CreatedAt: now.Add(-time.Hour)},
+		{Status: ledger.StatusPending, AmountMsats: 1000, EuroAmount: 1, CreatedAt: now.Add(-time.Minute)},
+	}
+
+	usages := Report(policy, history, now)
+	if len(usages) != 3 {
+		t.Fatalf("Report() returned %d windows, want 3", len(usages))
+	}
+
+	daily := usages[0]
+	if daily.Window != "daily" || daily.SpentMsats != 5100 || daily.Payments != 2 {
+		t.Fatalf("daily = %+v, want 5100 msats in 2 payments", daily)
+	}
+	if daily.RemainingMsats == nil || *daily.RemainingMsats != 0 {
+		t.Fatalf("daily remaining msats = %v, want 0", daily.RemainingMsats)
+	}
+	if daily.RemainingEur == nil || *daily.RemainingEur != 0 {
+		t.Fatalf("daily remaining EUR = %v, want 0", daily.RemainingEur)
+	}
+	if daily.RemainingPayments == nil || *daily.RemainingPayments != 0 {
+		t.Fatalf("daily remaining payments = %v, want 0", daily.RemainingPayments)
+	}
+
+	// Only limits the policy sets have a remaining amount
+	weekly := usages[1]
+	if weekly.RemainingMsats != nil || weekly.RemainingEur != nil || weekly.RemainingPayments != nil {
+		t.Fatalf("weekly = %+v, want no remaining amounts", weekly)
+	}
+
+	monthly := usages[2]
+	if monthly.RemainingMsats == nil || *monthly.RemainingMsats != 94900 || monthly.RemainingEur != nil {
+		t.Fatalf("monthly = %+v, want 94900 msats remaining and no EUR", monthly)
+	}
+	if !monthly.StartsAt.Equal(date(2025, 1, 1, 0, 0)) || !monthly.ResetsAt.Equal(date(2025, 2, 1, 0, 0)) {
+		t.Fatalf("monthly = %s to %s, want January", monthly.StartsAt, monthly.ResetsAt)
+	}
+}
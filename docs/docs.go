@@ -15,6 +15,47 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/lnurlp/{name}": {
+            "get": {
+                "description": "Returns the LNURL-pay parameters of a wallet's Lightning Address (LUD-06, LUD-16). Errors use the LNURL status format.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lnurl"
+                ],
+                "summary": "Lightning Address LNURL-pay endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name part of the Lightning Address",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.PayParams"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.Status"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/convert/eur-to-msats": {
             "get": {
                 "description": "Converts a Euro amount to millisatoshis using current exchange rate",
@@ -35,10 +76,9 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "API Key for authentication",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
                         "name": "api_key",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -60,8 +100,172 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Exchange rate unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/decode": {
+            "post": {
+                "description": "Decodes and verifies a BOLT11 invoice and values its amount in EUR, e.g. to confirm a payment with the user before paying it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "conversion"
+                ],
+                "summary": "Decode a BOLT11 invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Invoice to decode",
+                        "name": "invoice",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.DecodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.DecodeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "description": "Server-Sent Events stream of payment, invoice and wallet health events as they happen.\nEach event is sent with its ID, its type as the event name and the event as JSON data. Only event types the credentials have the permission for are sent: payments for payment events, invoices for invoice, payment request and payment.received events and wallets for wallet events.\nA client reconnecting with the Last-Event-ID header, or the last_event_id parameter, first receives the events it missed that are still in the event log.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Stream events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated event types to receive, all permitted types by default",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated wallet IDs to receive events of, all wallets by default",
+                        "name": "wallet",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received, when the Last-Event-ID header cannot be set",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
@@ -109,9 +313,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/nwc_payment": {
+        "/keysend": {
             "post": {
-                "description": "Transfer funds from one wallet to another using EUR amount",
+                "description": "Push a EUR amount from a wallet to a Lightning node pubkey without an invoice, optionally with TLV records",
                 "consumes": [
                     "application/json"
                 ],
@@ -121,22 +325,21 @@ const docTemplate = `{
                 "tags": [
                     "payments"
                 ],
-                "summary": "Make an NWC payment",
+                "summary": "Make a keysend payment",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "API Key for authentication",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
                         "name": "api_key",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
                     },
                     {
-                        "description": "Payment Information",
+                        "description": "Keysend payment",
                         "name": "payment",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.NwcPaymentRequest"
+                            "$ref": "#/definitions/main.KeysendPaymentRequest"
                         }
                     }
                 ],
@@ -144,7 +347,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.NwcPaymentResponse"
+                            "$ref": "#/definitions/main.KeysendPaymentResponse"
                         }
                     },
                     "400": {
@@ -159,95 +362,3622 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
+                    "402": {
+                        "description": "Insufficient balance",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or spending policy violation",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Wallet or exchange rate service failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "main.ConversionResponse": {
-            "type": "object",
-            "properties": {
-                "euro_amount": {
-                    "type": "number"
-                },
-                "msat_amount": {
-                    "type": "integer"
+        },
+        "/lnurlp/{name}/callback": {
+            "get": {
+                "description": "Creates an invoice on the wallet behind a Lightning Address for the requested amount, committing to the address metadata. Errors use the LNURL status format.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "lnurl"
+                ],
+                "summary": "Lightning Address LNURL-pay callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name part of the Lightning Address",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Amount in millisatoshis",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comment for the recipient (LUD-12)",
+                        "name": "comment",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.InvoiceReply"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.Status"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.Status"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/lnurl.Status"
+                        }
+                    }
                 }
             }
         },
-        "main.ErrorResponse": {
-            "type": "object",
-            "properties": {
+        "/nwc_payment": {
+            "post": {
+                "description": "Transfer funds from one wallet to another wallet or a Lightning Address using EUR amount",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Make an NWC payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Payment Information",
+                        "name": "payment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.NwcPaymentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.NwcPaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Insufficient balance",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Invoice already paid",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed, spending policy violation or unusable Lightning Address",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet or exchange rate service failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/pay/{id}": {
+            "get": {
+                "description": "HTML page showing the invoice of a hosted payment request as a QR code. The invoice is quoted again at the current rate once it expires, and the page shows when the request has been paid.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "payment-requests"
+                ],
+                "summary": "Payment page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Payment page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Payment request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "No invoice could be created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/pay/{id}/status": {
+            "get": {
+                "description": "Reports whether a hosted payment request is open, paid or expired and which invoice it currently shows, for its payment page to poll",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment-requests"
+                ],
+                "summary": "Payment page status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PayPageStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-requests": {
+            "get": {
+                "description": "List all hosted payment requests with their status, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment-requests"
+                ],
+                "summary": "List payment requests",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.PaymentRequestResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a hosted payment request for a Euro amount into a configured wallet and returns the link of its payment page.\nThe page shows an invoice quoted at the current rate, quotes again when the quote expires and reports when the request is paid.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment-requests"
+                ],
+                "summary": "Create a payment request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Payment request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CreatePaymentRequestRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/main.PaymentRequestResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Recipient wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment-requests/{id}": {
+            "get": {
+                "description": "Get a hosted payment request with its current quote, or when and how much it was paid",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment-requests"
+                ],
+                "summary": "Get a payment request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PaymentRequestResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/batch": {
+            "post": {
+                "description": "Pay several recipients from one wallet, given as JSON or as a CSV upload with recipient and euro_amount columns. For CSV the other fields are passed as query or form parameters. The batch is stored and paid in the background; its progress and results are fetched by its ID.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Make a batch of payments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Batch of payments",
+                        "name": "batch",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/main.BatchPaymentRequest"
+                        }
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file with recipient and euro_amount columns",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/batches.Batch"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Sender wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "CSV larger than 1 MB",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/batch/{id}": {
+            "get": {
+                "description": "Get the status, per payment results and summary of a batch, also while it is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a batch of payments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/batches.Batch"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/split": {
+            "post": {
+                "description": "Divide one EUR payment across several recipients by percentage or fixed amounts. The total is converted once, every share's invoice is fetched and reserved before anything is paid, and the shares are paid as linked payments reported together.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Make a split payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Split payment",
+                        "name": "split",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SplitPaymentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/batches.Batch"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Sender wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Exchange rate unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/split/{id}": {
+            "get": {
+                "description": "Get the status, per share results and summary of a split payment",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a split payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Split payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/batches.Batch"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rebalances": {
+            "get": {
+                "description": "List the rebalances that moved funds, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rebalancing"
+                ],
+                "summary": "List rebalances",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rebalance.Run"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Move funds from wallets above their configured balance range to wallets below it.\nTransfers are paid one after another and recorded in the ledger with kind rebalance.\nA dry run only returns the transfers that would be made. Runs that moved funds are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rebalancing"
+                ],
+                "summary": "Rebalance wallets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only plan the transfers",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/rebalance.Run"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A rebalance is already running",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rebalances/{id}": {
+            "get": {
+                "description": "Get a rebalance with the balances it started from and its transfers, each linked to its ledger entry",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rebalancing"
+                ],
+                "summary": "Get a rebalance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rebalance ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/rebalance.Run"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules": {
+            "get": {
+                "description": "List all recurring payments with their status and next run",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "List recurring payments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/schedules.Schedule"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Schedule a payment from a wallet to another wallet or a Lightning Address, due on a cron expression or at a fixed interval until an optional end date",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Create a recurring payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Recurring payment",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CreateScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/main.ScheduleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Sender wallet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules/{id}": {
+            "get": {
+                "description": "Get a recurring payment and the history of its runs, each linked to its ledger entry",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get a recurring payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.ScheduleResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop a recurring payment; its history is kept",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Cancel a recurring payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Schedule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/schedules.Schedule"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Schedule has already ended or been cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/budget": {
+            "get": {
+                "description": "Reports the wallet's spending policy and how much of each daily, weekly and monthly budget is left",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wallets"
+                ],
+                "summary": "Get remaining wallet budget",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.BudgetResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/invoices": {
+            "post": {
+                "description": "Creates a receive invoice on a configured wallet for a msat or EUR amount",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoices"
+                ],
+                "summary": "Create an invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Invoice details",
+                        "name": "invoice",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CreateInvoiceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/main.InvoiceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet or exchange rate service failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/invoices/{payment_hash}": {
+            "get": {
+                "description": "Looks up an invoice on its wallet and reports whether it is open, paid or expired",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoices"
+                ],
+                "summary": "Get invoice status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment hash of the invoice",
+                        "name": "payment_hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/invoices.Invoice"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/invoices/{payment_hash}/qr.png": {
+            "get": {
+                "description": "Renders an open invoice as a QR code of its upper case lightning: URI, on the server without any external service",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "invoices"
+                ],
+                "summary": "Invoice QR code as PNG",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment hash of the invoice",
+                        "name": "payment_hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 256,
+                        "description": "Width and height in pixels, 64 to 1024",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Invoice is already paid or has expired",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/invoices/{payment_hash}/qr.svg": {
+            "get": {
+                "description": "Renders an open invoice as a scalable QR code of its upper case lightning: URI, on the server without any external service",
+                "produces": [
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "invoices"
+                ],
+                "summary": "Invoice QR code as SVG",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment hash of the invoice",
+                        "name": "payment_hash",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SVG image",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Invoice is already paid or has expired",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/lnurl-withdraw": {
+            "post": {
+                "description": "Creates an invoice on the wallet within the limits of an LNURL-withdraw link and submits it to the service. The invoice is watched until it is paid; poll its status to see when the funds arrive.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoices"
+                ],
+                "summary": "Redeem an LNURL-withdraw link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "LNURL-withdraw link",
+                        "name": "withdraw",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.LNURLWithdrawRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/main.LNURLWithdrawResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "LNURL is invalid or was refused by the service",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallets/{id}/pay-invoice": {
+            "post": {
+                "description": "Pays an external Lightning invoice from a configured wallet after validating its network, expiry, amount and description",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Pay a BOLT11 invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Sender wallet ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Invoice to pay",
+                        "name": "invoice",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PayInvoiceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PayInvoiceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Insufficient balance",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Invoice already paid",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid invoice or spending policy violation",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Wallet failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Wallet did not reply in time",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "List the registered webhooks without their secrets, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhooks.Webhook"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Registers an endpoint that receives the given events as signed JSON POST requests.\nThe secret signing the deliveries is only returned here. Events may be \"*\" for every event type: payment.succeeded, payment.failed, invoice.paid, invoice.expired, payment_request.paid, wallet.unhealthy, wallet.healthy and balance.low.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Webhook to register",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CreateWebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhooks.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "description": "Get a registered webhook without its secret",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/webhooks.Webhook"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes a webhook together with its deliveries, including those still waiting for a retry",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "List the events queued for a webhook, newest first, with every attempt at delivering them and the HTTP status the endpoint answered with",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Only deliveries with this status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhooks.Delivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries/{delivery_id}/replay": {
+            "post": {
+                "description": "Queues the payload of a delivered or failed delivery again as a new delivery, which is sent right away and retried like any other",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Replay a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "delivery_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/webhooks.Delivery"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery is still pending",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrades to a WebSocket connection carrying JSON messages, authenticated once when it opens.\nA message of type request calls any REST endpoint with the credentials of the connection, as {\"id\", \"type\": \"request\", \"method\", \"path\", \"body\"}, and is answered by a response with the same id, the HTTP status and body. Requests run concurrently, up to 8 at a time.\nA message of type subscribe, with optional events, wallets and last_event_id, receives events like GET /events as messages of type event until an unsubscribe. A ping is answered with a pong.",
+                "tags": [
+                    "events"
+                ],
+                "summary": "WebSocket API",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key for authentication (not needed for HMAC or NIP-98 signed requests)",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "426": {
+                        "description": "Not a WebSocket request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "batches.Batch": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/batches.Item"
+                    }
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "multi_pay": {
+                    "type": "boolean"
+                },
+                "sender": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "summary": {
+                    "$ref": "#/definitions/batches.Summary"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "batches.Item": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "error_code": {
+                    "description": "ErrorCode is the API error code of Error",
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "fees_paid": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "ledger_id": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "number"
+                },
+                "preimage": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "batches.Summary": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "fees_msats": {
+                    "type": "integer"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "bolt11.HopHint": {
+            "type": "object",
+            "properties": {
+                "cltv_expiry_delta": {
+                    "type": "integer"
+                },
+                "fee_base_msat": {
+                    "type": "integer"
+                },
+                "fee_proportional_millionths": {
+                    "type": "integer"
+                },
+                "pubkey": {
+                    "type": "string"
+                },
+                "short_channel_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "budget.Usage": {
+            "type": "object",
+            "properties": {
+                "max_eur": {
+                    "type": "number"
+                },
+                "max_msats": {
+                    "type": "integer"
+                },
+                "max_payments": {
+                    "type": "integer"
+                },
+                "payments": {
+                    "type": "integer"
+                },
+                "remaining_eur": {
+                    "type": "number"
+                },
+                "remaining_msats": {
+                    "type": "integer"
+                },
+                "remaining_payments": {
+                    "type": "integer"
+                },
+                "resets_at": {
+                    "type": "string"
+                },
+                "spent_eur": {
+                    "type": "number"
+                },
+                "spent_msats": {
+                    "type": "integer"
+                },
+                "starts_at": {
+                    "type": "string"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "invoices.Invoice": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "invoice": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "preimage": {
+                    "type": "string"
+                },
+                "settled_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "wallet": {
+                    "type": "string"
+                }
+            }
+        },
+        "lnurl.InvoiceReply": {
+            "type": "object",
+            "properties": {
+                "pr": {
+                    "type": "string"
+                },
+                "routes": {
+                    "type": "array",
+                    "items": {}
+                }
+            }
+        },
+        "lnurl.PayParams": {
+            "type": "object",
+            "properties": {
+                "callback": {
+                    "type": "string"
+                },
+                "commentAllowed": {
+                    "type": "integer"
+                },
+                "maxSendable": {
+                    "type": "integer"
+                },
+                "metadata": {
+                    "type": "string"
+                },
+                "minSendable": {
+                    "type": "integer"
+                },
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "lnurl.Status": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.BatchPaymentItem": {
+            "type": "object",
+            "required": [
+                "euro_amount",
+                "recipient"
+            ],
+            "properties": {
+                "euro_amount": {
+                    "type": "number",
+                    "example": 0.000001
+                },
+                "recipient": {
+                    "description": "Recipient is a configured wallet ID or a Lightning Address (name@domain)",
+                    "type": "string",
+                    "example": "WALLET_VRATA_KRKE"
+                }
+            }
+        },
+        "main.BatchPaymentRequest": {
+            "type": "object",
+            "required": [
+                "items",
+                "sender"
+            ],
+            "properties": {
+                "concurrency": {
+                    "description": "Concurrency is how many payments are made at once, 4 by default",
+                    "type": "integer",
+                    "example": 4
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.BatchPaymentItem"
+                    }
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits per payment, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "multi_pay": {
+                    "description": "MultiPay pays all invoices with a single NIP-47 multi_pay_invoice request",
+                    "type": "boolean",
+                    "example": false
+                },
+                "sender": {
+                    "type": "string",
+                    "example": "WALLET_JOSIP"
+                }
+            }
+        },
+        "main.BudgetResponse": {
+            "type": "object",
+            "properties": {
+                "max_payment_eur": {
+                    "type": "number"
+                },
+                "max_payment_msats": {
+                    "type": "integer"
+                },
+                "wallet": {
+                    "type": "string"
+                },
+                "windows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/budget.Usage"
+                    }
+                }
+            }
+        },
+        "main.ConversionResponse": {
+            "type": "object",
+            "properties": {
+                "euro_amount": {
+                    "type": "number"
+                },
+                "msat_amount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.CreateInvoiceRequest": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer",
+                    "example": 1000000
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Order 1234"
+                },
+                "euro_amount": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "expiry_seconds": {
+                    "type": "integer",
+                    "example": 3600
+                }
+            }
+        },
+        "main.CreatePaymentRequestRequest": {
+            "type": "object",
+            "required": [
+                "euro_amount",
+                "recipient"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Conference ticket"
+                },
+                "euro_amount": {
+                    "type": "number",
+                    "example": 15
+                },
+                "expires_at": {
+                    "description": "ExpiresAt optionally ends the request; it can no longer be paid afterwards",
+                    "type": "string",
+                    "example": "2025-06-30T18:00:00Z"
+                },
+                "recipient": {
+                    "type": "string",
+                    "example": "WALLET_VRATA_KRKE"
+                }
+            }
+        },
+        "main.CreateScheduleRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "recipient",
+                "sender"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "example": 9.99
+                },
+                "cron": {
+                    "description": "Either a five field cron expression in UTC or a Go duration such as 24h",
+                    "type": "string",
+                    "example": "0 9 1 * *"
+                },
+                "currency": {
+                    "description": "Currency of the amount: EUR (default), SAT or MSAT",
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "ends_at": {
+                    "type": "string",
+                    "example": "2025-12-31T23:59:59Z"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "720h"
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "missed_runs": {
+                    "description": "MissedRuns is skip (default) or run_once for runs that fell due while the service was down",
+                    "type": "string",
+                    "example": "skip"
+                },
+                "recipient": {
+                    "description": "Recipient is a configured wallet ID or a Lightning Address (name@domain)",
+                    "type": "string",
+                    "example": "WALLET_VRATA_KRKE"
+                },
+                "sender": {
+                    "type": "string",
+                    "example": "WALLET_JOSIP"
+                },
+                "starts_at": {
+                    "description": "StartsAt delays the first run; an interval schedule first runs at StartsAt",
+                    "type": "string",
+                    "example": "2025-01-01T09:00:00Z"
+                }
+            }
+        },
+        "main.CreateWebhookRequest": {
+            "type": "object",
+            "required": [
+                "events",
+                "url"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Accounting"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "payment.succeeded",
+                        "invoice.paid"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/nwc"
+                },
+                "wallets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "WALLET_VRATA_KRKE"
+                    ]
+                }
+            }
+        },
+        "main.DecodeRequest": {
+            "type": "object",
+            "required": [
+                "invoice"
+            ],
+            "properties": {
+                "invoice": {
+                    "type": "string",
+                    "example": "lnbc10u1p..."
+                }
+            }
+        },
+        "main.DecodeResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "description_hash": {
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "description": "EuroAmount is omitted when the invoice has no amount or no exchange rate is available",
+                    "type": "number"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "expiry": {
+                    "type": "integer"
+                },
+                "features": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "min_final_cltv_expiry": {
+                    "type": "integer"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "payee": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "payment_secret": {
+                    "type": "string"
+                },
+                "route_hints": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/bolt11.HopHint"
+                        }
+                    }
+                }
+            }
+        },
+        "main.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "wallet_not_found"
+                },
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
                 "error": {
+                    "type": "string",
+                    "example": "sender wallet 'WALLET_JOSIP' not found"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "sender wallet 'WALLET_JOSIP' not found"
+                },
+                "retriable": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "main.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "wallets": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "boolean"
+                    }
+                }
+            }
+        },
+        "main.InvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "invoice": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "wallet": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.KeysendPaymentRequest": {
+            "type": "object",
+            "required": [
+                "euro_amount",
+                "pubkey",
+                "sender"
+            ],
+            "properties": {
+                "euro_amount": {
+                    "type": "number",
+                    "example": 0.000001
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "pubkey": {
+                    "type": "string",
+                    "example": "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"
+                },
+                "sender": {
+                    "type": "string",
+                    "example": "WALLET_JOSIP"
+                },
+                "tlv_records": {
+                    "description": "Optional custom records with types of at least 65536 and hex encoded values",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/nip47.TLVRecord"
+                    }
+                }
+            }
+        },
+        "main.KeysendPaymentResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "fees_paid": {
+                    "type": "integer"
+                },
+                "max_fee_msats": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "preimage": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.LNURLWithdrawRequest": {
+            "type": "object",
+            "required": [
+                "lnurl"
+            ],
+            "properties": {
+                "amount_msats": {
+                    "description": "Optional amount, the most the service allows by default",
+                    "type": "integer",
+                    "example": 1000000
+                },
+                "lnurl": {
+                    "type": "string",
+                    "example": "LNURL1DP68GURN8GHJ7..."
+                }
+            }
+        },
+        "main.LNURLWithdrawResponse": {
+            "type": "object",
+            "properties": {
+                "invoice": {
+                    "$ref": "#/definitions/invoices.Invoice"
+                },
+                "max_withdrawable_msats": {
+                    "type": "integer"
+                },
+                "min_withdrawable_msats": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.NwcPaymentRequest": {
+            "type": "object",
+            "required": [
+                "euro_amount",
+                "recipient",
+                "sender"
+            ],
+            "properties": {
+                "euro_amount": {
+                    "type": "number",
+                    "example": 0.000001
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "recipient": {
+                    "description": "Recipient is a configured wallet ID or a Lightning Address (name@domain)",
+                    "type": "string",
+                    "example": "WALLET_VRATA_KRKE"
+                },
+                "sender": {
+                    "type": "string",
+                    "example": "WALLET_JOSIP"
+                }
+            }
+        },
+        "main.NwcPaymentResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "fees_paid": {
+                    "type": "integer"
+                },
+                "max_fee_msats": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "recipient_balance": {
+                    "type": "integer"
+                },
+                "sender_balance": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PayInvoiceRequest": {
+            "type": "object",
+            "required": [
+                "invoice"
+            ],
+            "properties": {
+                "amount_msats": {
+                    "description": "Optional checks against the decoded invoice",
+                    "type": "integer",
+                    "example": 1000000
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Order 1234"
+                },
+                "invoice": {
+                    "type": "string",
+                    "example": "lnbc10u1p..."
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                }
+            }
+        },
+        "main.PayInvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "fees_paid": {
+                    "type": "integer"
+                },
+                "max_fee_msats": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "payee": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "preimage": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PayPageStatus": {
+            "type": "object",
+            "properties": {
+                "paid_at": {
+                    "type": "string"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "quote_expires_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                }
+            }
+        },
+        "main.PaymentRequestResponse": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "euro_amount": {
+                    "type": "number"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "invoice": {
+                    "type": "string"
+                },
+                "paid_at": {
+                    "type": "string"
+                },
+                "paid_msats": {
+                    "type": "integer"
+                },
+                "payment_hash": {
+                    "type": "string"
+                },
+                "payment_hashes": {
+                    "description": "PaymentHashes are the hashes of all invoices issued for the request",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "quote_expires_at": {
+                    "type": "string"
+                },
+                "quoted_at": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://pay.example.com/pay/4f1c..."
                 }
             }
         },
-        "main.HealthResponse": {
+        "main.ScheduleResponse": {
             "type": "object",
             "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "last_run_at": {
+                    "type": "string"
+                },
+                "max_fee_msats": {
+                    "type": "integer"
+                },
+                "max_fee_percent": {
+                    "type": "number"
+                },
+                "missed_runs": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "runs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/schedules.Run"
+                    }
+                },
+                "sender": {
+                    "type": "string"
+                },
                 "status": {
                     "type": "string"
                 },
-                "wallets": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "boolean"
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.SplitPaymentRequest": {
+            "type": "object",
+            "required": [
+                "sender",
+                "shares"
+            ],
+            "properties": {
+                "euro_amount": {
+                    "description": "EuroAmount is the total, which may be left out when every share is a fixed amount",
+                    "type": "number",
+                    "example": 10
+                },
+                "max_fee_msats": {
+                    "description": "Optional fee limits per share, which can only lower the configured ceiling",
+                    "type": "integer",
+                    "example": 1000
+                },
+                "max_fee_percent": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "multi_pay": {
+                    "description": "MultiPay pays all shares with a single NIP-47 multi_pay_invoice request",
+                    "type": "boolean",
+                    "example": false
+                },
+                "sender": {
+                    "type": "string",
+                    "example": "WALLET_JOSIP"
+                },
+                "shares": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.SplitShare"
                     }
                 }
             }
         },
-        "main.NwcPaymentRequest": {
+        "main.SplitShare": {
             "type": "object",
             "required": [
-                "euro_amount",
-                "recipient",
-                "sender"
+                "recipient"
             ],
             "properties": {
                 "euro_amount": {
                     "type": "number",
-                    "example": 0.000001
+                    "example": 0.5
+                },
+                "percent": {
+                    "type": "number",
+                    "example": 90
                 },
                 "recipient": {
+                    "description": "Recipient is a configured wallet ID or a Lightning Address (name@domain)",
                     "type": "string",
-                    "example": "WALLET_VRATA_KRKE"
+                    "example": "WALLET_VENUE"
+                }
+            }
+        },
+        "nip47.TLVRecord": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "rebalance.Run": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "transfers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rebalance.Transfer"
+                    }
+                },
+                "wallets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rebalance.WalletState"
+                    }
+                }
+            }
+        },
+        "rebalance.Transfer": {
+            "type": "object",
+            "properties": {
+                "amount_msats": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "error_code": {
+                    "description": "ErrorCode is the API error code of Error",
+                    "type": "string"
+                },
+                "fees_paid": {
+                    "type": "integer"
+                },
+                "ledger_id": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
                 },
                 "sender": {
-                    "type": "string",
-                    "example": "WALLET_JOSIP"
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
                 }
             }
         },
-        "main.NwcPaymentResponse": {
+        "rebalance.WalletState": {
+            "type": "object",
+            "properties": {
+                "balance_msats": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "max_msats": {
+                    "type": "integer"
+                },
+                "min_msats": {
+                    "type": "integer"
+                },
+                "target_msats": {
+                    "type": "integer"
+                },
+                "wallet": {
+                    "type": "string"
+                }
+            }
+        },
+        "schedules.Run": {
             "type": "object",
             "properties": {
                 "amount_msats": {
                     "type": "integer"
                 },
+                "error": {
+                    "type": "string"
+                },
                 "euro_amount": {
                     "type": "number"
                 },
                 "fees_paid": {
                     "type": "integer"
                 },
-                "message": {
+                "finished_at": {
                     "type": "string"
                 },
-                "recipient_balance": {
+                "id": {
+                    "type": "string"
+                },
+                "ledger_id": {
+                    "type": "string"
+                },
+                "missed": {
+                    "description": "Missed counts the runs a late run stands in for",
                     "type": "integer"
                 },
-                "sender_balance": {
+                "schedule_id": {
+                    "type": "string"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "schedules.Schedule": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "last_run_at": {
+                    "type": "string"
+                },
+                "max_fee_msats": {
                     "type": "integer"
                 },
-                "success": {
-                    "type": "boolean"
+                "max_fee_percent": {
+                    "type": "number"
+                },
+                "missed_runs": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "sender": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhooks.Attempt": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status_code": {
+                    "description": "StatusCode is the HTTP status the endpoint answered with, if it answered",
+                    "type": "integer"
+                }
+            }
+        },
+        "webhooks.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webhooks.Attempt"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "NextAttemptAt is when a pending delivery is tried next",
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "replay_of": {
+                    "description": "ReplayOf is the delivery this one replays",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "wallet": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhooks.Webhook": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Secret signs the deliveries; it is only shown when the webhook is created",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "wallets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         }
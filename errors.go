@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"nwc_app/budget"
+	"nwc_app/lnurl"
+	"nwc_app/nip47"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes reported in ErrorResponse.Code. Programs should act on these rather than on messages.
+const (
+	CodeInvalidRequest          = "invalid_request"
+	CodeBodyTooLarge            = "body_too_large"
+	CodeValidationFailed        = "validation_failed"
+	CodeWalletNotFound          = "wallet_not_found"
+	CodeForbidden               = "forbidden"
+	CodeNotFound                = "not_found"
+	CodeConflict                = "conflict"
+	CodeDuplicatePayment        = "duplicate_payment"
+	CodeInsufficientBalance     = "insufficient_balance"
+	CodeSpendingPolicy          = "spending_policy_violation"
+	CodeInvalidInvoice          = "invalid_invoice"
+	CodeLNURLError              = "lnurl_error"
+	CodeLNURLUnavailable        = "lnurl_unavailable"
+	CodePaymentFailed           = "payment_failed"
+	CodeFeeLimitExceeded        = "fee_limit_exceeded"
+	CodeWalletRateLimited       = "wallet_rate_limited"
+	CodeWalletUnauthorized      = "wallet_unauthorized"
+	CodeWalletError             = "wallet_error"
+	CodeWalletTimeout           = "wallet_timeout"
+	CodeExchangeRateUnavailable = "exchange_rate_unavailable"
+	CodeInternal                = "internal_error"
+)
+
+// APIError is an error together with how it is reported: its HTTP status, code,
+// optional details and whether the same request may succeed when sent again
+type APIError struct {
+	Status    int
+	Code      string
+	Message   string
+	Details   map[string]any
+	Retriable bool
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// invalidRequest reports a request that could not be read, such as malformed JSON
+func invalidRequest(format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// bodyTooLarge reports a request body or upload over its size limit
+func bodyTooLarge(format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusRequestEntityTooLarge, Code: CodeBodyTooLarge, Message: fmt.Sprintf(format, args...)}
+}
+
+// validationFailed reports a well formed request with values that cannot be used
+func validationFailed(format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound reports a missing record, such as a batch or schedule
+func notFound(format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// internalError reports a failure of the service itself, such as a store that cannot be written
+func internalError(format string, args ...any) *APIError {
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: fmt.Sprintf(format, args...)}
+}
+
+// WalletNotFoundError is returned when a wallet ID is not configured
+// Role names what the wallet was used as, such as sender, and may be empty
+type WalletNotFoundError struct {
+	Role   string
+	Wallet string
+}
+
+func (e *WalletNotFoundError) Error() string {
+	if e.Role == "" {
+		return fmt.Sprintf("wallet '%s' not found", e.Wallet)
+	}
+	return fmt.Sprintf("%s wallet '%s' not found", e.Role, e.Wallet)
+}
+
+// InsufficientFundsError is returned when the sender cannot cover a payment and its maximum fee
+type InsufficientFundsError struct {
+	Wallet         string
+	NeededMsats    int64
+	AvailableMsats int64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds in sender wallet: %d msat needed, %d msat available", e.NeededMsats, e.AvailableMsats)
+}
+
+// DuplicatePaymentError is returned when an invoice has already been paid or is being paid
+type DuplicatePaymentError struct {
+	PaymentHash string
+}
+
+func (e *DuplicatePaymentError) Error() string {
+	return fmt.Sprintf("invoice %s has already been paid or is being paid", e.PaymentHash)
+}
+
+// FeeLimitExceededError is returned when a payment settled but its wallet paid more in
+// routing fees than the ceiling. The payment went through and is in the ledger.
+type FeeLimitExceededError struct {
+	Wallet        string
+	LedgerID      string
+	PaymentHash   string
+	Preimage      string
+	FeesPaidMsats int64
+	MaxFeeMsats   int64
+}
+
+func (e *FeeLimitExceededError) Error() string {
+	return fmt.Sprintf("payment settled but %s paid %d msat in fees, above the %d msat limit", e.Wallet, e.FeesPaidMsats, e.MaxFeeMsats)
+}
+
+// WalletError is returned when a configured wallet could not be used or answered
+// a request with something unusable
+type WalletError struct {
+	Wallet string
+	Err    error
+}
+
+func (e *WalletError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WalletError) Unwrap() error {
+	return e.Err
+}
+
+// ExchangeRateError is returned when the BTC/EUR exchange rate cannot be fetched
+type ExchangeRateError struct {
+	Err error
+}
+
+func (e *ExchangeRateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExchangeRateError) Unwrap() error {
+	return e.Err
+}
+
+// toAPIError works out how err is reported
+// Errors from wallets are classified by their NIP-47 code; errors of unknown
+// origin are internal errors
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	var notFoundErr *WalletNotFoundError
+	var fundsErr *InsufficientFundsError
+	var duplicateErr *DuplicatePaymentError
+	var feeErr *FeeLimitExceededError
+	var violation *budget.Violation
+	var invoiceErr *InvoiceError
+	var rateErr *ExchangeRateError
+	var unavailableErr *lnurl.UnavailableError
+	var walletErr *WalletError
+	var nip47Err *nip47.Error
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &notFoundErr):
+		return &APIError{Status: http.StatusNotFound, Code: CodeWalletNotFound, Message: err.Error(),
+			Details: map[string]any{"wallet": notFoundErr.Wallet, "role": notFoundErr.Role}}
+	case errors.As(err, &fundsErr):
+		return &APIError{Status: http.StatusPaymentRequired, Code: CodeInsufficientBalance, Message: err.Error(),
+			Details: map[string]any{"wallet": fundsErr.Wallet, "needed_msats": fundsErr.NeededMsats, "available_msats": fundsErr.AvailableMsats}}
+	case errors.As(err, &duplicateErr):
+		return &APIError{Status: http.StatusConflict, Code: CodeDuplicatePayment, Message: err.Error(),
+			Details: map[string]any{"payment_hash": duplicateErr.PaymentHash}}
+	case errors.As(err, &feeErr):
+		return &APIError{Status: http.StatusBadGateway, Code: CodeFeeLimitExceeded, Message: err.Error(),
+			Details: map[string]any{"wallet": feeErr.Wallet, "ledger_id": feeErr.LedgerID, "payment_hash": feeErr.PaymentHash,
+				"preimage": feeErr.Preimage, "fees_paid": feeErr.FeesPaidMsats, "max_fee_msats": feeErr.MaxFeeMsats}}
+	case errors.As(err, &violation):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeSpendingPolicy, Message: err.Error(),
+			Details: map[string]any{"wallet": violation.Wallet, "rule": violation.Rule}}
+	case errors.As(err, &invoiceErr):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeInvalidInvoice, Message: err.Error()}
+	case lnurl.IsError(err):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeLNURLError, Message: err.Error()}
+	case errors.As(err, &unavailableErr):
+		return &APIError{Status: http.StatusBadGateway, Code: CodeLNURLUnavailable, Message: err.Error(), Retriable: true}
+	case errors.As(err, &rateErr):
+		return &APIError{Status: http.StatusBadGateway, Code: CodeExchangeRateUnavailable, Message: err.Error(), Retriable: true}
+	}
+
+	// Failures reported by or on the way to a wallet
+	apiErr = &APIError{Status: http.StatusBadGateway, Code: CodeWalletError, Message: err.Error()}
+	switch nip47.Classify(err) {
+	case nip47.ClassRateLimited:
+		apiErr.Code = CodeWalletRateLimited
+	case nip47.ClassTimeout:
+		apiErr.Status, apiErr.Code = http.StatusGatewayTimeout, CodeWalletTimeout
+	case nip47.ClassInsufficientBalance:
+		apiErr.Status, apiErr.Code = http.StatusPaymentRequired, CodeInsufficientBalance
+	case nip47.ClassPaymentFailed:
+		apiErr.Code = CodePaymentFailed
+	case nip47.ClassUnauthorized:
+		apiErr.Code = CodeWalletUnauthorized
+	case nip47.ClassNotFound:
+		apiErr.Status, apiErr.Code = http.StatusNotFound, CodeNotFound
+	default:
+		if !errors.As(err, &nip47Err) && !errors.As(err, &walletErr) {
+			return internalError("%s", err.Error())
+		}
+	}
+	// A payment that timed out may still go through, so only failures known to
+	// have left nothing behind are worth sending again
+	apiErr.Retriable = apiErr.Code == CodeWalletRateLimited || apiErr.Code == CodePaymentFailed
+	if errors.As(err, &nip47Err) {
+		apiErr.Details = map[string]any{"nip47_code": nip47Err.Code}
+	}
+	if errors.As(err, &walletErr) {
+		if apiErr.Details == nil {
+			apiErr.Details = map[string]any{}
+		}
+		apiErr.Details["wallet"] = walletErr.Wallet
+	}
+
+	return apiErr
+}
+
+// respondError writes err as an ErrorResponse with the status it maps to
+func respondError(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	c.JSON(apiErr.Status, errorResponse(apiErr))
+}
+
+// errorResponse is the body reporting an error
+func errorResponse(apiErr *APIError) ErrorResponse {
+	return ErrorResponse{
+		Error:     apiErr.Message,
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Details:   apiErr.Details,
+		Retriable: apiErr.Retriable,
+	}
+}
@@ -0,0 +1,131 @@
+// Package events distributes notable changes, such as a settled invoice,
+// to the parts of the service that react to them
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Event types
+const (
+	TypePaymentSucceeded = "payment.succeeded"
+	TypePaymentFailed    = "payment.failed"
+	// TypePaymentReceived and TypePaymentSent are payments a wallet reported itself,
+	// including ones made outside this service
+	TypePaymentReceived = "payment.received"
+	TypePaymentSent     = "payment.sent"
+	TypeInvoicePaid     = "invoice.paid"
+	TypeInvoiceExpired  = "invoice.expired"
+	// TypePaymentRequestPaid is a hosted payment request whose invoice settled
+	TypePaymentRequestPaid = "payment_request.paid"
+	// TypeWalletUnhealthy is a wallet that stopped answering, TypeWalletHealthy one that answers again
+	TypeWalletUnhealthy = "wallet.unhealthy"
+	TypeWalletHealthy   = "wallet.healthy"
+	// TypeBalanceLow is a wallet whose balance fell below its configured threshold
+	TypeBalanceLow = "balance.low"
+)
+
+// Types lists every event type that is published
+var Types = []string{
+	TypePaymentSucceeded,
+	TypePaymentFailed,
+	TypePaymentReceived,
+	TypePaymentSent,
+	TypeInvoicePaid,
+	TypeInvoiceExpired,
+	TypePaymentRequestPaid,
+	TypeWalletUnhealthy,
+	TypeWalletHealthy,
+	TypeBalanceLow,
+}
+
+// Event is a single change published on the bus.
+// Seq numbers the events kept in the log in the order they were published.
+type Event struct {
+	ID        string    `json:"id"`
+	Seq       int64     `json:"seq,omitempty"`
+	Type      string    `json:"type"`
+	Wallet    string    `json:"wallet,omitempty"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Bus hands every published event to all current subscribers
+type Bus struct {
+	history     *Log
+	mu          sync.Mutex
+	subscribers map[int]chan Event
+	next        int
+}
+
+// NewBus creates a bus without subscribers that records every event in history, if set
+func NewBus(history *Log) *Bus {
+	return &Bus{history: history, subscribers: make(map[int]chan Event)}
+}
+
+// Publish records an event and sends it to every subscriber.
+// A subscriber that is not keeping up misses the event rather than blocking the publisher.
+func (b *Bus) Publish(eventType, walletID string, data any) Event {
+	event := Event{
+		ID:        store.NewID(),
+		Type:      eventType,
+		Wallet:    walletID,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	log.Printf("Event %s for %s", eventType, walletID)
+
+	// Events are logged and sent while holding the lock so subscribers see them in log order
+	b.mu.Lock()
+	if b.history != nil {
+		if err := b.history.Append(&event); err != nil {
+			log.Printf("Failed to log %s event %s: %v", eventType, event.ID, err)
+		}
+	}
+
+	for id, subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Printf("Event subscriber %d is full, dropped %s event %s", id, eventType, event.ID)
+		}
+	}
+	b.mu.Unlock()
+
+	// Old events are dropped once other publishers can go ahead
+	if b.history != nil {
+		if err := b.history.Trim(); err != nil {
+			log.Printf("Failed to trim the event log: %v", err)
+		}
+	}
+
+	return event
+}
+
+// Subscribe returns a channel receiving published events and a function
+// that ends the subscription and closes the channel
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	events := make(chan Event, buffer)
+	b.subscribers[id] = events
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers, id)
+			close(events)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"nwc_app/store"
+)
+
+// Log keeps the most recent events so a client that lost its connection can
+// catch up on what it missed.
+// Appending only adds the event to the store's journal; events beyond the size
+// of the log are dropped separately by Trim, so publishers never wait for it.
+type Log struct {
+	events *store.Collection[Event]
+	size   int
+
+	mu  sync.Mutex
+	seq int64
+	// ids lists the logged events oldest first
+	ids []string
+}
+
+// OpenLog loads the event log stored in dir, which keeps the last size events
+func OpenLog(dir string, size int) (*Log, error) {
+	events, err := store.Open[Event](dir, "events")
+	if err != nil {
+		return nil, err
+	}
+
+	logged := events.List()
+	sort.Slice(logged, func(i, j int) bool {
+		return logged[i].Seq < logged[j].Seq
+	})
+
+	l := &Log{events: events, size: size}
+	for _, event := range logged {
+		l.seq = event.Seq
+		l.ids = append(l.ids, event.ID)
+	}
+	return l, l.Trim()
+}
+
+// Append numbers an event after the ones already logged and stores it
+func (l *Log) Append(event *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event.Seq = l.seq
+
+	if err := l.events.Put(event.ID, *event); err != nil {
+		return err
+	}
+	l.ids = append(l.ids, event.ID)
+	return nil
+}
+
+// Trim drops the oldest events beyond the size of the log
+func (l *Log) Trim() error {
+	l.mu.Lock()
+	excess := len(l.ids) - l.size
+	if excess <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	dropped := slices.Clone(l.ids[:excess])
+	l.ids = slices.Delete(l.ids, 0, excess)
+	l.mu.Unlock()
+
+	return l.events.Transaction(func(tx *store.Tx[Event]) error {
+		for _, id := range dropped {
+			tx.Delete(id)
+		}
+		return nil
+	})
+}
+
+// Since returns the logged events that followed the event with the given ID, oldest first.
+// It reports false, returning every logged event, when that event is no longer in the log.
+func (l *Log) Since(id string) ([]Event, bool) {
+	l.mu.Lock()
+	ids := slices.Clone(l.ids)
+	l.mu.Unlock()
+
+	start := 0
+	found := false
+	if i := slices.Index(ids, id); i >= 0 {
+		start, found = i+1, true
+	}
+
+	var since []Event
+	for _, id := range ids[start:] {
+		// Events being trimmed may already be gone from the store
+		if event, ok := l.events.Get(id); ok {
+			since = append(since, event)
+		}
+	}
+	return since, found
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func appendEvents(t *testing.T, l *Log, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		if err := l.Append(&Event{ID: id, Type: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func eventIDs(events []Event) string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestLogSince(t *testing.T) {
+	l, err := OpenLog(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEvents(t, l, "a", "b", "c")
+
+	tests := []struct {
+		id        string
+		want      string
+		wantFound bool
+	}{
+		{"a", "[b c]", true},
+		{"c", "[]", true},
+		{"gone", "[a b c]", false},
+		{"", "[a b c]", false},
+	}
+
+	for _, test := range tests {
+		since, found := l.Since(test.id)
+		if got := eventIDs(since); got != test.want || found != test.wantFound {
+			t.Fatalf("Since(%q) = %s, %t, want %s, %t", test.id, got, found, test.want, test.wantFound)
+		}
+	}
+}
+
+func TestLogTrim(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenLog(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEvents(t, l, "a", "b", "c", "d", "e")
+
+	// Appending alone never drops events
+	if since, _ := l.Since(""); eventIDs(since) != "[a b c d e]" {
+		t.Fatalf("Since() before Trim = %s", eventIDs(since))
+	}
+
+	if err := l.Trim(); err != nil {
+		t.Fatal(err)
+	}
+	if since, found := l.Since("b"); eventIDs(since) != "[c d e]" || found {
+		t.Fatalf("Since(b) after Trim = %s, %t, want [c d e], false", eventIDs(since), found)
+	}
+	if l.events.Len() != 3 {
+		t.Fatalf("store holds %d events, want 3", l.events.Len())
+	}
+}
+
+func TestLogReopen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenLog(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEvents(t, l, "a", "b", "c", "d")
+	l.events.Close()
+
+	// Reopening with a smaller size trims the oldest events and keeps numbering after the last one
+	l, err = OpenLog(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since, _ := l.Since(""); eventIDs(since) != "[c d]" {
+		t.Fatalf("Since() after reopening = %s, want [c d]", eventIDs(since))
+	}
+
+	event := Event{ID: "e"}
+	if err := l.Append(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Seq != 5 {
+		t.Fatalf("Append() seq = %d, want 5", event.Seq)
+	}
+}
+
+func TestBusTrimsLog(t *testing.T) {
+	l, err := OpenLog(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := NewBus(l)
+
+	for range 5 {
+		bus.Publish("test", "", nil)
+	}
+	if since, _ := l.Since(""); len(since) != 2 {
+		t.Fatalf("log holds %d events after publishing, want 2", len(since))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/middleware"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// eventStreamHeartbeat is how often an idle event stream sends a comment so
+// proxies do not close the connection
+const eventStreamHeartbeat = 15 * time.Second
+
+// eventPermissions maps event types to the permission needed to receive them
+var eventPermissions = map[string]string{
+	events.TypePaymentSucceeded:   middleware.PermissionPayments,
+	events.TypePaymentFailed:      middleware.PermissionPayments,
+	events.TypePaymentReceived:    middleware.PermissionInvoices,
+	events.TypePaymentSent:        middleware.PermissionPayments,
+	events.TypeInvoicePaid:        middleware.PermissionInvoices,
+	events.TypeInvoiceExpired:     middleware.PermissionInvoices,
+	events.TypePaymentRequestPaid: middleware.PermissionInvoices,
+	events.TypeWalletUnhealthy:    middleware.PermissionWallets,
+	events.TypeWalletHealthy:      middleware.PermissionWallets,
+	events.TypeBalanceLow:         middleware.PermissionWallets,
+}
+
+// eventFilter selects the events a client receives
+type eventFilter struct {
+	types   []string
+	wallets []string
+}
+
+// newEventFilter validates the event types and wallets a client asked for.
+// Without types the client receives every type its principal may see; asking for
+// a type it may not see is refused. Without wallets events of all wallets pass.
+func newEventFilter(principal *middleware.Principal, types, wallets []string) (eventFilter, error) {
+	var filter eventFilter
+
+	for _, eventType := range types {
+		permission, known := eventPermissions[eventType]
+		if !known {
+			return filter, validationFailed("unknown event type %q", eventType)
+		}
+		if principal == nil || !principal.Can(permission) {
+			return filter, &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "missing permission: " + permission}
+		}
+		filter.types = append(filter.types, eventType)
+	}
+	if len(types) == 0 {
+		for _, eventType := range events.Types {
+			if principal != nil && principal.Can(eventPermissions[eventType]) {
+				filter.types = append(filter.types, eventType)
+			}
+		}
+		if len(filter.types) == 0 {
+			return filter, &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "missing permission to receive any event"}
+		}
+	}
+
+	for _, walletID := range wallets {
+		walletID = strings.ToUpper(walletID)
+		if _, exists := walletURIs[walletID]; !exists {
+			return filter, &WalletNotFoundError{Wallet: walletID}
+		}
+		filter.wallets = append(filter.wallets, walletID)
+	}
+
+	return filter, nil
+}
+
+// matches reports whether the event passes the filter
+func (f eventFilter) matches(event events.Event) bool {
+	if !slices.Contains(f.types, event.Type) {
+		return false
+	}
+	return len(f.wallets) == 0 || slices.Contains(f.wallets, event.Wallet)
+}
+
+// eventCursor remembers the last logged event a client received, so the live
+// events that were also replayed from the log are not sent twice
+type eventCursor struct {
+	seq int64
+}
+
+// advance reports whether the event is new to the client and moves past it
+func (cur *eventCursor) advance(event events.Event) bool {
+	if event.Seq != 0 && event.Seq <= cur.seq {
+		return false
+	}
+	cur.seq = max(cur.seq, event.Seq)
+	return true
+}
+
+// missedEvents returns the logged events that followed the last one a client received
+func missedEvents(lastEventID string) []events.Event {
+	if lastEventID == "" {
+		return nil
+	}
+
+	missed, found := eventLog.Since(lastEventID)
+	if !found {
+		log.Printf("Event %s is no longer logged, resuming from the oldest logged event", lastEventID)
+	}
+	return missed
+}
+
+// queryList returns the values of a query parameter given repeatedly or comma separated
+func queryList(c *gin.Context, name string) []string {
+	var list []string
+	for _, value := range c.QueryArray(name) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// @Summary      Stream events
+// @Description  Server-Sent Events stream of payment, invoice and wallet health events as they happen.
+// @Description  Each event is sent with its ID, its type as the event name and the event as JSON data. Only event types the credentials have the permission for are sent: payments for payment events, invoices for invoice, payment request and payment.received events and wallets for wallet events.
+// @Description  A client reconnecting with the Last-Event-ID header, or the last_event_id parameter, first receives the events it missed that are still in the event log.
+// @Tags         events
+// @Produce      text/event-stream
+// @Param        api_key        query   string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Param        type           query   string  false  "Comma separated event types to receive, all permitted types by default"
+// @Param        wallet         query   string  false  "Comma separated wallet IDs to receive events of, all wallets by default"
+// @Param        last_event_id  query   string  false  "ID of the last event received, when the Last-Event-ID header cannot be set"
+// @Param        Last-Event-ID  header  string  false  "ID of the last event received"
+// @Success      200  {string}  string  "Event stream"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Router       /events [get]
+func eventStreamHandler(c *gin.Context) {
+	filter, err := newEventFilter(middleware.GetPrincipal(c), queryList(c, "type"), queryList(c, "wallet"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Subscribe before reading the log so no event falls between the two
+	published, cancel := eventBus.Subscribe(100)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	var cursor eventCursor
+	send := func(event events.Event) bool {
+		if !cursor.advance(event) || !filter.matches(event) {
+			return true
+		}
+		if err := sse.Encode(c.Writer, sse.Event{Id: event.ID, Event: event.Type, Data: event}); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	for _, event := range missedEvents(lastEventID) {
+		if !send(event) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-published:
+			if !ok || !send(event) {
+				return
+			}
+		}
+	}
+}
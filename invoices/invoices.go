@@ -0,0 +1,114 @@
+// Package invoices keeps track of the invoices created through the API
+// until they are paid or expire
+package invoices
+
+import (
+	"errors"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Invoice statuses
+const (
+	StatusOpen    = "open"
+	StatusPaid    = "paid"
+	StatusExpired = "expired"
+)
+
+// Invoice is an invoice created on a configured wallet.
+// Comment is the payer's note on a Lightning Address payment.
+type Invoice struct {
+	PaymentHash string     `json:"payment_hash"`
+	Wallet      string     `json:"wallet"`
+	Invoice     string     `json:"invoice"`
+	Status      string     `json:"status"`
+	AmountMsats int64      `json:"amount_msats"`
+	EuroAmount  float64    `json:"euro_amount,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+	Preimage    string     `json:"preimage,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// errUnchanged stops an update that would not change the record
+var errUnchanged = errors.New("invoice unchanged")
+
+// Store is the persistent list of invoices, keyed by payment hash
+type Store struct {
+	records *store.Collection[Invoice]
+}
+
+// Open loads the invoices stored in dir
+func Open(dir string) (*Store, error) {
+	records, err := store.Open[Invoice](dir, "invoices")
+	if err != nil {
+		return nil, err
+	}
+	return &Store{records: records}, nil
+}
+
+// Add records a newly created invoice as open
+func (s *Store) Add(invoice Invoice) (Invoice, error) {
+	invoice.Status = StatusOpen
+	invoice.UpdatedAt = time.Now().UTC()
+	return invoice, s.records.Put(invoice.PaymentHash, invoice)
+}
+
+// Get returns the invoice with the given payment hash
+func (s *Store) Get(paymentHash string) (Invoice, bool) {
+	return s.records.Get(paymentHash)
+}
+
+// Pending returns the invoices that are neither paid nor expired
+func (s *Store) Pending() []Invoice {
+	var pending []Invoice
+	for _, invoice := range s.records.List() {
+		if invoice.Status == StatusOpen {
+			pending = append(pending, invoice)
+		}
+	}
+	return pending
+}
+
+// Settle marks an open invoice as paid.
+// It reports false when the invoice was not open, so callers announce a payment only once.
+func (s *Store) Settle(paymentHash, preimage string, settledAt time.Time) (Invoice, bool, error) {
+	return s.transition(paymentHash, func(invoice *Invoice) {
+		invoice.Status = StatusPaid
+		invoice.Preimage = preimage
+		settled := settledAt.UTC()
+		invoice.SettledAt = &settled
+	})
+}
+
+// Expire marks an open invoice as expired.
+// It reports false when the invoice was not open.
+func (s *Store) Expire(paymentHash string) (Invoice, bool, error) {
+	return s.transition(paymentHash, func(invoice *Invoice) {
+		invoice.Status = StatusExpired
+	})
+}
+
+// transition applies change to an open invoice
+func (s *Store) transition(paymentHash string, change func(invoice *Invoice)) (Invoice, bool, error) {
+	invoice, err := s.records.Update(paymentHash, func(invoice *Invoice) error {
+		if invoice.Status != StatusOpen {
+			return errUnchanged
+		}
+		change(invoice)
+		invoice.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if errors.Is(err, errUnchanged) {
+		return invoice, false, nil
+	}
+	if err != nil {
+		return invoice, false, err
+	}
+
+	return invoice, true, nil
+}
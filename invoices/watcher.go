@@ -0,0 +1,112 @@
+package invoices
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/nip47"
+)
+
+// expiryGrace is how long after expiry an invoice the wallet cannot be asked
+// about is still kept open, in case it was paid just before expiring
+const expiryGrace = time.Hour
+
+// LookupFunc asks a wallet for its record of an invoice
+type LookupFunc func(ctx context.Context, walletID, paymentHash string) (*nip47.Transaction, error)
+
+// Watcher polls open invoices until they are paid or expire
+type Watcher struct {
+	Store    *Store
+	Lookup   LookupFunc
+	Events   *events.Bus
+	Interval time.Duration
+}
+
+// Run polls every Interval until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, invoice := range w.Store.Pending() {
+				if _, err := w.Check(ctx, invoice); err != nil {
+					log.Printf("Failed to check invoice %s on %s: %v", invoice.PaymentHash, invoice.Wallet, err)
+				}
+			}
+		}
+	}
+}
+
+// Check looks the invoice up on its wallet and records whether it has been paid
+// or has expired, publishing an event the first time either happens
+func (w *Watcher) Check(ctx context.Context, invoice Invoice) (Invoice, error) {
+	if invoice.Status != StatusOpen {
+		return invoice, nil
+	}
+
+	now := time.Now()
+	transaction, err := w.Lookup(ctx, invoice.Wallet, invoice.PaymentHash)
+	if err != nil {
+		if now.After(invoice.ExpiresAt.Add(expiryGrace)) {
+			return w.expire(invoice)
+		}
+		return invoice, err
+	}
+
+	if transaction.Settled() {
+		return w.settle(invoice, transaction)
+	}
+	if !now.Before(invoice.ExpiresAt) {
+		return w.expire(invoice)
+	}
+
+	return invoice, nil
+}
+
+// Notify settles an open invoice of the wallet the moment the wallet reports it paid,
+// without waiting for the next poll. It reports false when the payment is not for
+// an open invoice created through the API.
+func (w *Watcher) Notify(walletID string, transaction *nip47.Transaction) (Invoice, bool, error) {
+	invoice, ok := w.Store.Get(transaction.PaymentHash)
+	if !ok || invoice.Wallet != walletID || invoice.Status != StatusOpen || !transaction.Settled() {
+		return invoice, false, nil
+	}
+
+	settled, err := w.settle(invoice, transaction)
+	return settled, err == nil && settled.Status == StatusPaid, err
+}
+
+// settle records the invoice as paid. Wallets that report a settled state
+// without settled_at are taken to have settled it now.
+func (w *Watcher) settle(invoice Invoice, transaction *nip47.Transaction) (Invoice, error) {
+	settledAt := time.Now()
+	if transaction.SettledAt > 0 {
+		settledAt = time.Unix(transaction.SettledAt, 0)
+	}
+
+	settled, changed, err := w.Store.Settle(invoice.PaymentHash, transaction.Preimage, settledAt)
+	if err != nil || !changed {
+		return settled, err
+	}
+
+	log.Printf("Invoice %s on %s paid", settled.PaymentHash, settled.Wallet)
+	w.Events.Publish(events.TypeInvoicePaid, settled.Wallet, settled)
+	return settled, nil
+}
+
+func (w *Watcher) expire(invoice Invoice) (Invoice, error) {
+	expired, changed, err := w.Store.Expire(invoice.PaymentHash)
+	if err != nil || !changed {
+		return expired, err
+	}
+
+	log.Printf("Invoice %s on %s expired unpaid", expired.PaymentHash, expired.Wallet)
+	w.Events.Publish(events.TypeInvoiceExpired, expired.Wallet, expired)
+	return expired, nil
+}
@@ -0,0 +1,104 @@
+package invoices
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/nip47"
+
+	"github.com/untreu2/go-nwc"
+)
+
+func newWatcher(t *testing.T, transaction *nip47.Transaction, lookupErr error) *Watcher {
+	t.Helper()
+
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Watcher{
+		Store: store,
+		Lookup: func(ctx context.Context, walletID, paymentHash string) (*nip47.Transaction, error) {
+			return transaction, lookupErr
+		},
+		Events: events.NewBus(nil),
+	}
+}
+
+func TestWatcherCheck(t *testing.T) {
+	settledAt := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		expiresAt   time.Time
+		transaction *nip47.Transaction
+		err         error
+		want        string
+		settledAt   time.Time
+	}{
+		{"settled_at reported", now.Add(time.Hour), &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{SettledAt: settledAt.Unix(), Preimage: "ab"}}, nil, StatusPaid, settledAt},
+		{"settled state only", now.Add(time.Hour), &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{Preimage: "ab"}, State: nip47.StateSettled}, nil, StatusPaid, now},
+		{"pending", now.Add(time.Hour), &nip47.Transaction{State: nip47.StatePending}, nil, StatusOpen, time.Time{}},
+		{"expired", now.Add(-time.Minute), &nip47.Transaction{State: nip47.StatePending}, nil, StatusExpired, time.Time{}},
+		{"settled after expiry", now.Add(-time.Minute), &nip47.Transaction{State: nip47.StateSettled}, nil, StatusPaid, now},
+		{"lookup failed", now.Add(-time.Minute), nil, errors.New("relay down"), StatusOpen, time.Time{}},
+		{"lookup failed long after expiry", now.Add(-2 * expiryGrace), nil, errors.New("relay down"), StatusExpired, time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			watcher := newWatcher(t, test.transaction, test.err)
+			invoice, err := watcher.Store.Add(Invoice{PaymentHash: "hash", Wallet: "WALLET", ExpiresAt: test.expiresAt})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checked, err := watcher.Check(context.Background(), invoice)
+			if (err != nil) != (test.want == StatusOpen && test.err != nil) {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if checked.Status != test.want {
+				t.Fatalf("Check() status = %s, want %s", checked.Status, test.want)
+			}
+
+			if test.want != StatusPaid {
+				if checked.SettledAt != nil {
+					t.Fatalf("Check() settled_at = %s, want none", checked.SettledAt)
+				}
+				return
+			}
+			if checked.SettledAt == nil || checked.SettledAt.Sub(test.settledAt).Abs() > time.Minute {
+				t.Fatalf("Check() settled_at = %v, want %s", checked.SettledAt, test.settledAt)
+			}
+		})
+	}
+}
+
+func TestWatcherNotify(t *testing.T) {
+	watcher := newWatcher(t, nil, nil)
+	watcher.Store.Add(Invoice{PaymentHash: "hash", Wallet: "WALLET", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if _, ok, _ := watcher.Notify("OTHER", &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{PaymentHash: "hash"}, State: nip47.StateSettled}); ok {
+		t.Fatal("Notify() settled an invoice of another wallet")
+	}
+	if _, ok, _ := watcher.Notify("WALLET", &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{PaymentHash: "hash"}, State: nip47.StatePending}); ok {
+		t.Fatal("Notify() settled an unpaid invoice")
+	}
+
+	settled, ok, err := watcher.Notify("WALLET", &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{PaymentHash: "hash"}, State: nip47.StateSettled})
+	if err != nil || !ok {
+		t.Fatalf("Notify() = %t, %v, want the invoice settled", ok, err)
+	}
+	if settled.Status != StatusPaid || settled.SettledAt == nil || time.Since(*settled.SettledAt) > time.Minute {
+		t.Fatalf("Notify() = %+v, want it paid now", settled)
+	}
+
+	if _, ok, _ := watcher.Notify("WALLET", &nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{PaymentHash: "hash"}, State: nip47.StateSettled}); ok {
+		t.Fatal("Notify() settled the invoice twice")
+	}
+}
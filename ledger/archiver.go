@@ -0,0 +1,41 @@
+package ledger
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Archiver keeps the ledger to the entries of the last Retention, moving older ones
+// to the monthly archives every Interval
+type Archiver struct {
+	Ledger *Ledger
+	// Retention is how long entries stay in the ledger; zero keeps them forever
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// Run archives old entries right away and then every Interval until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	if a.Retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		moved, err := a.Ledger.Archive(time.Now().Add(-a.Retention))
+		if err != nil {
+			log.Printf("Failed to archive ledger entries: %v", err)
+		} else if moved > 0 {
+			log.Printf("Archived %d ledger entries older than %s", moved, a.Retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
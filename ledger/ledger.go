@@ -0,0 +1,296 @@
+// Package ledger records the payments made through the API
+package ledger
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Entry kinds
+const (
+	KindPayment = "payment"
+	KindInvoice = "invoice"
+	// KindLightningAddress is a payment to a Lightning Address through LNURL-pay
+	KindLightningAddress = "lightning_address"
+	// KindKeysend is a payment pushed to a node pubkey without an invoice
+	KindKeysend = "keysend"
+	// KindRebalance is a payment between configured wallets made by the rebalancer
+	KindRebalance = "rebalance"
+	// KindReceived is a payment a wallet received, reported by the wallet itself
+	KindReceived = "received"
+)
+
+// Entry statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Entry is a single payment in the ledger.
+// BatchID links the payments of a split or batch paid from prepared invoices.
+type Entry struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Sender      string    `json:"sender"`
+	Recipient   string    `json:"recipient"`
+	AmountMsats int64     `json:"amount_msats"`
+	FeesMsats   int64     `json:"fees_msats"`
+	EuroAmount  float64   `json:"euro_amount"`
+	PaymentHash string    `json:"payment_hash,omitempty"`
+	BatchID     string    `json:"batch_id,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Counts reports whether the entry counts against the sender's budget.
+// Pending entries count so concurrent payments cannot overspend.
+func (e Entry) Counts() bool {
+	return e.Status == StatusPending || e.Status == StatusSucceeded
+}
+
+// Ledger is the persistent list of payments.
+// Entries are indexed by sender, so budget checks only read the sender's own entries,
+// and received payments by recipient and payment hash, so repeated notifications are found directly.
+type Ledger struct {
+	dir     string
+	entries *store.Collection[Entry]
+
+	// mu serialises the writes that add or remove entries, keeping the indexes in step
+	mu       sync.RWMutex
+	bySender map[string][]string
+	received map[receivedKey]string
+}
+
+// receivedKey identifies a payment a wallet received
+type receivedKey struct {
+	recipient, paymentHash string
+}
+
+// Open loads the ledger stored in dir
+func Open(dir string) (*Ledger, error) {
+	entries, err := store.Open[Entry](dir, "ledger")
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Ledger{dir: dir, entries: entries, bySender: make(map[string][]string), received: make(map[receivedKey]string)}
+	for _, entry := range entries.List() {
+		l.index(entry)
+	}
+	return l, nil
+}
+
+// index adds an entry to the sender and received payment indexes
+func (l *Ledger) index(entry Entry) {
+	if entry.Sender != "" {
+		l.bySender[entry.Sender] = append(l.bySender[entry.Sender], entry.ID)
+	}
+	if entry.Kind == KindReceived {
+		l.received[receivedKey{entry.Recipient, entry.PaymentHash}] = entry.ID
+	}
+}
+
+// unindex removes an entry from the sender and received payment indexes
+func (l *Ledger) unindex(entry Entry) {
+	key := receivedKey{entry.Recipient, entry.PaymentHash}
+	if entry.Kind == KindReceived && l.received[key] == entry.ID {
+		delete(l.received, key)
+	}
+
+	ids := l.bySender[entry.Sender]
+	if i := slices.Index(ids, entry.ID); i >= 0 {
+		l.bySender[entry.Sender] = slices.Delete(ids, i, i+1)
+	}
+	if len(l.bySender[entry.Sender]) == 0 {
+		delete(l.bySender, entry.Sender)
+	}
+}
+
+// senderEntries returns the entries paid by sender in no particular order
+func (l *Ledger) senderEntries(sender string) []Entry {
+	var entries []Entry
+	for _, id := range l.bySender[sender] {
+		if entry, ok := l.entries.Get(id); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Reserve records a new pending entry after check approves it.
+// check receives the sender's earlier entries and runs while no other entry
+// can be added, so two concurrent payments cannot both pass a budget check.
+func (l *Ledger) Reserve(entry Entry, check func(history []Entry) error) (Entry, error) {
+	now := time.Now().UTC()
+	entry.ID = store.NewID()
+	entry.Status = StatusPending
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if check != nil {
+		if err := check(l.senderEntries(entry.Sender)); err != nil {
+			return entry, err
+		}
+	}
+
+	if err := l.entries.Put(entry.ID, entry); err != nil {
+		return entry, err
+	}
+	l.index(entry)
+	return entry, nil
+}
+
+// Complete marks a pending entry as succeeded and records the fees paid
+func (l *Ledger) Complete(id string, feesMsats int64, paymentHash string) (Entry, error) {
+	return l.entries.Update(id, func(entry *Entry) error {
+		entry.Status = StatusSucceeded
+		entry.FeesMsats = feesMsats
+		if paymentHash != "" {
+			entry.PaymentHash = paymentHash
+		}
+		entry.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Fail marks a pending entry as failed
+func (l *Ledger) Fail(id string, cause error) (Entry, error) {
+	return l.entries.Update(id, func(entry *Entry) error {
+		entry.Status = StatusFailed
+		entry.Error = cause.Error()
+		entry.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Receive records a payment a wallet received as a succeeded entry.
+// It reports false, returning the entry already recorded, when the wallet
+// reported the same payment before.
+func (l *Ledger) Receive(entry Entry) (Entry, bool, error) {
+	now := time.Now().UTC()
+	entry.ID = store.NewID()
+	entry.Kind = KindReceived
+	entry.Status = StatusSucceeded
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if recorded, ok := l.receivedEntry(entry.Recipient, entry.PaymentHash); ok {
+		return recorded, false, nil
+	}
+
+	if err := l.entries.Put(entry.ID, entry); err != nil {
+		return entry, false, err
+	}
+	l.index(entry)
+	return entry, true, nil
+}
+
+// Received returns the entry of a payment recipient received, if it is recorded
+func (l *Ledger) Received(recipient, paymentHash string) (Entry, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.receivedEntry(recipient, paymentHash)
+}
+
+// receivedEntry looks a received payment up in the index
+func (l *Ledger) receivedEntry(recipient, paymentHash string) (Entry, bool) {
+	id, ok := l.received[receivedKey{recipient, paymentHash}]
+	if !ok {
+		return Entry{}, false
+	}
+	return l.entries.Get(id)
+}
+
+// Get returns the entry with the given ID
+func (l *Ledger) Get(id string) (Entry, bool) {
+	return l.entries.Get(id)
+}
+
+// Sent returns the entries paid by sender since the given time, oldest first
+func (l *Ledger) Sent(sender string, since time.Time) []Entry {
+	l.mu.RLock()
+	history := l.senderEntries(sender)
+	l.mu.RUnlock()
+
+	var entries []Entry
+	for _, entry := range history {
+		if !entry.CreatedAt.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+// Archive moves the finished entries created before the given time out of the ledger
+// into one archive per month, stored as ledger-YYYY-MM beside it, and returns how many
+// it moved. Pending entries stay until they finish.
+func (l *Ledger) Archive(before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	months := make(map[string][]Entry)
+	for _, entry := range l.entries.List() {
+		if entry.Status != StatusPending && entry.CreatedAt.Before(before) {
+			month := entry.CreatedAt.UTC().Format("2006-01")
+			months[month] = append(months[month], entry)
+		}
+	}
+
+	moved := 0
+	for month, entries := range months {
+		// Entries are copied before they are removed, so an interrupted run
+		// at worst leaves them in both places until the next one
+		if err := l.copyToArchive("ledger-"+month, entries); err != nil {
+			return moved, err
+		}
+
+		err := l.entries.Transaction(func(tx *store.Tx[Entry]) error {
+			for _, entry := range entries {
+				tx.Delete(entry.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+		for _, entry := range entries {
+			l.unindex(entry)
+		}
+		moved += len(entries)
+	}
+	return moved, nil
+}
+
+// copyToArchive stores entries in the named archive
+func (l *Ledger) copyToArchive(name string, entries []Entry) error {
+	archive, err := store.Open[Entry](l.dir, name)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	return archive.Transaction(func(tx *store.Tx[Entry]) error {
+		for _, entry := range entries {
+			tx.Put(entry.ID, entry)
+		}
+		return nil
+	})
+}
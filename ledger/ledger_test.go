@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"nwc_app/store"
+)
+
+func TestReserveChecksSenderHistory(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Reserve(Entry{Sender: "A", AmountMsats: 1000}, nil)
+	l.Reserve(Entry{Sender: "B", AmountMsats: 5000}, nil)
+
+	var seen []Entry
+	_, err = l.Reserve(Entry{Sender: "A", AmountMsats: 2000}, func(history []Entry) error {
+		seen = history
+		return errors.New("over budget")
+	})
+	if err == nil {
+		t.Fatal("Reserve() ignored the check")
+	}
+	if len(seen) != 1 || seen[0].AmountMsats != 1000 {
+		t.Fatalf("check received %+v, want the one earlier entry of A", seen)
+	}
+	if sent := l.Sent("A", time.Time{}); len(sent) != 1 {
+		t.Fatalf("Sent(A) = %d entries, want the refused one left out", len(sent))
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, _ := l.Reserve(Entry{Sender: "A", AmountMsats: 1000}, nil)
+	l.Complete(done.ID, 0, "hash")
+	pending, _ := l.Reserve(Entry{Sender: "A", AmountMsats: 2000}, nil)
+
+	moved, err := l.Archive(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 {
+		t.Fatalf("Archive() moved %d entries, want the finished one", moved)
+	}
+	if _, ok := l.Get(done.ID); ok {
+		t.Fatal("archived entry still in the ledger")
+	}
+	if sent := l.Sent("A", time.Time{}); len(sent) != 1 || sent[0].ID != pending.ID {
+		t.Fatalf("Sent(A) = %+v, want only the pending entry", sent)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get(done.ID); ok {
+		t.Fatal("archived entry back after reopening the ledger")
+	}
+
+	month := done.CreatedAt.UTC().Format("2006-01")
+	archived, err := store.Open[Entry](dir, "ledger-"+month)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := archived.Get(done.ID); !ok {
+		t.Fatalf("entry not in archive ledger-%s", month)
+	}
+}
+
+func TestReceive(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, added, err := l.Receive(Entry{Recipient: "A", AmountMsats: 1000, PaymentHash: "hash"})
+	if err != nil || !added {
+		t.Fatalf("Receive() = %t, %v, want the payment added", added, err)
+	}
+	if again, added, _ := l.Receive(Entry{Recipient: "A", AmountMsats: 1000, PaymentHash: "hash"}); added || again.ID != first.ID {
+		t.Fatal("Receive() added the same payment twice")
+	}
+	if _, added, _ := l.Receive(Entry{Recipient: "B", AmountMsats: 1000, PaymentHash: "hash"}); !added {
+		t.Fatal("Receive() took a payment to another wallet for a repeat")
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, ok := reopened.Received("A", "hash"); !ok || found.ID != first.ID {
+		t.Fatalf("Received(A) after reopening = %+v, %t, want the first entry", found, ok)
+	}
+	if _, ok := reopened.Received("A", "other"); ok {
+		t.Fatal("Received() found an unknown payment")
+	}
+
+	if _, err := reopened.Archive(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Received("A", "hash"); ok {
+		t.Fatal("Received() found an archived payment")
+	}
+}
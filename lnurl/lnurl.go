@@ -0,0 +1,185 @@
+// Package lnurl implements LNURL-pay and LNURL-withdraw, used to pay Lightning
+// Addresses, redeem withdraw links and receive payments at our own addresses
+package lnurl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"nwc_app/bolt11"
+)
+
+// maxResponseSize bounds how much of an LNURL service's reply is read
+const maxResponseSize = 64 << 10
+
+// Error is a failure reported by an LNURL service or a reply that breaks the protocol
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+// UnavailableError is returned when an LNURL service cannot be reached or its reply cannot be read
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Client talks to LNURL services
+type Client struct {
+	HTTP *http.Client
+	// AllowHTTP permits services without TLS, for local development only
+	AllowHTTP bool
+}
+
+// NewClient creates a client with a request timeout
+func NewClient(allowHTTP bool) *Client {
+	return &Client{
+		HTTP:      &http.Client{Timeout: 10 * time.Second},
+		AllowHTTP: allowHTTP,
+	}
+}
+
+// IsAddress reports whether value looks like a Lightning Address (name@domain)
+func IsAddress(value string) bool {
+	name, domain, ok := strings.Cut(value, "@")
+	return ok && name != "" && domain != "" && !strings.ContainsAny(domain, "@/")
+}
+
+// AddressURL returns the LNURL-pay endpoint of a Lightning Address as defined in LUD-16
+func (c *Client) AddressURL(address string) (string, error) {
+	if !IsAddress(address) {
+		return "", &Error{Reason: fmt.Sprintf("invalid Lightning Address %q", address)}
+	}
+	name, domain, _ := strings.Cut(strings.ToLower(address), "@")
+
+	scheme := "https"
+	if c.AllowHTTP || strings.HasSuffix(domain, ".onion") {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/.well-known/lnurlp/%s", scheme, domain, url.PathEscape(name)), nil
+}
+
+// Decode returns the URL an LNURL points to.
+// Bech32 LNURLs with or without the "lightning:" prefix, LUD-17 schemes such as
+// lnurlw:// and fallback URLs carrying a lightning query parameter are accepted.
+func Decode(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > 10 && strings.EqualFold(value[:10], "lightning:") {
+		value = value[10:]
+	}
+
+	for _, scheme := range []string{"lnurlp://", "lnurlw://", "lnurlc://", "keyauth://"} {
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) {
+			rest := value[len(scheme):]
+			host, _, _ := strings.Cut(rest, "/")
+			if strings.HasSuffix(strings.ToLower(host), ".onion") {
+				return "http://" + rest, nil
+			}
+			return "https://" + rest, nil
+		}
+	}
+
+	if fallback, err := url.Parse(value); err == nil && fallback.Scheme != "" {
+		if encoded := fallback.Query().Get("lightning"); encoded != "" {
+			return Decode(encoded)
+		}
+		return "", &Error{Reason: "invalid LNURL: URL has no lightning parameter"}
+	}
+
+	hrp, data, err := bolt11.DecodeBech32(value)
+	if err != nil {
+		return "", &Error{Reason: fmt.Sprintf("invalid LNURL: %v", err)}
+	}
+	if hrp != "lnurl" {
+		return "", &Error{Reason: "invalid LNURL: unexpected prefix " + hrp}
+	}
+
+	return string(data), nil
+}
+
+// Status is the reply LNURL endpoints give to report success or failure
+type Status struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ErrorStatus returns the reply reporting a failure to an LNURL client
+func ErrorStatus(reason string) Status {
+	return Status{Status: "ERROR", Reason: reason}
+}
+
+// get fetches an LNURL endpoint and decodes its JSON reply into result
+func (c *Client) get(ctx context.Context, endpoint string, query url.Values, result any) error {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return &Error{Reason: fmt.Sprintf("invalid LNURL endpoint: %v", err)}
+	}
+	if target.Scheme != "https" && !(target.Scheme == "http" && (c.AllowHTTP || strings.HasSuffix(target.Hostname(), ".onion"))) {
+		return &Error{Reason: fmt.Sprintf("LNURL endpoint %s must use https", target.Host)}
+	}
+
+	// Callbacks may already carry query parameters that must be kept
+	if len(query) > 0 {
+		values := target.Query()
+		for key, value := range query {
+			values[key] = value
+		}
+		target.RawQuery = values.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return &UnavailableError{Err: fmt.Errorf("failed to reach LNURL service: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return &UnavailableError{Err: fmt.Errorf("failed to read LNURL reply: %w", err)}
+	}
+
+	// Services report errors in the body, often with a non-200 status
+	var reply Status
+	if json.Unmarshal(body, &reply) == nil && strings.EqualFold(reply.Status, "ERROR") {
+		return &Error{Reason: fmt.Sprintf("LNURL service error: %s", reply.Reason)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Reason: fmt.Sprintf("LNURL service returned status %d", resp.StatusCode)}
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return &Error{Reason: fmt.Sprintf("invalid LNURL reply: %v", err)}
+	}
+
+	return nil
+}
+
+// IsError reports whether err was reported by, or is the fault of, an LNURL service
+func IsError(err error) bool {
+	var lnurlErr *Error
+	return errors.As(err, &lnurlErr)
+}
@@ -0,0 +1,107 @@
+package lnurl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"nwc_app/bolt11"
+)
+
+// PayParams is the first reply of an LNURL-pay service (LUD-06)
+type PayParams struct {
+	Tag            string `json:"tag"`
+	Callback       string `json:"callback"`
+	MinSendable    int64  `json:"minSendable"`
+	MaxSendable    int64  `json:"maxSendable"`
+	Metadata       string `json:"metadata"`
+	CommentAllowed int    `json:"commentAllowed,omitempty"`
+}
+
+// Description returns the text/plain entry of the metadata
+func (p *PayParams) Description() string {
+	var entries [][]any
+	if json.Unmarshal([]byte(p.Metadata), &entries) != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if len(entry) == 2 && entry[0] == "text/plain" {
+			text, _ := entry[1].(string)
+			return text
+		}
+	}
+	return ""
+}
+
+// InvoiceReply is the callback's reply carrying the invoice to pay
+type InvoiceReply struct {
+	PR     string `json:"pr"`
+	Routes []any  `json:"routes"`
+}
+
+// Metadata returns the metadata of a Lightning Address, which invoices for it must commit to
+func Metadata(description, address string) string {
+	metadata, _ := json.Marshal([][]string{
+		{"text/plain", description},
+		{"text/identifier", address},
+	})
+	return string(metadata)
+}
+
+// FetchPayParams fetches the payment parameters from an LNURL-pay endpoint
+func (c *Client) FetchPayParams(ctx context.Context, endpoint string) (*PayParams, error) {
+	var params PayParams
+	if err := c.get(ctx, endpoint, nil, &params); err != nil {
+		return nil, err
+	}
+
+	if params.Tag != "payRequest" {
+		return nil, &Error{Reason: fmt.Sprintf("LNURL is not a pay request but %q", params.Tag)}
+	}
+	if params.Callback == "" || params.MinSendable <= 0 || params.MaxSendable < params.MinSendable {
+		return nil, &Error{Reason: "LNURL service returned invalid pay parameters"}
+	}
+
+	return &params, nil
+}
+
+// RequestInvoice asks the service for an invoice of amountMsats and verifies that
+// the invoice is for that amount and commits to the service's metadata
+func (c *Client) RequestInvoice(ctx context.Context, params *PayParams, amountMsats int64, comment string) (*bolt11.Invoice, error) {
+	if amountMsats < params.MinSendable || amountMsats > params.MaxSendable {
+		return nil, &Error{Reason: fmt.Sprintf("amount of %d msats is outside the %d to %d msats the recipient accepts",
+			amountMsats, params.MinSendable, params.MaxSendable)}
+	}
+
+	query := url.Values{"amount": {strconv.FormatInt(amountMsats, 10)}}
+	if comment != "" && params.CommentAllowed > 0 {
+		if len(comment) > params.CommentAllowed {
+			comment = comment[:params.CommentAllowed]
+		}
+		query.Set("comment", comment)
+	}
+
+	var reply InvoiceReply
+	if err := c.get(ctx, params.Callback, query, &reply); err != nil {
+		return nil, err
+	}
+
+	invoice, err := bolt11.Decode(reply.PR)
+	if err != nil {
+		return nil, &Error{Reason: fmt.Sprintf("LNURL service returned an invalid invoice: %v", err)}
+	}
+	if invoice.AmountMsats != amountMsats {
+		return nil, &Error{Reason: fmt.Sprintf("LNURL service returned an invoice for %d msats instead of %d msats", invoice.AmountMsats, amountMsats)}
+	}
+
+	metadataHash := sha256.Sum256([]byte(params.Metadata))
+	if invoice.DescriptionHash != hex.EncodeToString(metadataHash[:]) {
+		return nil, &Error{Reason: "LNURL service returned an invoice that does not commit to its metadata"}
+	}
+
+	return invoice, nil
+}
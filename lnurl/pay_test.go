@@ -0,0 +1,247 @@
+package lnurl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Invoices of BOLT #11: cake is for 2,000,000,000 msats and commits to cakeMetadata,
+// coffee is for 250,000,000 msats and has a plain description
+const (
+	cake         = "lnbc20m1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqs9qrsgq7ea976txfraylvgzuxs8kgcw23ezlrszfnh8r6qtfpr6cxga50aj6txm9rxrydzd06dfeawfk6swupvz4erwnyutnjq7x39ymw6j38gp7ynn44"
+	cakeMetadata = "One piece of chocolate cake, one icecream cone, one pickle, one slice of swiss cheese, one slice of salami, one lollypop, one piece of cherry pie, one sausage, one cupcake, and one slice of watermelon"
+	cakeAmount   = 2_000_000_000
+	coffee       = "lnbc2500u1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpu9qrsgquk0rl77nj30yxdy8j9vdx85fkpmdla2087ne0xh8nhedh8w27kyke0lp53ut353s06fv3qfegext0eh0ymjpf39tuven09sam30g4vgpfna3rh"
+)
+
+// payService is an LNURL-pay service answering with params and, from its callback, with reply
+type payService struct {
+	*httptest.Server
+	params map[string]any
+	reply  any
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newPayService(t *testing.T) *payService {
+	t.Helper()
+
+	service := &payService{reply: InvoiceReply{PR: cake}}
+	service.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service.mu.Lock()
+		service.requests = append(service.requests, r)
+		service.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/.well-known/lnurlp/alice":
+			json.NewEncoder(w).Encode(service.params)
+		case "/callback":
+			json.NewEncoder(w).Encode(service.reply)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(service.Close)
+
+	service.params = map[string]any{
+		"tag":         "payRequest",
+		"callback":    service.URL + "/callback?session=1",
+		"minSendable": 1000,
+		"maxSendable": 3_000_000_000,
+		"metadata":    cakeMetadata,
+	}
+	return service
+}
+
+// requested returns the requests received so far
+func (s *payService) requested() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// client returns a client trusting the service's certificate
+func (s *payService) client() *Client {
+	return &Client{HTTP: s.Client()}
+}
+
+func (s *payService) fetch(t *testing.T) *PayParams {
+	t.Helper()
+
+	params, err := s.client().FetchPayParams(context.Background(), s.URL+"/.well-known/lnurlp/alice")
+	if err != nil {
+		t.Fatalf("FetchPayParams() error = %v", err)
+	}
+	return params
+}
+
+func TestFetchPayParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(params map[string]any)
+		want   string
+	}{
+		{"valid", func(map[string]any) {}, ""},
+		{"withdraw request", func(params map[string]any) { params["tag"] = "withdrawRequest" }, "not a pay request"},
+		{"no callback", func(params map[string]any) { delete(params, "callback") }, "invalid pay parameters"},
+		{"no minimum", func(params map[string]any) { params["minSendable"] = 0 }, "invalid pay parameters"},
+		{"maximum below minimum", func(params map[string]any) { params["maxSendable"] = 999 }, "invalid pay parameters"},
+		{"service error", func(params map[string]any) {
+			clear(params)
+			params["status"] = "ERROR"
+			params["reason"] = "unknown user"
+		}, "LNURL service error: unknown user"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newPayService(t)
+			test.change(service.params)
+
+			params, err := service.client().FetchPayParams(context.Background(), service.URL+"/.well-known/lnurlp/alice")
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("FetchPayParams() error = %v", err)
+				}
+				if params.MinSendable != 1000 || params.MaxSendable != 3_000_000_000 || params.Metadata != cakeMetadata {
+					t.Fatalf("FetchPayParams() = %+v", *params)
+				}
+				return
+			}
+			if !IsError(err) || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("FetchPayParams() error = %v, want an LNURL error mentioning %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchPayParamsRequiresHTTPS(t *testing.T) {
+	service := newPayService(t)
+	endpoint := "http://" + strings.TrimPrefix(service.URL, "https://") + "/.well-known/lnurlp/alice"
+
+	if _, err := service.client().FetchPayParams(context.Background(), endpoint); !IsError(err) || !strings.Contains(err.Error(), "must use https") {
+		t.Fatalf("FetchPayParams() error = %v, want https to be required", err)
+	}
+	if len(service.requested()) != 0 {
+		t.Fatal("plain HTTP endpoint was requested")
+	}
+}
+
+func TestRequestInvoice(t *testing.T) {
+	service := newPayService(t)
+	params := service.fetch(t)
+
+	invoice, err := service.client().RequestInvoice(context.Background(), params, cakeAmount, "")
+	if err != nil {
+		t.Fatalf("RequestInvoice() error = %v", err)
+	}
+	if invoice.AmountMsats != cakeAmount {
+		t.Fatalf("RequestInvoice() amount = %d, want %d", invoice.AmountMsats, cakeAmount)
+	}
+
+	requests := service.requested()
+	callback := requests[len(requests)-1].URL.Query()
+	if callback.Get("amount") != "2000000000" || callback.Get("session") != "1" {
+		t.Fatalf("callback query = %v, want the amount and the callback's own parameters", callback)
+	}
+	if callback.Has("comment") {
+		t.Fatal("comment sent to a service that does not allow comments")
+	}
+}
+
+func TestRequestInvoiceSendable(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+	}{
+		{"below minimum", 999},
+		{"above maximum", 3_000_000_001},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newPayService(t)
+			params := service.fetch(t)
+			requested := len(service.requested())
+
+			_, err := service.client().RequestInvoice(context.Background(), params, test.amount, "")
+			if !IsError(err) || !strings.Contains(err.Error(), "outside the 1000 to 3000000000 msats") {
+				t.Fatalf("RequestInvoice() error = %v, want the amount refused", err)
+			}
+			if len(service.requested()) != requested {
+				t.Fatal("callback requested for an amount outside the sendable range")
+			}
+		})
+	}
+}
+
+func TestRequestInvoiceChecksInvoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		reply    any
+		want     string
+	}{
+		{"wrong amount", cakeMetadata, InvoiceReply{PR: coffee}, "instead of 2000000000 msats"},
+		{"other metadata", Metadata("cake", "alice@example.com"), InvoiceReply{PR: cake}, "does not commit to its metadata"},
+		{"invalid invoice", cakeMetadata, InvoiceReply{PR: "lnbc1invalid"}, "invalid invoice"},
+		{"service error", cakeMetadata, ErrorStatus("amount too small"), "LNURL service error: amount too small"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newPayService(t)
+			service.params["metadata"] = test.metadata
+			service.reply = test.reply
+
+			_, err := service.client().RequestInvoice(context.Background(), service.fetch(t), cakeAmount, "")
+			if !IsError(err) || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("RequestInvoice() error = %v, want an LNURL error mentioning %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRequestInvoiceTruncatesComment(t *testing.T) {
+	service := newPayService(t)
+	service.params["commentAllowed"] = 5
+	params := service.fetch(t)
+
+	if _, err := service.client().RequestInvoice(context.Background(), params, cakeAmount, "thanks for the cake"); err != nil {
+		t.Fatal(err)
+	}
+	requests := service.requested()
+	if comment := requests[len(requests)-1].URL.Query().Get("comment"); comment != "thank" {
+		t.Fatalf("comment = %q, want it cut to the 5 characters allowed", comment)
+	}
+}
+
+func TestAddressURL(t *testing.T) {
+	tests := []struct {
+		address   string
+		allowHTTP bool
+		want      string
+	}{
+		{"Alice@Example.com", false, "https://example.com/.well-known/lnurlp/alice"},
+		{"alice@example.com", true, "http://example.com/.well-known/lnurlp/alice"},
+		{"alice@hidden.onion", false, "http://hidden.onion/.well-known/lnurlp/alice"},
+	}
+
+	for _, test := range tests {
+		got, err := (&Client{AllowHTTP: test.allowHTTP}).AddressURL(test.address)
+		if err != nil || got != test.want {
+			t.Errorf("AddressURL(%q) = %q, %v, want %q", test.address, got, err, test.want)
+		}
+	}
+
+	if _, err := (&Client{}).AddressURL("example.com"); !IsError(err) {
+		t.Errorf("AddressURL() error = %v, want an invalid address", err)
+	}
+}
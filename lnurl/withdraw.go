@@ -0,0 +1,50 @@
+package lnurl
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WithdrawParams is the first reply of an LNURL-withdraw service (LUD-03)
+type WithdrawParams struct {
+	Tag                string `json:"tag"`
+	Callback           string `json:"callback"`
+	K1                 string `json:"k1"`
+	DefaultDescription string `json:"defaultDescription"`
+	MinWithdrawable    int64  `json:"minWithdrawable"`
+	MaxWithdrawable    int64  `json:"maxWithdrawable"`
+}
+
+// FetchWithdrawParams fetches the withdrawal parameters from an LNURL-withdraw endpoint
+func (c *Client) FetchWithdrawParams(ctx context.Context, endpoint string) (*WithdrawParams, error) {
+	var params WithdrawParams
+	if err := c.get(ctx, endpoint, nil, &params); err != nil {
+		return nil, err
+	}
+
+	if params.Tag != "withdrawRequest" {
+		return nil, &Error{Reason: fmt.Sprintf("LNURL is not a withdraw request but %q", params.Tag)}
+	}
+	if params.Callback == "" || params.K1 == "" || params.MaxWithdrawable <= 0 || params.MaxWithdrawable < params.MinWithdrawable {
+		return nil, &Error{Reason: "LNURL service returned invalid withdraw parameters"}
+	}
+
+	return &params, nil
+}
+
+// SubmitInvoice asks the service to pay the invoice. The service pays it
+// asynchronously, so success only means the request was accepted.
+func (c *Client) SubmitInvoice(ctx context.Context, params *WithdrawParams, invoice string) error {
+	var reply Status
+	query := url.Values{"k1": {params.K1}, "pr": {invoice}}
+	if err := c.get(ctx, params.Callback, query, &reply); err != nil {
+		return err
+	}
+	if !strings.EqualFold(reply.Status, "OK") {
+		return &Error{Reason: fmt.Sprintf("LNURL service did not accept the invoice: %q", reply.Status)}
+	}
+
+	return nil
+}
@@ -1,77 +1,1220 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	"nwc_app/batches"
+	"nwc_app/bolt11"
+	"nwc_app/budget"
+	"nwc_app/events"
+	"nwc_app/invoices"
+	"nwc_app/ledger"
+	"nwc_app/lnurl"
+	"nwc_app/nip47"
+	"nwc_app/payrequests"
+	"nwc_app/rebalance"
+	"nwc_app/schedules"
+	"nwc_app/wallet"
+
 	"github.com/untreu2/go-nwc"
 )
 
+// PaymentResult describes a payment made by makePayment
+type PaymentResult struct {
+	LedgerID    string
+	PaymentHash string
+	Preimage    string
+	FeesPaid    int64
+	MaxFeeMsats *int64
+}
+
 // makePayment handles Lightning payments between any two wallets
 // sender and recipient are keys in the walletURIs map
-// amount is in millisatoshis
-func makePayment(walletURIs map[string]string, sender string, recipient string, amount int) error {
+// amount is in millisatoshis, euroAmount is its value used for fiat budgets
+// feeLimit can only tighten the fee ceiling configured for the sender
+func makePayment(walletURIs map[string]string, sender string, recipient string, amount int, euroAmount float64, feeLimit wallet.FeePolicy) (*PaymentResult, error) {
+	return makeWalletPayment(walletURIs, ledger.KindPayment, sender, recipient, amount, euroAmount, feeLimit)
+}
+
+// makeWalletPayment is makePayment recording the payment in the ledger as kind
+func makeWalletPayment(walletURIs map[string]string, kind string, sender string, recipient string, amount int, euroAmount float64, feeLimit wallet.FeePolicy) (*PaymentResult, error) {
 	// Get URIs for both wallets
 	senderURI, ok := walletURIs[sender]
 	if !ok {
-		return fmt.Errorf("sender wallet '%s' not found", sender)
+		return nil, &WalletNotFoundError{Role: "sender", Wallet: sender}
 	}
 	
 	recipientURI, ok := walletURIs[recipient]
 	if !ok {
-		return fmt.Errorf("recipient wallet '%s' not found", recipient)
+		return nil, &WalletNotFoundError{Role: "recipient", Wallet: recipient}
 	}
 	
 	// Initialize wallet clients
-	senderClient, err := nwc.NewClient(senderURI)
+	senderClient, err := nip47.NewClient(senderURI)
 	if err != nil {
-		return fmt.Errorf("failed to initialize sender wallet: %w", err)
+		return nil, &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
 	}
 	
 	recipientClient, err := nwc.NewClient(recipientURI)
 	if err != nil {
-		return fmt.Errorf("failed to initialize recipient wallet: %w", err)
+		return nil, &WalletError{Wallet: recipient, Err: fmt.Errorf("failed to initialize recipient wallet: %w", err)}
+	}
+	
+	payment, err := sendPayment(senderClient, ledger.Entry{
+		Kind:        kind,
+		Sender:      sender,
+		Recipient:   recipient,
+		AmountMsats: int64(amount),
+		EuroAmount:  euroAmount,
+	}, feeLimit, func() (*nip47.Payment, error) {
+		// Create invoice from recipient; a fresh one replaces it if it expires while retrying
+		newInvoice := func() (*bolt11.Invoice, error) {
+			invoice, err := recipientClient.MakeInvoice(amount, fmt.Sprintf("Payment from %s to %s", sender, recipient))
+			if err != nil {
+				return nil, &WalletError{Wallet: recipient, Err: fmt.Errorf("failed to create invoice: %w", err)}
+			}
+			
+			// Never pay more than requested, whatever the recipient wallet returned
+			decoded, err := bolt11.Decode(invoice)
+			if err != nil {
+				return nil, &WalletError{Wallet: recipient, Err: fmt.Errorf("recipient wallet returned an invalid invoice: %w", err)}
+			}
+			if decoded.AmountMsats != int64(amount) {
+				return nil, &WalletError{Wallet: recipient, Err: fmt.Errorf("recipient wallet returned an invoice for %d msats instead of %d msats", decoded.AmountMsats, amount)}
+			}
+			
+			log.Printf("Created invoice %s for %d msat", decoded.PaymentHash, amount)
+			return decoded, nil
+		}
+		
+		invoice, err := newInvoice()
+		if err != nil {
+			return nil, err
+		}
+		return payBolt11(senderClient, invoice, newInvoice)
+	})
+	if err != nil {
+		return payment, err
+	}
+	
+	// Check updated balances
+	if balance, err := senderClient.GetBalance(); err == nil {
+		log.Printf("Updated %s balance: %d msat", sender, balance.Balance)
+	}
+	if balance, err := recipientClient.GetBalance(); err == nil {
+		log.Printf("Updated %s balance: %d msat", recipient, balance.Balance)
+	}
+	
+	return payment, nil
+}
+
+// payInvoice pays an external BOLT11 invoice from a configured wallet
+// The invoice must be for the configured network, unexpired and carry an amount;
+// expectedAmount and description are checked against it when given
+func payInvoice(walletURIs map[string]string, sender string, invoice string, expectedAmount int64, description string, feeLimit wallet.FeePolicy) (*PaymentResult, *bolt11.Invoice, error) {
+	senderURI, ok := walletURIs[sender]
+	if !ok {
+		return nil, nil, &WalletNotFoundError{Role: "sender", Wallet: sender}
+	}
+	
+	decoded, err := bolt11.Decode(invoice)
+	if err != nil {
+		return nil, nil, &InvoiceError{Reason: fmt.Sprintf("invalid invoice: %v", err)}
+	}
+	if err := validateInvoice(decoded, expectedAmount, description); err != nil {
+		return nil, decoded, err
+	}
+	
+	senderClient, err := nip47.NewClient(senderURI)
+	if err != nil {
+		return nil, decoded, &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
+	}
+	
+	// Fiat budgets need the Euro value; other payments go ahead if the rate is unavailable
+	euroAmount, err := msatsToEuro(decoded.AmountMsats)
+	if err != nil {
+		if walletConfigs[sender].Spending.UsesEur() {
+			return nil, decoded, fmt.Errorf("failed to convert msats to EUR for spending policy: %w", err)
+		}
+		log.Printf("Could not value invoice in EUR: %v", err)
+		euroAmount = 0
+	}
+	
+	payment, err := sendPayment(senderClient, ledger.Entry{
+		Kind:        ledger.KindInvoice,
+		Sender:      sender,
+		Recipient:   decoded.Payee,
+		AmountMsats: decoded.AmountMsats,
+		EuroAmount:  euroAmount,
+		PaymentHash: decoded.PaymentHash,
+	}, feeLimit, func() (*nip47.Payment, error) {
+		return payBolt11(senderClient, decoded, nil)
+	})
+	
+	return payment, decoded, err
+}
+
+// payLightningAddress pays a Lightning Address (name@domain) from a configured wallet
+// It fetches an invoice for amount msats through LNURL-pay and verifies it before paying
+func payLightningAddress(walletURIs map[string]string, sender string, address string, amount int, euroAmount float64, feeLimit wallet.FeePolicy) (*PaymentResult, error) {
+	senderURI, ok := walletURIs[sender]
+	if !ok {
+		return nil, &WalletNotFoundError{Role: "sender", Wallet: sender}
+	}
+	
+	invoice, err := fetchAddressInvoice(address, int64(amount))
+	if err != nil {
+		return nil, err
+	}
+	
+	senderClient, err := nip47.NewClient(senderURI)
+	if err != nil {
+		return nil, &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
+	}
+	
+	return sendPayment(senderClient, ledger.Entry{
+		Kind:        ledger.KindLightningAddress,
+		Sender:      sender,
+		Recipient:   address,
+		AmountMsats: int64(amount),
+		EuroAmount:  euroAmount,
+		PaymentHash: invoice.PaymentHash,
+	}, feeLimit, func() (*nip47.Payment, error) {
+		return payBolt11(senderClient, invoice, nil)
+	})
+}
+
+// fetchAddressInvoice fetches an invoice for amount msats from a Lightning Address
+// through LNURL-pay and verifies that it can be paid
+func fetchAddressInvoice(address string, amount int64) (*bolt11.Invoice, error) {
+	endpoint, err := lnurlClient.AddressURL(address)
+	if err != nil {
+		return nil, err
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	
+	params, err := lnurlClient.FetchPayParams(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	
+	invoice, err := lnurlClient.RequestInvoice(ctx, params, amount, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInvoice(invoice, amount, ""); err != nil {
+		return nil, err
+	}
+	
+	log.Printf("Fetched invoice %s for %d msat from %s", invoice.PaymentHash, amount, address)
+	
+	return invoice, nil
+}
+
+// payKeysend pushes amount msats from a configured wallet to a node pubkey without an invoice
+// tlvRecords are passed on to the recipient with the payment
+func payKeysend(walletURIs map[string]string, sender string, pubkey string, amount int, euroAmount float64, tlvRecords []nip47.TLVRecord, feeLimit wallet.FeePolicy) (*PaymentResult, error) {
+	senderURI, ok := walletURIs[sender]
+	if !ok {
+		return nil, &WalletNotFoundError{Role: "sender", Wallet: sender}
+	}
+	
+	senderClient, err := nip47.NewClient(senderURI)
+	if err != nil {
+		return nil, &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
+	}
+	
+	return sendPayment(senderClient, ledger.Entry{
+		Kind:        ledger.KindKeysend,
+		Sender:      sender,
+		Recipient:   pubkey,
+		AmountMsats: int64(amount),
+		EuroAmount:  euroAmount,
+	}, feeLimit, func() (*nip47.Payment, error) {
+		// Keysend payments cannot be looked up before paying again, so only
+		// attempts the wallet refused outright are retried
+		payment, err := retryPayment("keysend payment to "+pubkey, func() (*nip47.Payment, error) {
+			return senderClient.PayKeysendWithParams(context.Background(), nip47.KeysendParams{
+				Pubkey:      pubkey,
+				AmountMsats: int64(amount),
+				TLVRecords:  tlvRecords,
+			})
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("keysend payment failed: %w", err)
+		}
+		
+		log.Printf("Sent keysend payment of %d msat to %s", amount, pubkey)
+		return payment, nil
+	})
+}
+
+// BatchOptions controls how the payments of a batch are made
+type BatchOptions struct {
+	FeeLimit    wallet.FeePolicy
+	Concurrency int
+	// MultiPay pays all invoices with one multi_pay_invoice request instead of one request each
+	MultiPay bool
+	// AllOrNothing fetches and reserves every invoice first and pays none unless all could be
+	AllOrNothing bool
+}
+
+// runBatch pays the pending items of a stored batch and records each outcome as soon as it is known
+// Items without an amount in msats are converted at a single exchange rate
+func runBatch(walletURIs map[string]string, batch batches.Batch, options BatchOptions) batches.Batch {
+	var btcPriceInEur float64
+	var err error
+	for _, item := range batch.Items {
+		if item.Status == batches.ItemPending && item.AmountMsats == 0 {
+			btcPriceInEur, err = fetchBTCPriceEUR()
+			break
+		}
+	}
+	
+	var items []batches.Item
+	for _, item := range batch.Items {
+		switch {
+		case item.Status != batches.ItemPending:
+			continue
+		case item.AmountMsats > 0:
+			items = append(items, item)
+		case err != nil:
+			recordBatchItem(batch.ID, item, nil, fmt.Errorf("failed to convert EUR to msats: %w", err))
+		case euroToMsatsAt(item.EuroAmount, btcPriceInEur) <= 0:
+			recordBatchItem(batch.ID, item, nil, validationFailed("converted amount must be greater than 0"))
+		default:
+			item.AmountMsats = int64(euroToMsatsAt(item.EuroAmount, btcPriceInEur))
+			items = append(items, item)
+		}
+	}
+	
+	if options.MultiPay || options.AllOrNothing {
+		payments := prepareBatchPayments(walletURIs, batch.ID, batch.Sender, items, options)
+		if options.MultiPay {
+			multiPayBatch(walletURIs, batch.ID, batch.Sender, payments)
+		} else {
+			payPreparedBatch(walletURIs, batch.ID, batch.Sender, payments, options)
+		}
+	} else {
+		payBatchItems(walletURIs, batch.ID, batch.Sender, items, options)
+	}
+	
+	finished, err := batchStore.Finish(batch.ID)
+	if err != nil {
+		log.Printf("Failed to store batch %s: %v", batch.ID, err)
+	}
+	
+	log.Printf("Batch %s %s: %d of %d payments succeeded", batch.ID, finished.Status, finished.Summary.Succeeded, finished.Summary.Total)
+	
+	return finished
+}
+
+// payBatchItems makes each payment of a batch separately, at most options.Concurrency at a time
+func payBatchItems(walletURIs map[string]string, batchID string, sender string, items []batches.Item, options BatchOptions) {
+	slots := make(chan struct{}, max(options.Concurrency, 1))
+	var wg sync.WaitGroup
+	
+	for _, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(item batches.Item) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			
+			var result *PaymentResult
+			var err error
+			if lnurl.IsAddress(item.Recipient) {
+				result, err = payLightningAddress(walletURIs, sender, item.Recipient, int(item.AmountMsats), item.EuroAmount, options.FeeLimit)
+			} else {
+				result, err = makePayment(walletURIs, sender, item.Recipient, int(item.AmountMsats), item.EuroAmount, options.FeeLimit)
+			}
+			recordBatchItem(batchID, item, result, err)
+		}(item)
+	}
+	
+	wg.Wait()
+}
+
+// batchPayment is a batch item whose invoice has been fetched and reserved in the ledger
+type batchPayment struct {
+	item     batches.Item
+	invoice  *bolt11.Invoice
+	reserved *reservedPayment
+}
+
+// prepareBatchPayments fetches an invoice for every item and reserves it in the ledger
+// Items that cannot be prepared are recorded as failed; with options.AllOrNothing a
+// single failure fails every item and releases the reservations already made
+func prepareBatchPayments(walletURIs map[string]string, batchID string, sender string, items []batches.Item, options BatchOptions) []batchPayment {
+	var payments []batchPayment
+	var skipped []batches.Item
+	var failure error
+	for i, item := range items {
+		invoice, kind, err := batchInvoice(walletURIs, sender, item)
+		if err == nil {
+			var reserved *reservedPayment
+			reserved, err = reservePayment(ledger.Entry{
+				Kind:        kind,
+				Sender:      sender,
+				Recipient:   item.Recipient,
+				AmountMsats: item.AmountMsats,
+				EuroAmount:  item.EuroAmount,
+				PaymentHash: invoice.PaymentHash,
+				BatchID:     batchID,
+			}, options.FeeLimit)
+			if err == nil {
+				payments = append(payments, batchPayment{item: item, invoice: invoice, reserved: reserved})
+				continue
+			}
+		}
+		
+		recordBatchItem(batchID, item, nil, err)
+		if options.AllOrNothing {
+			failure = fmt.Errorf("not paid because the payment to %s failed: %w", item.Recipient, err)
+			skipped = items[i+1:]
+			break
+		}
+	}
+	
+	// The payments must fit the balance together, including room for the maximum fees
+	if failure == nil && len(payments) > 0 {
+		failure = checkBatchBalance(sender, walletURIs[sender], payments)
+	}
+	
+	if failure != nil {
+		for _, payment := range payments {
+			result, err := finishPayment(payment.reserved, nil, failure)
+			recordBatchItem(batchID, payment.item, result, err)
+		}
+		for _, item := range skipped {
+			recordBatchItem(batchID, item, nil, failure)
+		}
+		return nil
+	}
+	
+	return payments
+}
+
+// checkBatchBalance checks that the sender can afford all payments and their maximum fees
+func checkBatchBalance(sender string, senderURI string, payments []batchPayment) error {
+	var total int64
+	for _, payment := range payments {
+		total += payment.item.AmountMsats + payment.reserved.maxFee
+	}
+	
+	senderClient, err := nwc.NewClient(senderURI)
+	if err != nil {
+		return &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
+	}
+	
+	balance, err := senderClient.GetBalance()
+	if err != nil {
+		return &WalletError{Wallet: sender, Err: fmt.Errorf("failed to get sender balance: %w", err)}
+	}
+	if balance.Balance < total {
+		return &InsufficientFundsError{Wallet: sender, NeededMsats: total, AvailableMsats: balance.Balance}
+	}
+	
+	return nil
+}
+
+// payPreparedBatch pays prepared invoices separately, at most options.Concurrency at a time
+func payPreparedBatch(walletURIs map[string]string, batchID string, sender string, payments []batchPayment, options BatchOptions) {
+	senderClient, err := nip47.NewClient(walletURIs[sender])
+	
+	slots := make(chan struct{}, max(options.Concurrency, 1))
+	var wg sync.WaitGroup
+	
+	for _, payment := range payments {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(payment batchPayment) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			
+			var paid *nip47.Payment
+			payErr := err
+			if err == nil {
+				paid, payErr = payBolt11(senderClient, payment.invoice, nil)
+			}
+			
+			result, payErr := finishPayment(payment.reserved, paid, payErr)
+			recordBatchItem(batchID, payment.item, result, payErr)
+		}(payment)
+	}
+	
+	wg.Wait()
+}
+
+// multiPayBatch pays prepared invoices with a single multi_pay_invoice request.
+// Invoices the wallet gave no clear answer for are looked up before they count as failed.
+func multiPayBatch(walletURIs map[string]string, batchID string, sender string, payments []batchPayment) {
+	if len(payments) == 0 {
+		return
+	}
+	
+	requests := make([]nip47.MultiPayItem, len(payments))
+	for i, payment := range payments {
+		requests[i] = nip47.MultiPayItem{ID: strconv.Itoa(payment.item.Index), Invoice: payment.invoice.Raw}
+	}
+	
+	senderClient, err := nip47.NewClient(walletURIs[sender])
+	if err != nil {
+		err = &WalletError{Wallet: sender, Err: fmt.Errorf("failed to initialize sender wallet: %w", err)}
+		for _, payment := range payments {
+			result, payErr := finishPayment(payment.reserved, nil, err)
+			recordBatchItem(batchID, payment.item, result, payErr)
+		}
+		return
+	}
+	
+	log.Printf("Paying %d invoices from %s with multi_pay_invoice", len(requests), sender)
+	results, err := senderClient.MultiPayInvoice(context.Background(), requests)
+	if err != nil {
+		log.Printf("multi_pay_invoice from %s broke off, checking the invoices without a reply: %v", sender, err)
+	}
+	
+	for i, payment := range payments {
+		paid, payErr := results[requests[i].ID].Payment, results[requests[i].ID].Err
+		
+		// Only an error reply from the wallet says for sure the invoice was not paid
+		var walletErr *nip47.Error
+		if payErr != nil && !errors.As(payErr, &walletErr) {
+			if found, lookupErr := lookupPayment(senderClient, payment.invoice.PaymentHash); found != nil {
+				paid, payErr = found, nil
+			} else if lookupErr != nil {
+				payErr = lookupErr
+			}
+		}
+		if payErr != nil {
+			payErr = fmt.Errorf("payment failed: %w", payErr)
+		}
+		
+		result, payErr := finishPayment(payment.reserved, paid, payErr)
+		recordBatchItem(batchID, payment.item, result, payErr)
+	}
+}
+
+// batchInvoice gets the invoice paying a batch item, from the recipient wallet or its
+// Lightning Address, along with the kind of ledger entry the payment is recorded as
+func batchInvoice(walletURIs map[string]string, sender string, item batches.Item) (*bolt11.Invoice, string, error) {
+	if lnurl.IsAddress(item.Recipient) {
+		invoice, err := fetchAddressInvoice(item.Recipient, item.AmountMsats)
+		return invoice, ledger.KindLightningAddress, err
+	}
+	
+	invoice, err := createInvoice(walletURIs, item.Recipient, nip47.InvoiceParams{
+		AmountMsats: item.AmountMsats,
+		Description: fmt.Sprintf("Payment from %s to %s", sender, item.Recipient),
+	})
+	return invoice, ledger.KindPayment, err
+}
+
+// recordBatchItem stores the outcome of a batch payment
+func recordBatchItem(batchID string, item batches.Item, result *PaymentResult, err error) {
+	if err != nil {
+		item.Status = batches.ItemFailed
+		item.Error = err.Error()
+		item.ErrorCode = toAPIError(err).Code
+		log.Printf("Batch %s item %d to %s failed: %v", batchID, item.Index, item.Recipient, err)
+	} else {
+		item.Status = batches.ItemSucceeded
+	}
+	// A payment over its fee limit failed but was still made
+	if result != nil {
+		item.LedgerID = result.LedgerID
+		item.PaymentHash = result.PaymentHash
+		item.Preimage = result.Preimage
+		item.FeesPaid = result.FeesPaid
+	}
+	
+	if _, err := batchStore.UpdateItem(batchID, item); err != nil {
+		log.Printf("Failed to store batch %s item %d: %v", batchID, item.Index, err)
+	}
+}
+
+// paySchedule makes the payment of a due schedule
+// EUR amounts are converted at the current rate; the EUR value of amounts in
+// sats is only needed when the sender's spending policy is in EUR
+func paySchedule(ctx context.Context, schedule schedules.Schedule) (*schedules.Payment, error) {
+	var amount int
+	euroAmount := schedule.Amount
+	var err error
+	switch schedule.Currency {
+	case schedules.CurrencyEUR:
+		amount, err = euroToMsats(schedule.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert EUR to msats: %w", err)
+		}
+	case schedules.CurrencySAT, schedules.CurrencyMSAT:
+		amount = int(schedule.Amount)
+		if schedule.Currency == schedules.CurrencySAT {
+			amount = int(schedule.Amount * 1000)
+		}
+		euroAmount, err = msatsToEuro(int64(amount))
+		if err != nil {
+			if walletConfigs[schedule.Sender].Spending.UsesEur() {
+				return nil, fmt.Errorf("failed to convert msats to EUR for spending policy: %w", err)
+			}
+			log.Printf("Could not value scheduled payment in EUR: %v", err)
+			euroAmount = 0
+		}
+	default:
+		return nil, fmt.Errorf("unsupported currency %q", schedule.Currency)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("converted amount must be greater than 0")
+	}
+	
+	feeLimit := wallet.FeePolicy{
+		MaxFeeMsats:   schedule.MaxFeeMsats,
+		MaxFeePercent: schedule.MaxFeePercent,
+	}
+	
+	var result *PaymentResult
+	if lnurl.IsAddress(schedule.Recipient) {
+		result, err = payLightningAddress(walletURIs, schedule.Sender, schedule.Recipient, amount, euroAmount, feeLimit)
+	} else {
+		result, err = makePayment(walletURIs, schedule.Sender, schedule.Recipient, amount, euroAmount, feeLimit)
+	}
+	if result == nil {
+		return nil, err
+	}
+	
+	return &schedules.Payment{
+		LedgerID:    result.LedgerID,
+		AmountMsats: int64(amount),
+		EuroAmount:  euroAmount,
+		FeesPaid:    result.FeesPaid,
+	}, err
+}
+
+// payRebalance makes a transfer of the rebalancer, tagged as a rebalance in the ledger
+// Its EUR value is only needed when the sender's spending policy is in EUR
+func payRebalance(ctx context.Context, transfer rebalance.Transfer) (*rebalance.Payment, error) {
+	euroAmount, err := msatsToEuro(transfer.AmountMsats)
+	if err != nil {
+		if walletConfigs[transfer.Sender].Spending.UsesEur() {
+			return nil, fmt.Errorf("failed to convert msats to EUR for spending policy: %w", err)
+		}
+		log.Printf("Could not value rebalance in EUR: %v", err)
+		euroAmount = 0
+	}
+	
+	result, err := makeWalletPayment(walletURIs, ledger.KindRebalance, transfer.Sender, transfer.Recipient, int(transfer.AmountMsats), euroAmount, wallet.FeePolicy{})
+	if result == nil {
+		return nil, err
+	}
+	
+	return &rebalance.Payment{LedgerID: result.LedgerID, FeesPaid: result.FeesPaid}, err
+}
+
+// walletBalance returns the balance of a configured wallet in msats
+func walletBalance(ctx context.Context, walletID string) (int64, error) {
+	walletURI, ok := walletURIs[walletID]
+	if !ok {
+		return 0, &WalletNotFoundError{Wallet: walletID}
+	}
+
+	client, err := nip47.NewClient(walletURI)
+	if err != nil {
+		return 0, &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to initialize wallet: %w", err)}
+	}
+
+	balance, err := client.GetBalance()
+	if err != nil {
+		return 0, &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to get balance: %w", err)}
+	}
+	return balance.Balance, nil
+}
+
+// InvoiceError is returned when an invoice cannot be paid as given
+type InvoiceError struct {
+	Reason string
+}
+
+func (e *InvoiceError) Error() string {
+	return e.Reason
+}
+
+// validateInvoice checks that a decoded invoice can be paid by this service
+func validateInvoice(invoice *bolt11.Invoice, expectedAmount int64, description string) error {
+	if network := lightningNetwork(); invoice.Network != network {
+		return &InvoiceError{Reason: fmt.Sprintf("invoice is for %s but this service pays on %s", invoice.Network, network)}
+	}
+	if invoice.Expired(time.Now()) {
+		return &InvoiceError{Reason: fmt.Sprintf("invoice expired at %s", invoice.ExpiresAt.Format(time.RFC3339))}
+	}
+	if invoice.AmountMsats <= 0 {
+		return &InvoiceError{Reason: "invoices without an amount are not supported"}
+	}
+	if expectedAmount > 0 && invoice.AmountMsats != expectedAmount {
+		return &InvoiceError{Reason: fmt.Sprintf("invoice is for %d msats, expected %d msats", invoice.AmountMsats, expectedAmount)}
+	}
+	
+	if description != "" {
+		if invoice.DescriptionHash != "" {
+			hash := sha256.Sum256([]byte(description))
+			if hex.EncodeToString(hash[:]) != invoice.DescriptionHash {
+				return &InvoiceError{Reason: "invoice description hash does not match the description"}
+			}
+		} else if invoice.Description != description {
+			return &InvoiceError{Reason: "invoice description does not match"}
+		}
+	}
+	
+	return nil
+}
+
+// createInvoice asks a configured wallet for an invoice it can be paid with
+// An expiry of zero leaves the wallet's default in place
+func createInvoice(walletURIs map[string]string, walletID string, params nip47.InvoiceParams) (*bolt11.Invoice, error) {
+	walletURI, ok := walletURIs[walletID]
+	if !ok {
+		return nil, &WalletNotFoundError{Wallet: walletID}
+	}
+	
+	client, err := nip47.NewClient(walletURI)
+	if err != nil {
+		return nil, &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to initialize wallet: %w", err)}
+	}
+	
+	transaction, err := client.MakeInvoiceWithParams(context.Background(), params)
+	if err != nil {
+		return nil, &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to create invoice: %w", err)}
+	}
+	
+	// Read payment hash and expiry from the invoice itself, as wallets
+	// do not always fill in every field of the reply
+	invoice, err := bolt11.Decode(transaction.Invoice)
+	if err != nil {
+		return nil, &WalletError{Wallet: walletID, Err: fmt.Errorf("wallet returned an invalid invoice: %w", err)}
+	}
+	if invoice.AmountMsats != params.AmountMsats {
+		return nil, &WalletError{Wallet: walletID, Err: fmt.Errorf("wallet returned an invoice for %d msats instead of %d msats", invoice.AmountMsats, params.AmountMsats)}
+	}
+	if params.DescriptionHash != "" && invoice.DescriptionHash != params.DescriptionHash {
+		return nil, &WalletError{Wallet: walletID, Err: errors.New("wallet returned an invoice without the requested description hash")}
+	}
+	
+	log.Printf("Created invoice %s for %d msat on %s", invoice.PaymentHash, params.AmountMsats, walletID)
+	
+	return invoice, nil
+}
+
+// trackInvoice stores an invoice created on a configured wallet so the
+// invoice watcher follows it until it is paid or expires
+// record carries the wallet and any details the invoice itself does not hold
+func trackInvoice(record invoices.Invoice, invoice *bolt11.Invoice) invoices.Invoice {
+	record.PaymentHash = invoice.PaymentHash
+	record.Invoice = invoice.Raw
+	record.AmountMsats = invoice.AmountMsats
+	record.CreatedAt = invoice.CreatedAt
+	record.ExpiresAt = invoice.ExpiresAt
+	if invoice.Description != "" {
+		record.Description = invoice.Description
+	}
+	
+	record, err := invoiceStore.Add(record)
+	if err != nil {
+		log.Printf("Failed to store invoice %s: %v", invoice.PaymentHash, err)
+	}
+	
+	return record
+}
+
+// paymentRequestMu keeps two visitors of a payment page from quoting it at the same time
+var paymentRequestMu sync.Mutex
+
+// refreshPaymentRequest brings an open payment request up to date: a settled invoice
+// marks it paid, a passed deadline expires it and an expired quote is replaced by an
+// invoice at the current rate, valid for quoteTTL or until the request expires
+func refreshPaymentRequest(ctx context.Context, id string, quoteTTL time.Duration) (payrequests.Request, error) {
+	paymentRequestMu.Lock()
+	defer paymentRequestMu.Unlock()
+	
+	request, ok := paymentRequests.Get(id)
+	if !ok || request.Status != payrequests.StatusOpen {
+		return request, nil
+	}
+	
+	// The watcher may not have noticed the payment of the current invoice yet
+	if invoice, ok := invoiceStore.Get(request.PaymentHash); ok {
+		invoice, err := invoiceWatcher.Check(ctx, invoice)
+		if err != nil {
+			log.Printf("Failed to check invoice %s of payment request %s: %v", invoice.PaymentHash, id, err)
+		}
+		if invoice.Status == invoices.StatusPaid {
+			settlePaymentRequest(invoice)
+			request, _ = paymentRequests.Get(id)
+			return request, nil
+		}
+	}
+	
+	now := time.Now()
+	if request.Expired(now) {
+		log.Printf("Payment request %s expired unpaid", id)
+		return paymentRequests.Expire(id)
+	}
+	if !request.NeedsQuote(now) {
+		return request, nil
+	}
+	
+	msats, err := euroToMsats(request.EuroAmount)
+	if err != nil {
+		return request, fmt.Errorf("failed to convert EUR to msats: %w", err)
+	}
+	if msats <= 0 {
+		return request, validationFailed("converted amount must be greater than 0")
+	}
+	
+	expiry := quoteTTL
+	if request.ExpiresAt != nil && request.ExpiresAt.Sub(now) < expiry {
+		expiry = request.ExpiresAt.Sub(now).Round(time.Second) + time.Second
+	}
+	
+	invoice, err := createInvoice(walletURIs, request.Recipient, nip47.InvoiceParams{
+		AmountMsats: int64(msats),
+		Description: request.Description,
+		Expiry:      expiry,
+	})
+	if err != nil {
+		return request, err
+	}
+	trackInvoice(invoices.Invoice{Wallet: request.Recipient, EuroAmount: request.EuroAmount}, invoice)
+	
+	log.Printf("Quoted payment request %s at %d msat until %s", id, invoice.AmountMsats, invoice.ExpiresAt.Format(time.RFC3339))
+	
+	return paymentRequests.Requote(id, payrequests.Quote{
+		Invoice:     invoice.Raw,
+		PaymentHash: invoice.PaymentHash,
+		AmountMsats: invoice.AmountMsats,
+		ExpiresAt:   invoice.ExpiresAt,
+	})
+}
+
+// settlePaymentRequests marks payment requests paid as their invoices settle
+func settlePaymentRequests(published <-chan events.Event) {
+	for event := range published {
+		if invoice, ok := event.Data.(invoices.Invoice); ok && event.Type == events.TypeInvoicePaid {
+			settlePaymentRequest(invoice)
+		}
+	}
+}
+
+// settlePaymentRequest marks the payment request that issued a paid invoice as paid,
+// announcing it the first time
+func settlePaymentRequest(invoice invoices.Invoice) {
+	paidAt := invoice.UpdatedAt
+	if invoice.SettledAt != nil {
+		paidAt = *invoice.SettledAt
+	}
+	
+	request, changed, err := paymentRequests.Settle(invoice.PaymentHash, invoice.AmountMsats, paidAt)
+	if err != nil {
+		log.Printf("Failed to mark payment request paid by invoice %s: %v", invoice.PaymentHash, err)
+		return
+	}
+	if changed {
+		log.Printf("Payment request %s paid with invoice %s", request.ID, invoice.PaymentHash)
+		eventBus.Publish(events.TypePaymentRequestPaid, request.Recipient, request)
+	}
+}
+
+// NotifiedPayment is a payment a wallet reported, the data of payment.received and payment.sent events.
+// LedgerID is the ledger entry of the payment, if it is in the ledger.
+type NotifiedPayment struct {
+	nip47.Transaction
+	LedgerID string `json:"ledger_id,omitempty"`
+}
+
+// listenNotifications listens for the payment notifications a configured wallet published since the given time
+func listenNotifications(ctx context.Context, walletID string, since time.Time, handle func(nip47.Notification)) error {
+	client, err := nip47.NewClient(walletURIs[walletID])
+	if err != nil {
+		return &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to initialize wallet: %w", err)}
+	}
+
+	return client.Listen(ctx, since, handle, func(err error) {
+		log.Printf("Ignoring notification of %s: %v", walletID, err)
+	})
+}
+
+// handleNotification records a payment a wallet reported and announces it.
+// A received payment is added to the ledger, settling the API invoice it paid
+// right away; a sent payment is linked to the ledger entry of the API payment, if any.
+func handleNotification(walletID string, notification nip47.Notification) {
+	transaction := notification.Transaction
+	if transaction.PaymentHash == "" {
+		log.Printf("Ignoring %s notification of %s without a payment hash", notification.Type, walletID)
+		return
+	}
+
+	switch notification.Type {
+	case nip47.NotificationPaymentReceived:
+		// Wallets repeat notifications, which need no exchange rate or ledger write
+		if _, ok := paymentLedger.Received(walletID, transaction.PaymentHash); ok {
+			return
+		}
+
+		entry := ledger.Entry{
+			Recipient:   walletID,
+			AmountMsats: transaction.Amount,
+			FeesMsats:   transaction.FeesPaid,
+			PaymentHash: transaction.PaymentHash,
+		}
+		if btcPriceInEur, err := cachedBTCPriceEUR(); err == nil {
+			entry.EuroAmount = msatsToEuroAt(transaction.Amount, btcPriceInEur)
+		}
+
+		received, added, err := paymentLedger.Receive(entry)
+		if err != nil {
+			log.Printf("Failed to record payment %s received by %s in ledger: %v", transaction.PaymentHash, walletID, err)
+			return
+		}
+		if !added {
+			return
+		}
+
+		log.Printf("%s received %d msat (payment %s)", walletID, transaction.Amount, transaction.PaymentHash)
+		if _, _, err := invoiceWatcher.Notify(walletID, &transaction); err != nil {
+			log.Printf("Failed to settle invoice %s on %s: %v", transaction.PaymentHash, walletID, err)
+		}
+		eventBus.Publish(events.TypePaymentReceived, walletID, NotifiedPayment{Transaction: transaction, LedgerID: received.ID})
+
+	case nip47.NotificationPaymentSent:
+		// Finding the ledger entry may wait for the payment to finish, so it does
+		// not hold up the notifications that follow
+		go func() {
+			payment := NotifiedPayment{Transaction: transaction, LedgerID: sentEntry(walletID, transaction.PaymentHash)}
+
+			log.Printf("%s sent %d msat (payment %s)", walletID, transaction.Amount, transaction.PaymentHash)
+			eventBus.Publish(events.TypePaymentSent, walletID, payment)
+		}()
+
+	default:
+		log.Printf("Ignoring unknown %q notification of %s", notification.Type, walletID)
+	}
+}
+
+// sentEntry returns the ID of the ledger entry of a payment the wallet sent through the API, if any.
+// The hash of a payment whose invoice the service asked for itself is only recorded once the
+// wallet answers, which may be after its notification, so pending payments are waited for briefly.
+func sentEntry(walletID, paymentHash string) string {
+	for wait := 0; ; wait++ {
+		pending := false
+		for _, entry := range paymentLedger.Sent(walletID, time.Now().Add(-24*time.Hour)) {
+			if entry.PaymentHash == paymentHash {
+				return entry.ID
+			}
+			pending = pending || (entry.Status == ledger.StatusPending && entry.PaymentHash == "")
+		}
+		if !pending || wait == 10 {
+			return ""
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// withdrawLNURL funds a configured wallet from an LNURL-withdraw link
+// It creates an invoice on the wallet for amount msats, or the most the service
+// allows when amount is zero, and hands it to the service to pay
+func withdrawLNURL(walletURIs map[string]string, walletID string, link string, amount int64) (invoices.Invoice, *lnurl.WithdrawParams, error) {
+	endpoint, err := lnurl.Decode(link)
+	if err != nil {
+		return invoices.Invoice{}, nil, err
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	
+	params, err := lnurlClient.FetchWithdrawParams(ctx, endpoint)
+	if err != nil {
+		return invoices.Invoice{}, nil, err
+	}
+	
+	if amount == 0 {
+		amount = params.MaxWithdrawable
+	}
+	if amount < params.MinWithdrawable || amount > params.MaxWithdrawable {
+		return invoices.Invoice{}, params, &lnurl.Error{Reason: fmt.Sprintf("amount of %d msats is outside the %d to %d msats the service allows",
+			amount, params.MinWithdrawable, params.MaxWithdrawable)}
+	}
+	
+	invoice, err := createInvoice(walletURIs, walletID, nip47.InvoiceParams{
+		AmountMsats: amount,
+		Description: params.DefaultDescription,
+	})
+	if err != nil {
+		return invoices.Invoice{}, params, err
+	}
+	
+	// Track the invoice before the service can pay it
+	record := trackInvoice(invoices.Invoice{Wallet: walletID}, invoice)
+	
+	if err := lnurlClient.SubmitInvoice(ctx, params, invoice.Raw); err != nil {
+		return record, params, err
+	}
+	
+	log.Printf("Submitted invoice %s for %d msat to LNURL-withdraw service", invoice.PaymentHash, amount)
+	
+	return record, params, nil
+}
+
+// lookupInvoice asks a configured wallet for the state of one of its invoices
+func lookupInvoice(ctx context.Context, walletID string, paymentHash string) (*nip47.Transaction, error) {
+	walletURI, ok := walletURIs[walletID]
+	if !ok {
+		return nil, &WalletNotFoundError{Wallet: walletID}
+	}
+
+	client, err := nip47.NewClient(walletURI)
+	if err != nil {
+		return nil, &WalletError{Wallet: walletID, Err: fmt.Errorf("failed to initialize wallet: %w", err)}
+	}
+
+	return client.LookupInvoice(ctx, paymentHash)
+}
+
+// lightningNetwork returns the network invoices must be issued for, set by NWC_NETWORK
+func lightningNetwork() string {
+	if network := wallet.LoadSetting("NWC_NETWORK"); network != "" {
+		return network
 	}
+	return bolt11.NetworkMainnet
+}
+
+// sendPayment makes the payment described by entry from the sender wallet
+// It enforces the sender's fee ceiling and spending policy and records the payment in the ledger
+// pay is only called once the payment has been reserved
+func sendPayment(senderClient *nip47.Client, entry ledger.Entry, feeLimit wallet.FeePolicy, pay func() (*nip47.Payment, error)) (*PaymentResult, error) {
+	sender := entry.Sender
 	
-	// Check sender balance
+	// Check sender balance, including room for the maximum fee
+	maxFee, _ := feeCeiling(sender, feeLimit, entry.AmountMsats)
 	balance, err := senderClient.GetBalance()
 	if err != nil {
-		return fmt.Errorf("failed to get sender balance: %w", err)
+		return nil, &WalletError{Wallet: sender, Err: fmt.Errorf("failed to get sender balance: %w", err)}
 	}
 	
 	log.Printf("%s balance: %d msat", sender, balance.Balance)
 	
-	if balance.Balance < int64(amount) {
-		return fmt.Errorf("insufficient funds in sender wallet: %d msat needed, %d msat available", amount, balance.Balance)
+	if balance.Balance < entry.AmountMsats+maxFee {
+		return nil, &InsufficientFundsError{Wallet: sender, NeededMsats: entry.AmountMsats + maxFee, AvailableMsats: balance.Balance}
 	}
 	
-	// Create invoice from recipient
-	invoice, err := recipientClient.MakeInvoice(amount, fmt.Sprintf("Payment from %s to %s", sender, recipient))
+	payment, err := reservePayment(entry, feeLimit)
 	if err != nil {
-		return fmt.Errorf("failed to create invoice: %w", err)
+		return nil, err
 	}
 	
-	log.Printf("Created invoice for %d msat", amount)
+	result, err := pay()
+	return finishPayment(payment, result, err)
+}
+
+// reservedPayment is a payment recorded as pending in the ledger together with its fee ceiling
+type reservedPayment struct {
+	entry      ledger.Entry
+	maxFee     int64
+	feeLimited bool
+}
+
+// reservePayment records entry as pending in the ledger with its maximum fee
+// if it fits the sender's spending policy and its invoice is not already being paid
+func reservePayment(entry ledger.Entry, feeLimit wallet.FeePolicy) (*reservedPayment, error) {
+	sender := entry.Sender
+	
+	// Work out the highest fee this payment may cost
+	maxFee, feeLimited := feeCeiling(sender, feeLimit, entry.AmountMsats)
+	entry.FeesMsats = maxFee
+	
+	// Reserve the payment and its maximum fee in the ledger if it fits the sender's spending policy
+	entry, err := paymentLedger.Reserve(entry, func(history []ledger.Entry) error {
+		for _, previous := range history {
+			if entry.PaymentHash != "" && previous.PaymentHash == entry.PaymentHash && previous.Counts() {
+				return &DuplicatePaymentError{PaymentHash: entry.PaymentHash}
+			}
+		}
+		return budget.Check(sender, walletConfigs[sender].Spending, history, entry.AmountMsats+maxFee, entry.EuroAmount, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
 	
-	// Pay invoice with sender
-	result, err := senderClient.PayInvoice(invoice)
+	return &reservedPayment{entry: entry, maxFee: maxFee, feeLimited: feeLimited}, nil
+}
+
+// finishPayment records the outcome of a reserved payment in the ledger
+func finishPayment(reserved *reservedPayment, result *nip47.Payment, err error) (*PaymentResult, error) {
+	entry, maxFee := reserved.entry, reserved.maxFee
 	if err != nil {
-		return fmt.Errorf("payment failed: %w", err)
+		if failed, ferr := paymentLedger.Fail(entry.ID, err); ferr != nil {
+			log.Printf("Failed to record payment %s in ledger: %v", entry.ID, ferr)
+		} else {
+			eventBus.Publish(events.TypePaymentFailed, failed.Sender, failed)
+		}
+		return nil, err
 	}
 	
 	log.Printf("Payment successful! Fees: %d msat", result.FeesPaid)
 	
-	// Check updated balances
-	senderBalance, _ := senderClient.GetBalance()
-	recipientBalance, _ := recipientClient.GetBalance()
+	// Payments whose hash was not known up front, such as keysend, are identified by the hash of their preimage
+	paymentHash := entry.PaymentHash
+	if preimage, err := hex.DecodeString(result.Preimage); paymentHash == "" && err == nil && len(preimage) == 32 {
+		hash := sha256.Sum256(preimage)
+		paymentHash = hex.EncodeToString(hash[:])
+	}
 	
-	log.Printf("Updated %s balance: %d msat", sender, senderBalance.Balance)
-	log.Printf("Updated %s balance: %d msat", recipient, recipientBalance.Balance)
+	payment := &PaymentResult{
+		LedgerID:    entry.ID,
+		PaymentHash: paymentHash,
+		Preimage:    result.Preimage,
+		FeesPaid:    result.FeesPaid,
+	}
+	if reserved.feeLimited {
+		payment.MaxFeeMsats = &maxFee
+	}
 	
-	return nil
+	if completed, err := paymentLedger.Complete(entry.ID, result.FeesPaid, paymentHash); err != nil {
+		log.Printf("Failed to record payment %s in ledger: %v", entry.ID, err)
+	} else {
+		eventBus.Publish(events.TypePaymentSucceeded, completed.Sender, completed)
+	}
+	
+	// NIP-47 offers no way to cap routing fees, so a wallet that ignored the
+	// reserved ceiling can only be caught once the payment has settled. The
+	// payment is returned with the error so callers can still report it.
+	if reserved.feeLimited && result.FeesPaid > maxFee {
+		log.Printf("WARNING: %s paid %d msat in fees, above the %d msat limit", entry.Sender, result.FeesPaid, maxFee)
+		return payment, &FeeLimitExceededError{
+			Wallet:        entry.Sender,
+			LedgerID:      entry.ID,
+			PaymentHash:   paymentHash,
+			Preimage:      result.Preimage,
+			FeesPaidMsats: result.FeesPaid,
+			MaxFeeMsats:   maxFee,
+		}
+	}
+	
+	return payment, nil
+}
+
+// payBolt11 pays an invoice from the sender wallet, retrying transient failures
+// renew, when set, replaces the invoice if it expires before a retry; without it
+// an expired invoice ends the retries
+func payBolt11(senderClient *nip47.Client, invoice *bolt11.Invoice, renew func() (*bolt11.Invoice, error)) (*nip47.Payment, error) {
+	payment, err := retryPayment("payment of invoice "+invoice.PaymentHash, func() (*nip47.Payment, error) {
+		return senderClient.PayBolt11(context.Background(), invoice.Raw)
+	}, func() (*nip47.Payment, error) {
+		// Make sure the last attempt did not go through after all
+		payment, err := lookupPayment(senderClient, invoice.PaymentHash)
+		if err != nil || payment != nil {
+			return payment, err
+		}
+		
+		if invoice.Expired(time.Now()) {
+			if renew == nil {
+				return nil, fmt.Errorf("invoice expired at %s", invoice.ExpiresAt.Format(time.RFC3339))
+			}
+			if invoice, err = renew(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payment failed: %w", err)
+	}
+	
+	return payment, nil
+}
+
+// retryPayment makes a payment with pay, trying again after retriable failures
+// Before each retry, unless the wallet refused the last attempt outright, check must
+// confirm the payment did not go through: it returns the payment if it did, or an error
+// if that cannot be known. Without check only refused attempts are retried.
+func retryPayment(description string, pay func() (*nip47.Payment, error), check func() (*nip47.Payment, error)) (*nip47.Payment, error) {
+	for attempt := 1; ; attempt++ {
+		payment, err := pay()
+		if err == nil {
+			return payment, nil
+		}
+		
+		class := nip47.Classify(err)
+		refused := class == nip47.ClassRateLimited
+		if !retryPolicy.Retry(attempt) || !nip47.Retriable(err) || (check == nil && !refused) {
+			return nil, err
+		}
+		
+		delay := retryPolicy.Delay(attempt)
+		log.Printf("Attempt %d of %s failed (%s), retrying in %s: %v", attempt, description, class, delay.Round(time.Millisecond), err)
+		time.Sleep(delay)
+		
+		if !refused {
+			paid, checkErr := check()
+			if checkErr != nil {
+				log.Printf("Not retrying %s: %v", description, checkErr)
+				return nil, err
+			}
+			if paid != nil {
+				log.Printf("No retry needed, the %s went through after all", description)
+				return paid, nil
+			}
+		}
+	}
+}
+
+// errPaymentPending stops retries while the wallet still reports a payment in flight
+var errPaymentPending = errors.New("the wallet still reports the payment as pending")
+
+// lookupPayment asks the sender wallet whether it has paid the invoice with paymentHash
+// It returns the payment if it settled, nothing if it certainly did not, and an error
+// if the payment is still in flight or its state cannot be told
+func lookupPayment(senderClient *nip47.Client, paymentHash string) (*nip47.Payment, error) {
+	transaction, err := senderClient.LookupInvoice(context.Background(), paymentHash)
+	if nip47.IsCode(err, nip47.CodeNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not look up the payment: %w", err)
+	}
+	
+	if transaction.Settled() {
+		return &nip47.Payment{Preimage: transaction.Preimage, FeesPaid: transaction.FeesPaid}, nil
+	}
+	
+	// A wallet that also issued the invoice may report it as incoming; it is unpaid
+	// An outgoing payment is only known to have failed if the wallet says so
+	if transaction.Type == nip47.TypeIncoming || transaction.State == nip47.StateFailed || transaction.State == nip47.StateExpired {
+		return nil, nil
+	}
+	return nil, errPaymentPending
+}
+
+// feeCeiling returns the maximum fee sender may pay for a payment of amount msats.
+// The wallet's fee policy overrides the global one and requestLimit may only lower the result.
+// It returns false when no limit applies.
+func feeCeiling(sender string, requestLimit wallet.FeePolicy, amount int64) (int64, bool) {
+	ceiling, limited := defaultFeePolicy.Merge(walletConfigs[sender].Fees).Ceiling(amount)
+
+	requested, requestLimited := requestLimit.Ceiling(amount)
+	if requestLimited && (!limited || requested < ceiling) {
+		ceiling, limited = requested, true
+	}
+
+	return ceiling, limited
 }
 
 // Wallet URI functions moved to api.go
@@ -79,6 +1222,81 @@ func makePayment(walletURIs map[string]string, sender string, recipient string,
 // euroToMsats converts Euro amount to millisatoshis using current exchange rate
 // Returns the equivalent amount in millisatoshis
 func euroToMsats(euroAmount float64) (int, error) {
+	btcPriceInEur, err := fetchBTCPriceEUR()
+	if err != nil {
+		return 0, err
+	}
+	
+	return euroToMsatsAt(euroAmount, btcPriceInEur), nil
+}
+
+// euroToMsatsAt converts a Euro amount to millisatoshis at the given BTC price in Euro
+func euroToMsatsAt(euroAmount float64, btcPriceInEur float64) int {
+	// Calculate conversions
+	// 1 BTC = 100,000,000 satoshis
+	// 1 satoshi = 1,000 millisatoshis
+	btcAmount := euroAmount / btcPriceInEur
+	satoshis := btcAmount * 100000000
+	millisatoshis := satoshis * 1000
+	
+	// Return as integer (rounded)
+	return int(millisatoshis)
+}
+
+// msatsToEuro converts millisatoshis to a Euro amount using current exchange rate
+func msatsToEuro(msats int64) (float64, error) {
+	btcPriceInEur, err := fetchBTCPriceEUR()
+	if err != nil {
+		return 0, err
+	}
+	
+	return msatsToEuroAt(msats, btcPriceInEur), nil
+}
+
+// msatsToEuroAt converts millisatoshis to a Euro amount at the given BTC price in Euro
+func msatsToEuroAt(msats int64, btcPriceInEur float64) float64 {
+	return float64(msats) / 1000 / 100000000 * btcPriceInEur
+}
+
+// btcPriceCacheTTL is how long cachedBTCPriceEUR reuses a fetched price
+const btcPriceCacheTTL = time.Minute
+
+// btcPriceCache holds the last price fetched by cachedBTCPriceEUR
+var btcPriceCache struct {
+	sync.Mutex
+	price     float64
+	fetchedAt time.Time
+}
+
+// cachedBTCPriceEUR returns the price of one bitcoin in Euro, fetching it at most once a minute.
+// It is meant for amounts that are only reported, such as received payments; payments use fetchBTCPriceEUR.
+func cachedBTCPriceEUR() (float64, error) {
+	btcPriceCache.Lock()
+	defer btcPriceCache.Unlock()
+	
+	if time.Since(btcPriceCache.fetchedAt) < btcPriceCacheTTL {
+		return btcPriceCache.price, nil
+	}
+	
+	price, err := fetchBTCPriceEUR()
+	if err != nil {
+		return 0, err
+	}
+	btcPriceCache.price, btcPriceCache.fetchedAt = price, time.Now()
+	return price, nil
+}
+
+// fetchBTCPriceEUR returns the current price of one bitcoin in Euro
+func fetchBTCPriceEUR() (float64, error) {
+	price, err := requestBTCPriceEUR()
+	if err != nil {
+		return 0, &ExchangeRateError{Err: err}
+	}
+	return price, nil
+}
+
+// requestBTCPriceEUR asks CoinGecko for the price of one bitcoin in Euro
+func requestBTCPriceEUR() (float64, error) {
 	// CoinGecko API endpoint for BTC price in EUR
 	url := "https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=eur"
 	
@@ -109,15 +1327,7 @@ func euroToMsats(euroAmount float64) (int, error) {
 		return 0, fmt.Errorf("could not find BTC/EUR exchange rate in response")
 	}
 	
-	// Calculate conversions
-	// 1 BTC = 100,000,000 satoshis
-	// 1 satoshi = 1,000 millisatoshis
-	btcAmount := euroAmount / btcPriceInEur
-	satoshis := btcAmount * 100000000
-	millisatoshis := satoshis * 1000000
-	
-	// Return as integer (rounded)
-	return int(millisatoshis), nil
+	return btcPriceInEur, nil
 }
 
 func main() {
@@ -2,15 +2,31 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ErrorResponse represents an error response
+// ErrorResponse represents an error response, in the same form as the API's own errors
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retriable bool   `json:"retriable"`
+}
+
+// abortWithError ends a request with an ErrorResponse
+func abortWithError(c *gin.Context, status int, code string, message string, retriable bool) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Error:     message,
+		Code:      code,
+		Message:   message,
+		Retriable: retriable,
+	})
 }
 
 // LoggingMiddleware logs information about each request
@@ -38,3 +54,124 @@ func LoggingMiddleware() gin.HandlerFunc {
 			duration.String() + "\n"))
 	}
 }
+
+// PrincipalKey is the gin context key holding the authenticated *Principal
+const PrincipalKey = "principal"
+
+// Permissions that can be granted to a principal
+const (
+	PermissionAll      = "*"
+	PermissionPayments = "payments"
+	PermissionConvert  = "convert"
+	PermissionWallets  = "wallets"
+	PermissionInvoices = "invoices"
+	// PermissionAdmin manages the service itself, such as its webhooks
+	PermissionAdmin = "admin"
+)
+
+// Principal identifies the caller of an authenticated request
+type Principal struct {
+	ID          string   `json:"id"`
+	Scheme      string   `json:"scheme"`
+	Permissions []string `json:"permissions"`
+}
+
+// Can reports whether the principal has been granted the permission
+func (p *Principal) Can(permission string) bool {
+	for _, granted := range p.Permissions {
+		if granted == PermissionAll || granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrincipal returns the principal authenticated by AuthMiddleware, if any
+func GetPrincipal(c *gin.Context) *Principal {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
+
+// AuthScheme authenticates a request using one kind of credentials.
+// ok is false when the request carries no credentials for this scheme,
+// so the next scheme can be tried.
+type AuthScheme func(c *gin.Context) (principal *Principal, ok bool, err error)
+
+// AuthMiddleware authenticates requests with the first scheme whose credentials are present
+func AuthMiddleware(schemes ...AuthScheme) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, scheme := range schemes {
+			principal, ok, err := scheme(c)
+			if !ok {
+				continue
+			}
+			if errors.Is(err, ErrBodyTooLarge) {
+				abortWithError(c, http.StatusRequestEntityTooLarge, "body_too_large", err.Error(), false)
+				return
+			}
+			if err != nil {
+				abortWithError(c, http.StatusUnauthorized, "unauthorized", err.Error(), false)
+				return
+			}
+
+			c.Set(PrincipalKey, principal)
+			c.Next()
+			return
+		}
+
+		abortWithError(c, http.StatusUnauthorized, "unauthorized", "API key is required. Please provide it in the api_key query parameter", false)
+	}
+}
+
+// principalContextKey is the request context key of a principal authenticated earlier
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying an already authenticated principal,
+// for requests the server makes on behalf of a connection authenticated when it opened
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// ContextAuth accepts the principal carried by the request context.
+// Only the server itself can set it, clients cannot.
+func ContextAuth() AuthScheme {
+	return func(c *gin.Context) (*Principal, bool, error) {
+		principal, ok := c.Request.Context().Value(principalContextKey{}).(*Principal)
+		return principal, ok && principal != nil, nil
+	}
+}
+
+// APIKeyAuth accepts a static API key from the api_key query parameter or the X-API-Key header
+func APIKeyAuth(apiKey string) AuthScheme {
+	return func(c *gin.Context) (*Principal, bool, error) {
+		requestAPIKey := c.Query("api_key")
+		if requestAPIKey == "" {
+			requestAPIKey = c.GetHeader("X-API-Key")
+		}
+		if requestAPIKey == "" {
+			return nil, false, nil
+		}
+
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(requestAPIKey), []byte(apiKey)) != 1 {
+			return nil, true, errors.New("Invalid API key")
+		}
+
+		return &Principal{ID: "api_key", Scheme: "api_key", Permissions: []string{PermissionAll}}, true, nil
+	}
+}
+
+// RequirePermission rejects requests whose principal lacks the permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil || !principal.Can(permission) {
+			abortWithError(c, http.StatusForbidden, "forbidden", "missing permission: "+permission, false)
+			return
+		}
+		c.Next()
+	}
+}
@@ -9,7 +9,8 @@ import (
 func CORSMiddleware() gin.HandlerFunc {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "X-API-Key", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "X-API-Key", "Authorization",
+		"X-NWC-Key-Id", "X-NWC-Timestamp", "X-NWC-Nonce", "X-NWC-Signature"}
 	config.AllowCredentials = true
 	config.ExposeHeaders = []string{"Content-Length", "X-API-Key"}
 	
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers carrying an HMAC request signature
+const (
+	HMACKeyIDHeader     = "X-NWC-Key-Id"
+	HMACTimestampHeader = "X-NWC-Timestamp"
+	HMACNonceHeader     = "X-NWC-Nonce"
+	HMACSignatureHeader = "X-NWC-Signature"
+)
+
+// maxSignedBodySize caps the body of a signed request, which is read whole for hashing
+const maxSignedBodySize = 1 << 20
+
+// ErrBodyTooLarge is returned when a signed request, or one whose JSON body is read
+// for rate limiting, has a body over maxSignedBodySize
+var ErrBodyTooLarge = fmt.Errorf("request body is larger than %d bytes", maxSignedBodySize)
+
+// HMACConfig configures HMAC request signing
+type HMACConfig struct {
+	// Secrets maps key IDs to their shared secrets
+	Secrets map[string]string
+	// MaxSkew is how far a request timestamp may drift from the server clock
+	MaxSkew time.Duration
+	// Nonces remembers nonces already used within the MaxSkew window
+	Nonces NonceStore
+}
+
+// NonceStore records nonces to detect replayed requests
+type NonceStore interface {
+	// Use marks the nonce as used until ttl elapses.
+	// It returns false when the nonce has already been used.
+	Use(nonce string, ttl time.Duration) bool
+}
+
+// HMACAuth authenticates requests signed with a shared secret.
+//
+// The signature is the hex encoded HMAC-SHA256 of the string
+//
+//	METHOD \n PATH \n TIMESTAMP \n NONCE \n hex(SHA256(BODY))
+//
+// where PATH includes the query string and TIMESTAMP is in Unix seconds.
+func HMACAuth(config HMACConfig) AuthScheme {
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.Nonces == nil {
+		config.Nonces = NewMemoryNonceStore()
+	}
+
+	return func(c *gin.Context) (*Principal, bool, error) {
+		keyID := c.GetHeader(HMACKeyIDHeader)
+		signature := c.GetHeader(HMACSignatureHeader)
+		if keyID == "" && signature == "" {
+			return nil, false, nil
+		}
+
+		secret, ok := config.Secrets[keyID]
+		if !ok {
+			return nil, true, errors.New("unknown signing key")
+		}
+
+		timestamp, err := strconv.ParseInt(c.GetHeader(HMACTimestampHeader), 10, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid %s header", HMACTimestampHeader)
+		}
+		skew := time.Since(time.Unix(timestamp, 0))
+		if skew > config.MaxSkew || skew < -config.MaxSkew {
+			return nil, true, errors.New("request timestamp is outside the allowed window")
+		}
+
+		nonce := c.GetHeader(HMACNonceHeader)
+		if nonce == "" {
+			return nil, true, fmt.Errorf("%s header is required", HMACNonceHeader)
+		}
+
+		body, err := readSignedBody(c)
+		if err != nil {
+			return nil, true, err
+		}
+
+		expected := SignRequest(secret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body)
+		provided, err := hex.DecodeString(signature)
+		if err != nil || !hmac.Equal(provided, expected) {
+			return nil, true, errors.New("invalid request signature")
+		}
+
+		// Only remember nonces of correctly signed requests so that
+		// unauthenticated callers cannot exhaust a key's nonce space
+		if !config.Nonces.Use(keyID+":"+nonce, 2*config.MaxSkew) {
+			return nil, true, errors.New("request nonce has already been used")
+		}
+
+		return &Principal{ID: keyID, Scheme: "hmac", Permissions: []string{PermissionAll}}, true, nil
+	}
+}
+
+// SignRequest computes the HMAC signature of a request as verified by HMACAuth
+func SignRequest(secret, method, path string, timestamp int64, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+
+	return mac.Sum(nil)
+}
+
+// readSignedBody reads the whole body of a signed request and puts it back for the handler.
+// Bodies over maxSignedBodySize are refused rather than verified on a part of them.
+func readSignedBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, ErrBodyTooLarge
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// ParseHMACKeys parses a "keyID:secret,keyID:secret" list of signing keys
+func ParseHMACKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		keyID, secret, found := strings.Cut(entry, ":")
+		if !found || keyID == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signing key entry %q, expected keyID:secret", entry)
+		}
+		keys[keyID] = secret
+	}
+
+	return keys, nil
+}
+
+// MemoryNonceStore keeps used nonces in process memory.
+// Nonces are also kept in order of expiry, so forgetting the expired ones
+// only looks at those and not at every nonce in use.
+type MemoryNonceStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	order   nonceQueue
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{expires: make(map[string]time.Time)}
+}
+
+// Use implements NonceStore
+func (s *MemoryNonceStore) Use(nonce string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for len(s.order) > 0 && now.After(s.order[0].expiry) {
+		expired := heap.Pop(&s.order).(nonceExpiry)
+		if s.expires[expired.nonce].Equal(expired.expiry) {
+			delete(s.expires, expired.nonce)
+		}
+	}
+
+	if _, used := s.expires[nonce]; used {
+		return false
+	}
+	expiry := now.Add(ttl)
+	s.expires[nonce] = expiry
+	heap.Push(&s.order, nonceExpiry{nonce: nonce, expiry: expiry})
+
+	return true
+}
+
+// nonceExpiry is when a used nonce may be forgotten
+type nonceExpiry struct {
+	nonce  string
+	expiry time.Time
+}
+
+// nonceQueue is a min-heap of nonces by expiry
+type nonceQueue []nonceExpiry
+
+func (q nonceQueue) Len() int           { return len(q) }
+func (q nonceQueue) Less(i, j int) bool { return q[i].expiry.Before(q[j].expiry) }
+func (q nonceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nonceQueue) Push(x any)        { *q = append(*q, x.(nonceExpiry)) }
+
+func (q *nonceQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
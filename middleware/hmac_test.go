@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs a request through AuthMiddleware with the given schemes, echoing the body
+// the handler received
+func serve(t *testing.T, request *http.Request, schemes ...AuthScheme) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := gin.New()
+	router.Any("/*path", AuthMiddleware(schemes...), func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(http.StatusOK, "%s", body)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// signedRequest builds a request signed with the given secret
+func signedRequest(secret, method, path string, timestamp int64, nonce string, body []byte) *http.Request {
+	request := httptest.NewRequest(method, path, bytes.NewReader(body))
+	request.Header.Set(HMACKeyIDHeader, "device")
+	request.Header.Set(HMACTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HMACNonceHeader, nonce)
+	request.Header.Set(HMACSignatureHeader, hex.EncodeToString(SignRequest(secret, method, path, timestamp, nonce, body)))
+	return request
+}
+
+func TestHMACAuth(t *testing.T) {
+	now := time.Now().Unix()
+	body := []byte(`{"sender":"WALLET_A"}`)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		status  int
+	}{
+		{
+			name:    "valid signature",
+			request: func() *http.Request { return signedRequest("secret", "POST", "/nwc_payment?x=1", now, "n1", body) },
+			status:  http.StatusOK,
+		},
+		{
+			name:    "wrong secret",
+			request: func() *http.Request { return signedRequest("other", "POST", "/nwc_payment", now, "n2", body) },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name: "unknown key",
+			request: func() *http.Request {
+				request := signedRequest("secret", "POST", "/nwc_payment", now, "n3", body)
+				request.Header.Set(HMACKeyIDHeader, "unknown")
+				return request
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "stale timestamp",
+			request: func() *http.Request { return signedRequest("secret", "POST", "/nwc_payment", now-600, "n4", body) },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "missing nonce",
+			request: func() *http.Request { return signedRequest("secret", "POST", "/nwc_payment", now, "", body) },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			request: func() *http.Request {
+				request := signedRequest("secret", "POST", "/nwc_payment", now, "n5", body)
+				request.Body = httptest.NewRequest("POST", "/", strings.NewReader(`{"sender":"WALLET_B"}`)).Body
+				return request
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered path",
+			request: func() *http.Request {
+				request := signedRequest("secret", "POST", "/nwc_payment", now, "n6", body)
+				request.URL.Path = "/keysend"
+				return request
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "body over the limit",
+			request: func() *http.Request {
+				return signedRequest("secret", "POST", "/nwc_payment", now, "n7", bytes.Repeat([]byte("a"), maxSignedBodySize+1))
+			},
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	scheme := HMACAuth(HMACConfig{Secrets: map[string]string{"device": "secret"}})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(t, test.request(), scheme)
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestHMACAuthPassesBody(t *testing.T) {
+	body := bytes.Repeat([]byte("b"), maxSignedBodySize)
+	scheme := HMACAuth(HMACConfig{Secrets: map[string]string{"device": "secret"}})
+
+	recorder := serve(t, signedRequest("secret", "POST", "/nwc_payment", time.Now().Unix(), "n", body), scheme)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	if recorder.Body.Len() != len(body) {
+		t.Fatalf("handler received %d bytes, want %d", recorder.Body.Len(), len(body))
+	}
+}
+
+func TestHMACAuthRejectsReplay(t *testing.T) {
+	scheme := HMACAuth(HMACConfig{Secrets: map[string]string{"device": "secret"}})
+	now := time.Now().Unix()
+
+	if recorder := serve(t, signedRequest("secret", "GET", "/balance", now, "once", nil), scheme); recorder.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", recorder.Code)
+	}
+	if recorder := serve(t, signedRequest("secret", "GET", "/balance", now, "once", nil), scheme); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status = %d, want 401", recorder.Code)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+
+	if !store.Use("a", 20*time.Millisecond) {
+		t.Fatal("fresh nonce refused")
+	}
+	if !store.Use("b", time.Hour) {
+		t.Fatal("fresh nonce refused")
+	}
+	if store.Use("a", time.Hour) {
+		t.Fatal("used nonce accepted")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !store.Use("a", time.Hour) {
+		t.Fatal("expired nonce refused")
+	}
+	if store.Use("b", time.Hour) {
+		t.Fatal("unexpired nonce accepted")
+	}
+	if len(store.expires) != 2 || store.order.Len() != 2 {
+		t.Fatalf("kept %d nonces in %d queue entries, want 2", len(store.expires), store.order.Len())
+	}
+}
+
+func TestParseHMACKeys(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "a:1, b:2", want: map[string]string{"a": "1", "b": "2"}},
+		{value: "a:x:y", want: map[string]string{"a": "x:y"}},
+		{value: "a", wantErr: true},
+		{value: ":secret", wantErr: true},
+		{value: "a:", wantErr: true},
+	}
+
+	for _, test := range tests {
+		keys, err := ParseHMACKeys(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseHMACKeys(%q) error = %v, want error %t", test.value, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if len(keys) != len(test.want) {
+			t.Errorf("ParseHMACKeys(%q) = %v, want %v", test.value, keys, test.want)
+		}
+		for id, secret := range test.want {
+			if keys[id] != secret {
+				t.Errorf("ParseHMACKeys(%q)[%q] = %q, want %q", test.value, id, keys[id], secret)
+			}
+		}
+	}
+}
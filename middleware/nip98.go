@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// KindHTTPAuth is the event kind used by NIP-98 HTTP authentication
+const KindHTTPAuth = 27235
+
+// NIP98Config configures NIP-98 Nostr HTTP authentication
+type NIP98Config struct {
+	// Permissions maps hex encoded pubkeys to the permissions they are granted
+	Permissions map[string][]string
+	// BaseURL is the public scheme and host of this server, e.g. https://pay.example.com.
+	// When empty it is derived from the request.
+	BaseURL string
+	// MaxAge is how far an event's created_at may drift from the server clock
+	MaxAge time.Duration
+	// Events remembers event IDs already used within the MaxAge window
+	Events NonceStore
+}
+
+// NIP98Auth authenticates requests carrying a signed kind 27235 event
+// in an "Authorization: Nostr <base64 event>" header
+func NIP98Auth(config NIP98Config) AuthScheme {
+	if config.MaxAge <= 0 {
+		config.MaxAge = time.Minute
+	}
+	if config.Events == nil {
+		config.Events = NewMemoryNonceStore()
+	}
+
+	return func(c *gin.Context) (*Principal, bool, error) {
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Nostr") {
+			return nil, false, nil
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+		if err != nil {
+			return nil, true, errors.New("invalid Nostr authorization header encoding")
+		}
+
+		var event nostr.Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, true, errors.New("invalid Nostr authorization event")
+		}
+
+		if event.Kind != KindHTTPAuth {
+			return nil, true, fmt.Errorf("authorization event must be of kind %d", KindHTTPAuth)
+		}
+		if !event.CheckID() {
+			return nil, true, errors.New("authorization event ID does not match its content")
+		}
+		if valid, err := event.CheckSignature(); err != nil || !valid {
+			return nil, true, errors.New("invalid authorization event signature")
+		}
+
+		age := time.Since(event.CreatedAt.Time())
+		if age > config.MaxAge || age < -config.MaxAge {
+			return nil, true, errors.New("authorization event is outside the allowed time window")
+		}
+
+		if !strings.EqualFold(event.Tags.Find("method").Value(), c.Request.Method) {
+			return nil, true, errors.New("authorization event method does not match the request")
+		}
+		if event.Tags.Find("u").Value() != requestURL(c, config.BaseURL) {
+			return nil, true, errors.New("authorization event URL does not match the request")
+		}
+
+		// A request with a body must commit to it, or a captured event could
+		// be replayed with another body within its time window
+		body, err := readSignedBody(c)
+		if err != nil {
+			return nil, true, err
+		}
+		payload := event.Tags.Find("payload")
+		if payload == nil && len(body) > 0 {
+			return nil, true, errors.New("authorization event must have a payload tag for a request with a body")
+		}
+		if payload != nil {
+			bodyHash := sha256.Sum256(body)
+			if !strings.EqualFold(payload.Value(), hex.EncodeToString(bodyHash[:])) {
+				return nil, true, errors.New("authorization event payload does not match the request body")
+			}
+		}
+
+		permissions, ok := config.Permissions[event.PubKey]
+		if !ok {
+			return nil, true, errors.New("pubkey is not allowed to access this API")
+		}
+
+		if !config.Events.Use(event.ID, 2*config.MaxAge) {
+			return nil, true, errors.New("authorization event has already been used")
+		}
+
+		return &Principal{ID: event.PubKey, Scheme: "nip98", Permissions: permissions}, true, nil
+	}
+}
+
+// requestURL reconstructs the absolute URL a client used to reach this server
+func requestURL(c *gin.Context, baseURL string) string {
+	return PublicBaseURL(c, baseURL) + c.Request.URL.RequestURI()
+}
+
+// PublicBaseURL returns the URL clients reach this server at: baseURL when it is
+// configured, otherwise the scheme and host of the request
+func PublicBaseURL(c *gin.Context, baseURL string) string {
+	if baseURL == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+			scheme = forwarded
+		}
+		baseURL = scheme + "://" + c.Request.Host
+	}
+
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// ParseNIP98Permissions parses a "pubkey:perm|perm,pubkey:perm" list of allowed pubkeys.
+// Pubkeys may be hex encoded or npub strings.
+func ParseNIP98Permissions(value string) (map[string][]string, error) {
+	permissions := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pubkey, granted, found := strings.Cut(entry, ":")
+		if !found || granted == "" {
+			return nil, fmt.Errorf("invalid NIP-98 entry %q, expected pubkey:permission|permission", entry)
+		}
+
+		if strings.HasPrefix(pubkey, "npub") {
+			prefix, decoded, err := nip19.Decode(pubkey)
+			if err != nil || prefix != "npub" {
+				return nil, fmt.Errorf("invalid npub %q", pubkey)
+			}
+			pubkey = decoded.(string)
+		}
+		if !nostr.IsValidPublicKey(pubkey) {
+			return nil, fmt.Errorf("invalid pubkey %q", pubkey)
+		}
+
+		permissions[pubkey] = strings.Split(granted, "|")
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// nip98Request builds a request authorized by an event signed with sk, after edit adjusts it
+func nip98Request(t *testing.T, sk, method, url string, body []byte, edit func(event *nostr.Event)) *http.Request {
+	t.Helper()
+
+	event := nostr.Event{
+		Kind:      KindHTTPAuth,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"u", url}, {"method", method}},
+	}
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		event.Tags = append(event.Tags, nostr.Tag{"payload", hex.EncodeToString(hash[:])})
+	}
+	if edit != nil {
+		edit(&event)
+	}
+	if err := event.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(event)
+	request := httptest.NewRequest(method, url, bytes.NewReader(body))
+	request.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(raw))
+	return request
+}
+
+func TestNIP98Auth(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pubkey, _ := nostr.GetPublicKey(sk)
+	other := nostr.GeneratePrivateKey()
+
+	const url = "http://example.com/nwc_payment?x=1"
+	body := []byte(`{"sender":"WALLET_A"}`)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		status  int
+	}{
+		{
+			name:    "valid event with payload",
+			request: func() *http.Request { return nip98Request(t, sk, "POST", url, body, nil) },
+			status:  http.StatusOK,
+		},
+		{
+			name:    "valid event without body",
+			request: func() *http.Request { return nip98Request(t, sk, "GET", url, nil, nil) },
+			status:  http.StatusOK,
+		},
+		{
+			name: "body without payload tag",
+			request: func() *http.Request {
+				return nip98Request(t, sk, "POST", url, body, func(event *nostr.Event) {
+					event.Tags = event.Tags[:2]
+				})
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "payload of another body",
+			request: func() *http.Request {
+				request := nip98Request(t, sk, "POST", url, body, nil)
+				request.Body = httptest.NewRequest("POST", url, bytes.NewReader([]byte(`{"sender":"WALLET_B"}`))).Body
+				return request
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "unknown pubkey",
+			request: func() *http.Request { return nip98Request(t, other, "POST", url, body, nil) },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name: "wrong method",
+			request: func() *http.Request {
+				return nip98Request(t, sk, "POST", url, body, func(event *nostr.Event) {
+					event.Tags[1] = nostr.Tag{"method", "GET"}
+				})
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong URL",
+			request: func() *http.Request {
+				return nip98Request(t, sk, "POST", url, body, func(event *nostr.Event) {
+					event.Tags[0] = nostr.Tag{"u", "http://example.com/keysend"}
+				})
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong kind",
+			request: func() *http.Request {
+				return nip98Request(t, sk, "POST", url, body, func(event *nostr.Event) { event.Kind = 1 })
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "expired event",
+			request: func() *http.Request {
+				return nip98Request(t, sk, "POST", url, body, func(event *nostr.Event) {
+					event.CreatedAt = nostr.Timestamp(time.Now().Add(-time.Hour).Unix())
+				})
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "forged signature",
+			request: func() *http.Request {
+				request := nip98Request(t, other, "POST", url, body, nil)
+				raw, _ := base64.StdEncoding.DecodeString(request.Header.Get("Authorization")[len("Nostr "):])
+				var event nostr.Event
+				json.Unmarshal(raw, &event)
+				event.PubKey = pubkey
+				event.ID = event.GetID()
+				raw, _ = json.Marshal(event)
+				request.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(raw))
+				return request
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	scheme := NIP98Auth(NIP98Config{Permissions: map[string][]string{pubkey: {PermissionPayments}}})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(t, test.request(), scheme)
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestNIP98AuthRejectsReplay(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pubkey, _ := nostr.GetPublicKey(sk)
+	scheme := NIP98Auth(NIP98Config{Permissions: map[string][]string{pubkey: {PermissionPayments}}})
+
+	request := nip98Request(t, sk, "GET", "http://example.com/balance", nil, nil)
+	if recorder := serve(t, request, scheme); recorder.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", recorder.Code)
+	}
+
+	replayed := httptest.NewRequest("GET", "http://example.com/balance", nil)
+	replayed.Header.Set("Authorization", request.Header.Get("Authorization"))
+	if recorder := serve(t, replayed, scheme); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status = %d, want 401", recorder.Code)
+	}
+}
+
+func TestParseNIP98Permissions(t *testing.T) {
+	pubkey, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: pubkey + ":payments|invoices"},
+		{value: "  ," + pubkey + ":*"},
+		{value: pubkey, wantErr: true},
+		{value: pubkey + ":", wantErr: true},
+		{value: "abc:payments", wantErr: true},
+		{value: "npub1invalid:payments", wantErr: true},
+	}
+
+	for _, test := range tests {
+		permissions, err := ParseNIP98Permissions(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseNIP98Permissions(%q) error = %v, want error %t", test.value, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && len(permissions[pubkey]) == 0 {
+			t.Errorf("ParseNIP98Permissions(%q) = %v, want permissions for %s", test.value, permissions, pubkey)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit describes a token bucket that holds Burst tokens and refills
+// completely over Period
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<count>/<s|m|h|d>", e.g. "10/m"
+func ParseRateLimit(value string) (RateLimit, error) {
+	count, unit, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected count/period", value)
+	}
+
+	burst, err := strconv.Atoi(count)
+	if err != nil || burst <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit count %q", count)
+	}
+
+	periods := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+	}
+	period, ok := periods[unit]
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit period %q", unit)
+	}
+
+	return RateLimit{Burst: burst, Period: period}, nil
+}
+
+// refillInterval is how long it takes to refill a single token
+func (l RateLimit) refillInterval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// RateLimitStore keeps token buckets
+type RateLimitStore interface {
+	// Take removes a token from every bucket identified by keys, or from none of them
+	// when any is empty, so a rejected request does not use up the other buckets.
+	Take(keys []string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitResult is the outcome of taking tokens from a set of buckets
+type RateLimitResult struct {
+	Allowed bool
+	// Denied is the index of the empty bucket that refills last and RetryAfter
+	// how long until it has a token, when the request is not allowed
+	Denied     int
+	RetryAfter time.Duration
+}
+
+// RateLimitKey extracts the value a request is throttled by.
+// An empty value means the request is not limited on this dimension.
+type RateLimitKey struct {
+	Name  string
+	Value func(c *gin.Context) string
+}
+
+// ByPrincipal throttles requests per authenticated caller (API key, HMAC key or pubkey)
+var ByPrincipal = RateLimitKey{
+	Name: "principal",
+	Value: func(c *gin.Context) string {
+		principal := GetPrincipal(c)
+		if principal == nil {
+			return ""
+		}
+		return principal.Scheme + ":" + principal.ID
+	},
+}
+
+// ByClientIP throttles requests per client IP address
+var ByClientIP = RateLimitKey{
+	Name: "ip",
+	Value: func(c *gin.Context) string {
+		return c.ClientIP()
+	},
+}
+
+// ByBodyField throttles requests per value of a top level string field in the JSON body.
+// Other bodies, such as CSV and multipart uploads, give the field as a form or query parameter.
+// A JSON body over maxSignedBodySize is rejected with 413 rather than read in part.
+func ByBodyField(field string) RateLimitKey {
+	return RateLimitKey{
+		Name: field,
+		Value: func(c *gin.Context) string {
+			// Bodies without a content type are bound as JSON too
+			if contentType := c.ContentType(); contentType != "application/json" && contentType != "" {
+				value, ok := c.GetPostForm(field)
+				if !ok {
+					value = c.Query(field)
+				}
+				return strings.ToUpper(value)
+			}
+			if c.Request.Body == nil {
+				return ""
+			}
+
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				return ""
+			}
+			if len(body) > maxSignedBodySize {
+				abortWithError(c, http.StatusRequestEntityTooLarge, "body_too_large", ErrBodyTooLarge.Error(), false)
+				return ""
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(body, &fields); err != nil {
+				return ""
+			}
+			value, _ := fields[field].(string)
+			return strings.ToUpper(value)
+		},
+	}
+}
+
+// ByPathParam throttles requests per value of a path parameter.
+// name is the dimension reported when the limit is hit, as with ByBodyField.
+func ByPathParam(name, param string) RateLimitKey {
+	return RateLimitKey{
+		Name: name,
+		Value: func(c *gin.Context) string {
+			return strings.ToUpper(c.Param(param))
+		},
+	}
+}
+
+// RateLimitMiddleware throttles a route with one token bucket per key.
+// Buckets belong to the route they are used on, so routes sharing a limit do not share buckets.
+// A request is rejected with 429 and a Retry-After header when any of its buckets is empty.
+func RateLimitMiddleware(store RateLimitStore, limit RateLimit, keys ...RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var buckets []string
+		var names []string
+		for _, key := range keys {
+			value := key.Value(c)
+			// A key may reject a request it cannot read
+			if c.IsAborted() {
+				return
+			}
+			if value == "" {
+				continue
+			}
+			buckets = append(buckets, c.Request.Method+" "+c.FullPath()+":"+key.Name+":"+value)
+			names = append(names, key.Name)
+		}
+		if len(buckets) == 0 {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(buckets, limit)
+		if err != nil {
+			// Fail open so an unavailable backend does not take the API down
+			log.Printf("Rate limit store error: %v", err)
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			seconds := int(math.Ceil(result.RetryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			abortWithError(c, http.StatusTooManyRequests, "rate_limited",
+				fmt.Sprintf("rate limit exceeded for %s, retry in %d seconds", names[result.Denied], seconds), true)
+			return
+		}
+		c.Next()
+	}
+}
+
+// bucket is the state of a single in-memory token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(keys []string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	result := RateLimitResult{Allowed: true}
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b, ok := s.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(limit.Burst), updated: now, period: limit.Period}
+			s.buckets[key] = b
+		}
+
+		refilled := float64(now.Sub(b.updated)) / float64(limit.refillInterval())
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+refilled)
+		b.updated = now
+		buckets[i] = b
+
+		if b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) * float64(limit.refillInterval()))
+			if result.Allowed || wait > result.RetryAfter {
+				result = RateLimitResult{Denied: i, RetryAfter: wait}
+			}
+		}
+	}
+
+	if result.Allowed {
+		for _, b := range buckets {
+			b.tokens--
+		}
+	}
+	return result, nil
+}
+
+// sweep drops buckets that have not been touched for a full period and are therefore full again
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenBucketScript atomically refills the buckets stored as hashes at KEYS and
+// takes a token from each of them, or from none when any is empty.
+// It returns {allowed, index of the empty bucket refilling last, milliseconds until it has a token}.
+const tokenBucketScript = `
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = {}
+local allowed = 1
+local denied = 0
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local state = redis.call('HMGET', key, 'tokens', 'updated')
+	local current = tonumber(state[1]) or burst
+	local updated = tonumber(state[2]) or now
+	current = math.min(burst, current + (now - updated) / interval)
+	tokens[i] = current
+	if current < 1 then
+		local needed = math.ceil((1 - current) * interval)
+		if allowed == 1 or needed > wait then
+			denied = i - 1
+			wait = needed
+		end
+		allowed = 0
+	end
+end
+for i, key in ipairs(KEYS) do
+	local current = tokens[i]
+	if allowed == 1 then
+		current = current - 1
+	end
+	redis.call('HSET', key, 'tokens', tostring(current), 'updated', tostring(now))
+	redis.call('PEXPIRE', key, math.ceil(burst * interval))
+end
+return {allowed, denied, wait}
+`
+
+// redisPoolSize is how many idle connections are kept open
+const redisPoolSize = 8
+
+// redisTimeout limits connecting to the server and each command
+const redisTimeout = 5 * time.Second
+
+// RedisRateLimitStore keeps token buckets in a Redis compatible server
+// (Redis, Valkey, KeyDB, Dragonfly) so limits are shared between replicas.
+// The script is sent once per server and then run by its hash.
+type RedisRateLimitStore struct {
+	address  string
+	password string
+	database int
+	prefix   string
+
+	scriptSHA string
+	idle      chan *redisConn
+}
+
+// redisConn is a connection to the server with its reply reader
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisRateLimitStore creates a store for a redis://[:password@]host:port[/db] URL
+func NewRedisRateLimitStore(rawURL string) (*RedisRateLimitStore, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "redis" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid redis URL %q", rawURL)
+	}
+
+	sha := sha1.Sum([]byte(tokenBucketScript))
+	store := &RedisRateLimitStore{
+		address:   parsed.Host,
+		prefix:    "nwc_ratelimit:",
+		scriptSHA: hex.EncodeToString(sha[:]),
+		idle:      make(chan *redisConn, redisPoolSize),
+	}
+	if password, ok := parsed.User.Password(); ok {
+		store.password = password
+	}
+	if db := strings.TrimPrefix(parsed.Path, "/"); db != "" {
+		store.database, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	return store, nil
+}
+
+// Take implements RateLimitStore
+func (s *RedisRateLimitStore) Take(keys []string, limit RateLimit) (RateLimitResult, error) {
+	interval := float64(limit.refillInterval()) / float64(time.Millisecond)
+	args := []string{strconv.Itoa(len(keys))}
+	for _, key := range keys {
+		args = append(args, s.prefix+key)
+	}
+	args = append(args,
+		strconv.Itoa(limit.Burst),
+		strconv.FormatFloat(interval, 'f', -1, 64),
+		strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	reply, err := s.do(append([]string{"EVALSHA", s.scriptSHA}, args...)...)
+	var redisErr redisError
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "NOSCRIPT") {
+		// EVAL also caches the script, so later calls find it by its hash
+		reply, err = s.do(append([]string{"EVAL", tokenBucketScript}, args...)...)
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	denied, _ := values[1].(int64)
+	wait, _ := values[2].(int64)
+	if denied < 0 || int(denied) >= len(keys) {
+		return RateLimitResult{}, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+
+	return RateLimitResult{Allowed: allowed == 1, Denied: int(denied), RetryAfter: time.Duration(wait) * time.Millisecond}, nil
+}
+
+// do sends a command on a pooled connection, retrying once on a fresh
+// connection if a pooled one has gone away
+func (s *RedisRateLimitStore) do(args ...string) (any, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := s.get()
+		if err != nil {
+			return nil, err
+		}
+
+		reply, err := conn.roundTrip(args)
+		var redisErr redisError
+		if err == nil || errors.As(err, &redisErr) {
+			s.put(conn)
+			return reply, err
+		}
+
+		conn.conn.Close()
+		if attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// get takes an idle connection from the pool or opens a new one
+func (s *RedisRateLimitStore) get() (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+		return s.dial()
+	}
+}
+
+// put returns a connection to the pool, closing it when the pool is full
+func (s *RedisRateLimitStore) put(conn *redisConn) {
+	select {
+	case s.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+// dial connects to the server, authenticates and selects the database
+func (s *RedisRateLimitStore) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", s.address, redisTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if s.password != "" {
+		if _, err := conn.roundTrip([]string{"AUTH", s.password}); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if s.database != 0 {
+		if _, err := conn.roundTrip([]string{"SELECT", strconv.Itoa(s.database)}); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// roundTrip writes a RESP command and reads its reply
+func (c *redisConn) roundTrip(args []string) (any, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(command.String())); err != nil {
+		return nil, err
+	}
+
+	return readRESP(c.reader)
+}
+
+// redisError is an error reply returned by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRESP reads a single RESP2 value
+func readRESP(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]any, count)
+		for i := range values {
+			if values[i], err = readRESP(reader); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
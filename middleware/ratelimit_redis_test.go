@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers EVALSHA with NOSCRIPT until the script has been sent with EVAL
+// and records the commands it receives
+type fakeRedis struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	loaded   bool
+	commands []string
+	conns    int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns++
+			server.mu.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+		args, _ := request.([]any)
+		command, _ := args[0].(string)
+
+		f.mu.Lock()
+		f.commands = append(f.commands, command)
+		reply := "*3\r\n:1\r\n:0\r\n:0\r\n"
+		switch {
+		case command == "EVALSHA" && !f.loaded:
+			reply = "-NOSCRIPT No matching script\r\n"
+		case command == "EVAL":
+			f.loaded = true
+		}
+		f.mu.Unlock()
+
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisRateLimitStoreLoadsScriptOnce(t *testing.T) {
+	server := newFakeRedis(t)
+	store, err := NewRedisRateLimitStore("redis://" + server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := RateLimit{Burst: 10, Period: time.Minute}
+	for i := 0; i < 3; i++ {
+		result, err := store.Take([]string{"a", "b"}, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Fatalf("result = %+v, want allowed", result)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if got := strings.Join(server.commands, ","); got != "EVALSHA,EVAL,EVALSHA,EVALSHA" {
+		t.Fatalf("commands = %s, want the script sent once and then run by hash", got)
+	}
+	if server.conns != 1 {
+		t.Fatalf("opened %d connections, want the pooled one reused", server.conns)
+	}
+}
+
+func TestRedisRateLimitStoreConcurrent(t *testing.T) {
+	server := newFakeRedis(t)
+	store, _ := NewRedisRateLimitStore("redis://" + server.listener.Addr().String())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Take([]string{"a"}, RateLimit{Burst: 10, Period: time.Minute}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(store.idle) > redisPoolSize {
+		t.Fatalf("kept %d idle connections, want at most %d", len(store.idle), redisPoolSize)
+	}
+}
+
+func TestNewRedisRateLimitStore(t *testing.T) {
+	tests := []struct {
+		url      string
+		password string
+		database int
+		wantErr  bool
+	}{
+		{url: "redis://localhost:6379"},
+		{url: "redis://:secret@localhost:6379/2", password: "secret", database: 2},
+		{url: "http://localhost:6379", wantErr: true},
+		{url: "redis://", wantErr: true},
+		{url: "redis://localhost:6379/x", wantErr: true},
+	}
+
+	for _, test := range tests {
+		store, err := NewRedisRateLimitStore(test.url)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewRedisRateLimitStore(%q) error = %v, want error %t", test.url, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && (store.password != test.password || store.database != test.database) {
+			t.Errorf("NewRedisRateLimitStore(%q) = password %q database %d", test.url, store.password, store.database)
+		}
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    string
+		wantErr bool
+	}{
+		{reply: "+OK\r\n", want: "OK"},
+		{reply: ":42\r\n", want: "42"},
+		{reply: "$5\r\nhello\r\n", want: "hello"},
+		{reply: "*2\r\n:1\r\n$1\r\nx\r\n", want: "[1 x]"},
+		{reply: "-ERR wrong\r\n", wantErr: true},
+		{reply: "?\r\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		value, err := readRESP(bufio.NewReader(strings.NewReader(test.reply)))
+		if (err != nil) != test.wantErr {
+			t.Errorf("readRESP(%q) error = %v, want error %t", test.reply, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && fmt.Sprint(value) != test.want {
+			t.Errorf("readRESP(%q) = %s, want %s", test.reply, fmt.Sprint(value), test.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{value: "10/m", want: RateLimit{Burst: 10, Period: time.Minute}},
+		{value: " 5/s ", want: RateLimit{Burst: 5, Period: time.Second}},
+		{value: "100/d", want: RateLimit{Burst: 100, Period: 24 * time.Hour}},
+		{value: "10", wantErr: true},
+		{value: "0/m", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "x/m", wantErr: true},
+		{value: "10/w", wantErr: true},
+	}
+
+	for _, test := range tests {
+		limit, err := ParseRateLimit(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseRateLimit(%q) error = %v, want error %t", test.value, err, test.wantErr)
+			continue
+		}
+		if limit != test.want {
+			t.Errorf("ParseRateLimit(%q) = %+v, want %+v", test.value, limit, test.want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreBurstAndRefill(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 3, Period: 300 * time.Millisecond}
+
+	for i := 0; i < limit.Burst; i++ {
+		if result, _ := store.Take([]string{"a"}, limit); !result.Allowed {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+
+	result, _ := store.Take([]string{"a"}, limit)
+	if result.Allowed {
+		t.Fatal("request over the burst allowed")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > limit.refillInterval() {
+		t.Fatalf("RetryAfter = %s, want up to %s", result.RetryAfter, limit.refillInterval())
+	}
+
+	if result, _ := store.Take([]string{"b"}, limit); !result.Allowed {
+		t.Fatal("another bucket was limited")
+	}
+
+	time.Sleep(limit.refillInterval() + 10*time.Millisecond)
+	if result, _ := store.Take([]string{"a"}, limit); !result.Allowed {
+		t.Fatal("request refused after a token was refilled")
+	}
+}
+
+func TestMemoryRateLimitStoreAllOrNothing(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 2, Period: time.Hour}
+
+	store.Take([]string{"ip"}, limit)
+	store.Take([]string{"ip"}, limit)
+
+	// The principal bucket has tokens but the IP bucket is empty
+	for i := 0; i < 5; i++ {
+		result, _ := store.Take([]string{"principal", "ip"}, limit)
+		if result.Allowed || result.Denied != 1 {
+			t.Fatalf("result = %+v, want denied by bucket 1", result)
+		}
+	}
+
+	for i := 0; i < limit.Burst; i++ {
+		if result, _ := store.Take([]string{"principal"}, limit); !result.Allowed {
+			t.Fatalf("rejected requests used up the principal bucket after %d requests", i)
+		}
+	}
+}
+
+// rateLimitedRouter serves two routes of the same group on the store
+func rateLimitedRouter(store RateLimitStore, limit RateLimit) *gin.Engine {
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	handler := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/a", RateLimitMiddleware(store, limit, ByClientIP), handler)
+	router.GET("/b", RateLimitMiddleware(store, limit, ByClientIP), handler)
+	return router
+}
+
+func get(router http.Handler, path, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", path, nil)
+	request.RemoteAddr = remoteAddr
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := rateLimitedRouter(NewMemoryRateLimitStore(), RateLimit{Burst: 1, Period: time.Hour})
+
+	if recorder := get(router, "/a", "192.0.2.1:1000", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", recorder.Code)
+	}
+
+	recorder := get(router, "/a", "192.0.2.1:1001", nil)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want 429", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Fatal("429 without Retry-After")
+	}
+
+	if recorder := get(router, "/b", "192.0.2.1:1002", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("other route: status = %d, want 200 from its own bucket", recorder.Code)
+	}
+	if recorder := get(router, "/a", "192.0.2.2:1000", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("other client: status = %d, want 200", recorder.Code)
+	}
+}
+
+func TestRateLimitMiddlewareIgnoresForwardedIP(t *testing.T) {
+	router := rateLimitedRouter(NewMemoryRateLimitStore(), RateLimit{Burst: 1, Period: time.Hour})
+
+	get(router, "/a", "192.0.2.1:1000", map[string]string{"X-Forwarded-For": "198.51.100.1"})
+	recorder := get(router, "/a", "192.0.2.1:1000", map[string]string{"X-Forwarded-For": "198.51.100.2", "X-Real-IP": "198.51.100.3"})
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 whatever X-Forwarded-For says", recorder.Code)
+	}
+}
+
+func TestByBodyField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/pay", RateLimitMiddleware(NewMemoryRateLimitStore(), RateLimit{Burst: 1, Period: time.Hour}, ByBodyField("sender")), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%d", len(body))
+	})
+
+	post := func(contentType, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	jsonBody := `{"sender": "wallet_a"}`
+	if recorder := post("application/json", "/pay", jsonBody); recorder.Code != http.StatusOK || recorder.Body.String() != strconv.Itoa(len(jsonBody)) {
+		t.Fatalf("JSON: status = %d, body = %s, want the whole body passed on", recorder.Code, recorder.Body)
+	}
+	if recorder := post("application/json", "/pay", `{"sender": "WALLET_A"}`); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("JSON again: status = %d, want 429", recorder.Code)
+	}
+
+	// CSV and form uploads give the sender as a parameter
+	if recorder := post("text/csv", "/pay?sender=WALLET_A", "recipient,euro_amount\n"); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("CSV: status = %d, want 429 from the sender's bucket", recorder.Code)
+	}
+	if recorder := post("application/x-www-form-urlencoded", "/pay", "sender=wallet_a"); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("form: status = %d, want 429 from the sender's bucket", recorder.Code)
+	}
+	if recorder := post("text/csv", "/pay?sender=WALLET_B", "recipient,euro_amount\n"); recorder.Code != http.StatusOK {
+		t.Fatalf("CSV of another sender: status = %d, want 200", recorder.Code)
+	}
+
+	large := `{"sender": "WALLET_C", "notes": "` + strings.Repeat("x", maxSignedBodySize) + `"}`
+	if recorder := post("application/json", "/pay", large); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large JSON: status = %d, want 413", recorder.Code)
+	}
+}
@@ -0,0 +1,129 @@
+// Package monitor checks the configured wallets on an interval and publishes an
+// event when one stops answering or its balance runs low
+package monitor
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"nwc_app/events"
+)
+
+// BalanceFunc reads the balance of a wallet in msats
+type BalanceFunc func(ctx context.Context, walletID string) (int64, error)
+
+// Status is the outcome of checking a wallet and the data of the events the monitor publishes
+type Status struct {
+	Wallet       string `json:"wallet"`
+	Healthy      bool   `json:"healthy"`
+	BalanceMsats int64  `json:"balance_msats,omitempty"`
+	// LowBalanceMsats is the configured threshold, if any
+	LowBalanceMsats int64     `json:"low_balance_msats,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// state is what the monitor remembers of a wallet between checks
+type state struct {
+	unhealthy bool
+	low       bool
+}
+
+// Monitor checks wallets and publishes wallet.unhealthy, wallet.healthy and
+// balance.low events when their state changes, so a lasting problem is reported once
+type Monitor struct {
+	Wallets []string
+	// LowBalance maps wallet IDs to the balance below which they are reported
+	LowBalance map[string]int64
+	Balance    BalanceFunc
+	Events     *events.Bus
+	// Interval between checks; zero disables the monitor
+	Interval time.Duration
+
+	mu     sync.Mutex
+	states map[string]state
+}
+
+// Run checks every wallet right away and then every Interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	if m.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll checks every wallet, ordered by wallet ID
+func (m *Monitor) CheckAll(ctx context.Context) []Status {
+	wallets := append([]string(nil), m.Wallets...)
+	sort.Strings(wallets)
+
+	statuses := make([]Status, 0, len(wallets))
+	for _, walletID := range wallets {
+		if ctx.Err() != nil {
+			break
+		}
+		statuses = append(statuses, m.Check(ctx, walletID))
+	}
+	return statuses
+}
+
+// Check reads the balance of a wallet and publishes the events its new state calls for.
+// A wallet is assumed healthy and funded before its first check.
+func (m *Monitor) Check(ctx context.Context, walletID string) Status {
+	status := Status{
+		Wallet:          walletID,
+		LowBalanceMsats: m.LowBalance[walletID],
+		CheckedAt:       time.Now().UTC(),
+	}
+	balance, err := m.Balance(ctx, walletID)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Healthy = true
+		status.BalanceMsats = balance
+	}
+
+	m.mu.Lock()
+	if m.states == nil {
+		m.states = make(map[string]state)
+	}
+	previous := m.states[walletID]
+	current := previous
+	current.unhealthy = !status.Healthy
+	// An unreachable wallet keeps its last known balance state
+	if status.Healthy {
+		current.low = status.LowBalanceMsats > 0 && status.BalanceMsats < status.LowBalanceMsats
+	}
+	m.states[walletID] = current
+	m.mu.Unlock()
+
+	switch {
+	case current.unhealthy && !previous.unhealthy:
+		log.Printf("Wallet %s is unhealthy: %s", walletID, status.Error)
+		m.Events.Publish(events.TypeWalletUnhealthy, walletID, status)
+	case !current.unhealthy && previous.unhealthy:
+		log.Printf("Wallet %s is healthy again", walletID)
+		m.Events.Publish(events.TypeWalletHealthy, walletID, status)
+	}
+	if current.low && !previous.low {
+		log.Printf("Wallet %s balance of %d msat is below %d msat", walletID, status.BalanceMsats, status.LowBalanceMsats)
+		m.Events.Publish(events.TypeBalanceLow, walletID, status)
+	}
+
+	return status
+}
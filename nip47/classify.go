@@ -0,0 +1,55 @@
+package nip47
+
+import (
+	"context"
+	"errors"
+)
+
+// Error classes group failures by how callers should react to them
+const (
+	ClassRateLimited         = "rate_limited"
+	ClassTimeout             = "timeout"
+	ClassInsufficientBalance = "insufficient_balance"
+	ClassPaymentFailed       = "payment_failed"
+	ClassUnauthorized        = "unauthorized"
+	ClassNotFound            = "not_found"
+	ClassOther               = "other"
+)
+
+// Classify returns the class of an error from a wallet request.
+// No reply in time and relay failures are both timeouts: the request may or may not
+// have reached the wallet.
+func Classify(err error) string {
+	var walletErr *Error
+	var relayErr *RelayError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &walletErr):
+		switch walletErr.Code {
+		case CodeRateLimited:
+			return ClassRateLimited
+		case CodeInsufficientBalance, CodeQuotaExceeded:
+			return ClassInsufficientBalance
+		case CodePaymentFailed:
+			return ClassPaymentFailed
+		case CodeUnauthorized, CodeRestricted:
+			return ClassUnauthorized
+		case CodeNotFound:
+			return ClassNotFound
+		}
+		return ClassOther
+	case errors.Is(err, ErrNoResponse), errors.Is(err, context.DeadlineExceeded), errors.As(err, &relayErr):
+		return ClassTimeout
+	}
+	return ClassOther
+}
+
+// Retriable reports whether a request that failed with err may succeed when sent again
+func Retriable(err error) bool {
+	switch Classify(err) {
+	case ClassRateLimited, ClassTimeout, ClassPaymentFailed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+// Package nip47 implements the Nostr Wallet Connect requests the go-nwc client lacks,
+// and decodes the error replies go-nwc ignores
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/untreu2/go-nwc"
+)
+
+// Event kinds used by NIP-47
+const (
+	KindRequest  = 23194
+	KindResponse = 23195
+)
+
+// RequestTimeout bounds how long a request waits for the wallet's reply
+const RequestTimeout = 30 * time.Second
+
+// ErrNoResponse is returned when the wallet did not reply in time
+var ErrNoResponse = errors.New("no response from wallet")
+
+// RelayError is returned when the relay carrying requests to the wallet cannot be used
+type RelayError struct {
+	Op  string
+	Err error
+}
+
+func (e *RelayError) Error() string {
+	return fmt.Sprintf("failed to %s: %v", e.Op, e.Err)
+}
+
+func (e *RelayError) Unwrap() error {
+	return e.Err
+}
+
+// Error is an error reply from the wallet service
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Error codes defined by NIP-47
+const (
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeNotImplemented      = "NOT_IMPLEMENTED"
+	CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+	CodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	CodeRestricted          = "RESTRICTED"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeInternal            = "INTERNAL"
+	CodeNotFound            = "NOT_FOUND"
+	CodePaymentFailed       = "PAYMENT_FAILED"
+	CodeOther               = "OTHER"
+)
+
+// IsCode reports whether err is an error reply from the wallet with the given code
+func IsCode(err error, code string) bool {
+	var walletErr *Error
+	return errors.As(err, &walletErr) && walletErr.Code == code
+}
+
+// Client talks to a wallet service. It embeds the go-nwc client so its
+// methods remain available.
+type Client struct {
+	*nwc.Client
+}
+
+// NewClient creates a client from a nostr+walletconnect URI
+func NewClient(uri string) (*Client, error) {
+	client, err := nwc.NewClient(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: client}, nil
+}
+
+// response is the decrypted content of a kind 23195 event
+type response struct {
+	ResultType string          `json:"result_type"`
+	Error      *Error          `json:"error"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// Call sends a request and decodes the result into result.
+// An error reply from the wallet is returned as *Error.
+func (c *Client) Call(ctx context.Context, method string, params map[string]any, result any) error {
+	var replyErr error
+	err := c.exchange(ctx, method, params, RequestTimeout, func(event *nostr.Event, reply *response) bool {
+		replyErr = reply.decode(result)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return replyErr
+}
+
+// exchange sends a request and passes every reply to handle until it returns true.
+// Most methods have a single reply; multi_pay_invoice has one per invoice.
+// Events from other authors or that do not decrypt are skipped, so they cannot cut the wait short.
+func (c *Client) exchange(ctx context.Context, method string, params map[string]any, timeout time.Duration, handle func(event *nostr.Event, reply *response) bool) error {
+	sharedSecret, err := nip04.ComputeSharedSecret(c.WalletPubKey, c.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	request, err := c.request(method, params, sharedSecret)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(ctx, c.RelayURL)
+	if err != nil {
+		return &RelayError{Op: "connect to relay", Err: err}
+	}
+	defer relay.Close()
+
+	// Subscribe before publishing so a fast reply cannot be missed
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{
+		Kinds:   []int{KindResponse},
+		Authors: []string{c.WalletPubKey},
+		Tags:    nostr.TagMap{"e": []string{request.ID}},
+	}})
+	if err != nil {
+		return &RelayError{Op: "subscribe to replies", Err: err}
+	}
+	defer sub.Unsub()
+
+	if err := relay.Publish(ctx, request); err != nil {
+		return &RelayError{Op: "publish request", Err: err}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s", ErrNoResponse, method)
+		case event, ok := <-sub.Events:
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrNoResponse, method)
+			}
+			if event.PubKey != c.WalletPubKey {
+				continue
+			}
+			reply, err := decryptResponse(event, sharedSecret)
+			if err != nil {
+				continue
+			}
+			if handle(event, reply) {
+				return nil
+			}
+		}
+	}
+}
+
+// request builds and signs an encrypted request event
+func (c *Client) request(method string, params map[string]any, sharedSecret []byte) (nostr.Event, error) {
+	if params == nil {
+		params = map[string]any{}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	content, err := nip04.Encrypt(string(payload), sharedSecret)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	event := nostr.Event{
+		PubKey:    c.ClientPubKey,
+		CreatedAt: nostr.Now(),
+		Kind:      KindRequest,
+		Tags:      nostr.Tags{{"p", c.WalletPubKey}},
+		Content:   content,
+	}
+	if err := event.Sign(c.ClientSecret); err != nil {
+		return nostr.Event{}, err
+	}
+
+	return event, nil
+}
+
+// decryptResponse decrypts a reply event
+func decryptResponse(event *nostr.Event, sharedSecret []byte) (*response, error) {
+	decrypted, err := nip04.Decrypt(event.Content, sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt reply: %w", err)
+	}
+
+	var reply response
+	if err := json.Unmarshal([]byte(decrypted), &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse reply: %w", err)
+	}
+
+	return &reply, nil
+}
+
+// decode returns the wallet's error reply or decodes the result into result
+func (r *response) decode(result any) error {
+	if r.Error != nil && (r.Error.Code != "" || r.Error.Message != "") {
+		return r.Error
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
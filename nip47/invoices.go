@@ -0,0 +1,76 @@
+package nip47
+
+import (
+	"context"
+	"time"
+
+	"github.com/untreu2/go-nwc"
+)
+
+// Transaction states reported by wallets that support them
+const (
+	StatePending = "pending"
+	StateSettled = "settled"
+	StateFailed  = "failed"
+	StateExpired = "expired"
+)
+
+// Transaction types
+const (
+	TypeIncoming = "incoming"
+	TypeOutgoing = "outgoing"
+)
+
+// Transaction is an invoice or payment as reported by the wallet service.
+// State is only filled in by wallets following the newer revisions of NIP-47.
+type Transaction struct {
+	nwc.InvoiceDetails
+	State string `json:"state,omitempty"`
+}
+
+// Settled reports whether the invoice has been paid
+func (t *Transaction) Settled() bool {
+	return t.SettledAt > 0 || t.State == StateSettled
+}
+
+// InvoiceParams are the parameters of a make_invoice request
+type InvoiceParams struct {
+	AmountMsats     int64
+	Description     string
+	DescriptionHash string
+	Expiry          time.Duration
+}
+
+// MakeInvoiceWithParams creates an invoice, unlike go-nwc's MakeInvoice
+// accepting an expiry and description hash and returning the full details
+func (c *Client) MakeInvoiceWithParams(ctx context.Context, params InvoiceParams) (*Transaction, error) {
+	request := map[string]any{
+		"amount": params.AmountMsats,
+	}
+	if params.Description != "" {
+		request["description"] = params.Description
+	}
+	if params.DescriptionHash != "" {
+		request["description_hash"] = params.DescriptionHash
+	}
+	if params.Expiry > 0 {
+		request["expiry"] = int64(params.Expiry / time.Second)
+	}
+
+	var transaction Transaction
+	if err := c.Call(ctx, "make_invoice", request, &transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// LookupInvoice returns the wallet's record of the invoice with the given payment hash
+func (c *Client) LookupInvoice(ctx context.Context, paymentHash string) (*Transaction, error) {
+	var transaction Transaction
+	if err := c.Call(ctx, "lookup_invoice", map[string]any{"payment_hash": paymentHash}, &transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
@@ -0,0 +1,111 @@
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+// Notification event kinds, the same notification encrypted with NIP-04 or NIP-44
+const (
+	KindNotification      = 23196
+	KindNotificationNIP44 = 23197
+)
+
+// Notification types
+const (
+	NotificationPaymentReceived = "payment_received"
+	NotificationPaymentSent     = "payment_sent"
+)
+
+// Notification is a payment the wallet service announced
+type Notification struct {
+	Type        string      `json:"notification_type"`
+	Transaction Transaction `json:"notification"`
+	// CreatedAt is when the wallet published the notification
+	CreatedAt time.Time `json:"-"`
+}
+
+// Listen passes the notifications the wallet published since the given time to handle
+// until ctx is cancelled or the relay connection is lost, which is returned as *RelayError.
+// Events that cannot be decrypted are passed to skip, when set, and otherwise ignored.
+func (c *Client) Listen(ctx context.Context, since time.Time, handle func(Notification), skip func(error)) error {
+	sharedSecret, err := nip04.ComputeSharedSecret(c.WalletPubKey, c.ClientSecret)
+	if err != nil {
+		return err
+	}
+	conversationKey, err := nip44.GenerateConversationKey(c.WalletPubKey, c.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	relay, err := nostr.RelayConnect(ctx, c.RelayURL)
+	if err != nil {
+		return &RelayError{Op: "connect to relay", Err: err}
+	}
+	defer relay.Close()
+
+	sinceTimestamp := nostr.Timestamp(since.Unix())
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{
+		Kinds:   []int{KindNotification, KindNotificationNIP44},
+		Authors: []string{c.WalletPubKey},
+		Tags:    nostr.TagMap{"p": []string{c.ClientPubKey}},
+		Since:   &sinceTimestamp,
+	}})
+	if err != nil {
+		return &RelayError{Op: "subscribe to notifications", Err: err}
+	}
+	defer sub.Unsub()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-relay.Context().Done():
+			return &RelayError{Op: "listen for notifications", Err: context.Cause(relay.Context())}
+		case event, ok := <-sub.Events:
+			if !ok {
+				return &RelayError{Op: "listen for notifications", Err: errors.New("subscription closed")}
+			}
+			if event.PubKey != c.WalletPubKey {
+				continue
+			}
+
+			notification, err := decryptNotification(event, sharedSecret, conversationKey)
+			if err != nil {
+				if skip != nil {
+					skip(err)
+				}
+				continue
+			}
+			handle(*notification)
+		}
+	}
+}
+
+// decryptNotification decrypts a notification event with the scheme its kind uses
+func decryptNotification(event *nostr.Event, sharedSecret []byte, conversationKey [32]byte) (*Notification, error) {
+	var decrypted string
+	var err error
+	if event.Kind == KindNotificationNIP44 {
+		decrypted, err = nip44.Decrypt(event.Content, conversationKey)
+	} else {
+		decrypted, err = nip04.Decrypt(event.Content, sharedSecret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt notification %s: %w", event.ID, err)
+	}
+
+	var notification Notification
+	if err := json.Unmarshal([]byte(decrypted), &notification); err != nil {
+		return nil, fmt.Errorf("failed to parse notification %s: %w", event.ID, err)
+	}
+	notification.CreatedAt = event.CreatedAt.Time()
+	return &notification, nil
+}
@@ -0,0 +1,74 @@
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+)
+
+// notify publishes a NIP-04 notification of a payment from the wallet, created at the given time
+func (r *testRelay) notify(clientPubKey string, paymentHash string, createdAt time.Time) {
+	r.t.Helper()
+
+	payload, _ := json.Marshal(map[string]any{
+		"notification_type": NotificationPaymentReceived,
+		"notification":      map[string]any{"type": "incoming", "payment_hash": paymentHash, "amount": 1000},
+	})
+	sharedSecret, _ := nip04.ComputeSharedSecret(clientPubKey, r.walletSecret)
+	content, _ := nip04.Encrypt(string(payload), sharedSecret)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      KindNotification,
+		Tags:      nostr.Tags{{"p", clientPubKey}},
+		Content:   content,
+	}
+	if err := event.Sign(r.walletSecret); err != nil {
+		r.t.Fatal(err)
+	}
+	r.publish(event)
+}
+
+func TestListenSince(t *testing.T) {
+	relay := newTestRelay(t)
+	client := relay.client()
+
+	now := time.Now().Truncate(time.Second)
+	relay.notify(client.ClientPubKey, "old", now.Add(-time.Hour))
+	relay.notify(client.ClientPubKey, "missed", now.Add(-time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var received []Notification
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Listen(ctx, now.Add(-2*time.Minute), func(notification Notification) {
+			received = append(received, notification)
+			if len(received) == 2 {
+				cancel()
+			}
+		}, nil)
+	}()
+
+	// Published after subscribing, once the missed one has arrived
+	time.Sleep(100 * time.Millisecond)
+	relay.notify(client.ClientPubKey, "live", now)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen() did not pass on the notifications")
+	}
+
+	if len(received) != 2 || received[0].Transaction.PaymentHash != "missed" || received[1].Transaction.PaymentHash != "live" {
+		t.Fatalf("received %+v, want the missed and the live notification", received)
+	}
+	if !received[0].CreatedAt.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("CreatedAt = %s, want %s", received[0].CreatedAt, now.Add(-time.Minute))
+	}
+}
@@ -0,0 +1,117 @@
+package nip47
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Payment is the reply to a successful payment request
+type Payment struct {
+	Preimage string `json:"preimage"`
+	FeesPaid int64  `json:"fees_paid"`
+}
+
+// TLVRecord is a custom record sent along with a keysend payment.
+// Value is hex encoded.
+type TLVRecord struct {
+	Type  uint64 `json:"type"`
+	Value string `json:"value"`
+}
+
+// KeysendParams are the parameters of a pay_keysend request
+type KeysendParams struct {
+	Pubkey      string
+	AmountMsats int64
+	TLVRecords  []TLVRecord
+}
+
+// PayKeysendWithParams pays a node directly, unlike go-nwc's PayKeysend
+// sending TLV records and reporting the wallet's error replies
+func (c *Client) PayKeysendWithParams(ctx context.Context, params KeysendParams) (*Payment, error) {
+	request := map[string]any{
+		"pubkey": params.Pubkey,
+		"amount": params.AmountMsats,
+	}
+	if len(params.TLVRecords) > 0 {
+		request["tlv_records"] = params.TLVRecords
+	}
+
+	var payment Payment
+	if err := c.Call(ctx, "pay_keysend", request, &payment); err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// MultiPayItem is one invoice of a multi_pay_invoice request, identified by ID in the replies
+type MultiPayItem struct {
+	ID      string `json:"id"`
+	Invoice string `json:"invoice"`
+}
+
+// MultiPayResult is the outcome of one invoice of a multi_pay_invoice request
+type MultiPayResult struct {
+	Payment *Payment
+	Err     error
+}
+
+// MultiPayInvoice pays several invoices with a single request. The wallet replies
+// once per invoice; invoices without a reply before the timeout get ErrNoResponse.
+// The returned error is set when the exchange broke off early; the results still hold
+// the replies that arrived, and the invoices without one carry that error.
+func (c *Client) MultiPayInvoice(ctx context.Context, items []MultiPayItem) (map[string]MultiPayResult, error) {
+	results := make(map[string]MultiPayResult, len(items))
+	requested := make(map[string]bool, len(items))
+	for _, item := range items {
+		requested[item.ID] = true
+	}
+
+	// Wallets pay the invoices one after another, so allow time for each
+	timeout := RequestTimeout + time.Duration(len(items))*5*time.Second
+
+	err := c.exchange(ctx, "multi_pay_invoice", map[string]any{"invoices": items}, timeout, func(event *nostr.Event, reply *response) bool {
+		id := event.Tags.Find("d").Value()
+		if _, seen := results[id]; !requested[id] || seen {
+			return false
+		}
+
+		var payment Payment
+		if err := reply.decode(&payment); err != nil {
+			results[id] = MultiPayResult{Err: err}
+		} else {
+			results[id] = MultiPayResult{Payment: &payment}
+		}
+		return len(results) == len(items)
+	})
+
+	// Replies that arrived count even when others did not
+	for _, item := range items {
+		if _, ok := results[item.ID]; !ok {
+			missing := err
+			if errors.Is(err, ErrNoResponse) {
+				missing = fmt.Errorf("%w: multi_pay_invoice %s", ErrNoResponse, item.ID)
+			}
+			results[item.ID] = MultiPayResult{Err: missing}
+		}
+	}
+	if errors.Is(err, ErrNoResponse) {
+		err = nil
+	}
+
+	return results, err
+}
+
+// PayBolt11 pays an invoice. Unlike go-nwc's PayInvoice it returns the wallet's
+// error replies as *Error, so callers can tell why a payment failed.
+func (c *Client) PayBolt11(ctx context.Context, invoice string) (*Payment, error) {
+	var payment Payment
+	if err := c.Call(ctx, "pay_invoice", map[string]any{"invoice": invoice}, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
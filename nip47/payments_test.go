@@ -0,0 +1,81 @@
+package nip47
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestCallSkipsUnreadableReplies(t *testing.T) {
+	relay := newTestRelay(t)
+	relay.answer = func(request *nostr.Event, method string, params map[string]any) []nostr.Event {
+		return []nostr.Event{
+			relay.reply(request, "", "not encrypted", true),
+			relay.reply(request, "", map[string]any{"result_type": method, "result": map[string]any{"preimage": "ab"}}, false),
+		}
+	}
+
+	payment, err := relay.client().PayBolt11(context.Background(), "lnbc1")
+	if err != nil {
+		t.Fatalf("PayBolt11() error = %v", err)
+	}
+	if payment.Preimage != "ab" {
+		t.Fatalf("PayBolt11() preimage = %q, want ab", payment.Preimage)
+	}
+}
+
+func TestMultiPayInvoice(t *testing.T) {
+	relay := newTestRelay(t)
+	relay.answer = func(request *nostr.Event, method string, params map[string]any) []nostr.Event {
+		return []nostr.Event{
+			relay.reply(request, "paid", map[string]any{"result_type": method, "result": map[string]any{"preimage": "ab", "fees_paid": 3}}, false),
+			relay.reply(request, "refused", map[string]any{"result_type": method, "error": map[string]any{"code": CodeInsufficientBalance, "message": "no funds"}}, false),
+			// Neither may stand in for the reply of "silent"
+			relay.reply(request, "silent", "not encrypted", true),
+			relay.reply(request, "unknown", map[string]any{"result_type": method, "result": map[string]any{"preimage": "cd"}}, false),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	results, err := relay.client().MultiPayInvoice(ctx, []MultiPayItem{
+		{ID: "paid", Invoice: "lnbc1"},
+		{ID: "refused", Invoice: "lnbc2"},
+		{ID: "silent", Invoice: "lnbc3"},
+	})
+	if err != nil {
+		t.Fatalf("MultiPayInvoice() error = %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("MultiPayInvoice() returned %d results, want one per invoice", len(results))
+	}
+	if paid := results["paid"]; paid.Err != nil || paid.Payment.Preimage != "ab" || paid.Payment.FeesPaid != 3 {
+		t.Fatalf("paid = %+v, want the payment", paid)
+	}
+	if refused := results["refused"]; !IsCode(refused.Err, CodeInsufficientBalance) {
+		t.Fatalf("refused error = %v, want the wallet error", refused.Err)
+	}
+	if silent := results["silent"]; !errors.Is(silent.Err, ErrNoResponse) {
+		t.Fatalf("silent error = %v, want no response", silent.Err)
+	}
+}
+
+func TestMultiPayInvoiceRelayDown(t *testing.T) {
+	relay := newTestRelay(t)
+	client := relay.client()
+	relay.server.Close()
+
+	results, err := client.MultiPayInvoice(context.Background(), []MultiPayItem{{ID: "a", Invoice: "lnbc1"}})
+	var relayErr *RelayError
+	if !errors.As(err, &relayErr) {
+		t.Fatalf("MultiPayInvoice() error = %v, want a relay error", err)
+	}
+	if !errors.As(results["a"].Err, &relayErr) {
+		t.Fatalf("result error = %v, want the relay error", results["a"].Err)
+	}
+}
@@ -0,0 +1,201 @@
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+)
+
+// testRelay is an in-memory relay with a wallet answering the requests published to it
+type testRelay struct {
+	t      *testing.T
+	server *httptest.Server
+
+	walletSecret, walletPubKey string
+	clientSecret               string
+
+	// answer returns the events the wallet publishes in reply to a decrypted request
+	answer func(request *nostr.Event, method string, params map[string]any) []nostr.Event
+
+	mu     sync.Mutex
+	events []nostr.Event
+	subs   map[*websocket.Conn]map[string]nostr.Filters
+}
+
+func newTestRelay(t *testing.T) *testRelay {
+	t.Helper()
+
+	r := &testRelay{
+		t:            t,
+		walletSecret: nostr.GeneratePrivateKey(),
+		clientSecret: nostr.GeneratePrivateKey(),
+		subs:         make(map[*websocket.Conn]map[string]nostr.Filters),
+	}
+	r.walletPubKey, _ = nostr.GetPublicKey(r.walletSecret)
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// client returns a client connected to the wallet through the relay
+func (r *testRelay) client() *Client {
+	r.t.Helper()
+
+	relayURL := "ws" + strings.TrimPrefix(r.server.URL, "http")
+	client, err := NewClient(fmt.Sprintf("nostr+walletconnect://%s?relay=%s&secret=%s", r.walletPubKey, relayURL, r.clientSecret))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return client
+}
+
+func (r *testRelay) serve(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	r.mu.Lock()
+	r.subs[conn] = make(map[string]nostr.Filters)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.subs, conn)
+		r.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := conn.Read(context.Background())
+		if err != nil {
+			return
+		}
+		var message []json.RawMessage
+		if json.Unmarshal(data, &message) != nil || len(message) < 2 {
+			continue
+		}
+		var label, id string
+		json.Unmarshal(message[0], &label)
+
+		switch label {
+		case "EVENT":
+			var event nostr.Event
+			json.Unmarshal(message[1], &event)
+			r.write(conn, []any{"OK", event.ID, true, ""})
+			r.publish(event)
+			if event.Kind == KindRequest && r.answer != nil {
+				go r.answerRequest(&event)
+			}
+		case "REQ":
+			json.Unmarshal(message[1], &id)
+			var filters nostr.Filters
+			for _, raw := range message[2:] {
+				var filter nostr.Filter
+				json.Unmarshal(raw, &filter)
+				filters = append(filters, filter)
+			}
+
+			r.mu.Lock()
+			r.subs[conn][id] = filters
+			var stored []nostr.Event
+			for _, event := range r.events {
+				if filters.Match(&event) {
+					stored = append(stored, event)
+				}
+			}
+			r.mu.Unlock()
+
+			for _, event := range stored {
+				r.write(conn, []any{"EVENT", id, event})
+			}
+			r.write(conn, []any{"EOSE", id})
+		case "CLOSE":
+			json.Unmarshal(message[1], &id)
+			r.mu.Lock()
+			delete(r.subs[conn], id)
+			r.mu.Unlock()
+		}
+	}
+}
+
+// publish stores an event and sends it to the matching subscriptions
+func (r *testRelay) publish(event nostr.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	type target struct {
+		conn *websocket.Conn
+		id   string
+	}
+	var targets []target
+	for conn, subs := range r.subs {
+		for id, filters := range subs {
+			if filters.Match(&event) {
+				targets = append(targets, target{conn, id})
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	for _, target := range targets {
+		r.write(target.conn, []any{"EVENT", target.id, event})
+	}
+}
+
+func (r *testRelay) write(conn *websocket.Conn, message any) {
+	data, _ := json.Marshal(message)
+	conn.Write(context.Background(), websocket.MessageText, data)
+}
+
+func (r *testRelay) answerRequest(request *nostr.Event) {
+	sharedSecret, _ := nip04.ComputeSharedSecret(request.PubKey, r.walletSecret)
+	decrypted, err := nip04.Decrypt(request.Content, sharedSecret)
+	if err != nil {
+		r.t.Errorf("wallet could not decrypt request: %v", err)
+		return
+	}
+	var payload struct {
+		Method string         `json:"method"`
+		Params map[string]any `json:"params"`
+	}
+	json.Unmarshal([]byte(decrypted), &payload)
+
+	for _, event := range r.answer(request, payload.Method, payload.Params) {
+		r.publish(event)
+	}
+}
+
+// reply builds a signed wallet reply to request, tagged with d when it is set.
+// content is encrypted unless raw is set.
+func (r *testRelay) reply(request *nostr.Event, d string, content any, raw bool) nostr.Event {
+	r.t.Helper()
+
+	text, _ := content.(string)
+	if !raw {
+		payload, _ := json.Marshal(content)
+		sharedSecret, _ := nip04.ComputeSharedSecret(request.PubKey, r.walletSecret)
+		text, _ = nip04.Encrypt(string(payload), sharedSecret)
+	}
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      KindResponse,
+		Tags:      nostr.Tags{{"e", request.ID}, {"p", request.PubKey}},
+		Content:   text,
+	}
+	if d != "" {
+		event.Tags = append(event.Tags, nostr.Tag{"d", d})
+	}
+	if err := event.Sign(r.walletSecret); err != nil {
+		r.t.Fatal(err)
+	}
+	return event
+}
@@ -0,0 +1,120 @@
+// Package notifications listens for the payment notifications the configured
+// wallets publish, so payments are seen as they happen, including ones made
+// outside this service
+package notifications
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"nwc_app/nip47"
+	"nwc_app/retry"
+)
+
+// seenSize is how many notifications are remembered to drop the copies of a notification
+// a wallet publishes with both encryptions
+const seenSize = 1000
+
+// stableAfter is how long a connection must last for its loss to count as a fresh failure
+const stableAfter = time.Minute
+
+// resumeOverlap is how long before the last notification received a new subscription starts,
+// allowing for notifications published out of order or by a wallet with a slow clock
+const resumeOverlap = time.Minute
+
+// ListenFunc listens for the notifications a wallet published since the given time
+// until ctx is cancelled or the connection is lost
+type ListenFunc func(ctx context.Context, walletID string, since time.Time, handle func(nip47.Notification)) error
+
+// HandleFunc reacts to a notification of a wallet
+type HandleFunc func(walletID string, notification nip47.Notification)
+
+// Listener keeps a notification subscription open for every wallet, reconnecting
+// with backoff when a relay connection is lost
+type Listener struct {
+	Wallets []string
+	Listen  ListenFunc
+	Handle  HandleFunc
+	// Retry spaces out reconnection attempts; MaxAttempts is not used, a wallet is never given up on
+	Retry retry.Policy
+
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+}
+
+// Run listens for the notifications of every wallet until ctx is cancelled
+func (l *Listener) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, walletID := range l.Wallets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.listen(ctx, walletID)
+		}()
+	}
+	wg.Wait()
+}
+
+// listen keeps the subscription of a wallet open until ctx is cancelled.
+// A new subscription starts a little before the last notification received, or before
+// listening began, so the notifications published while reconnecting are not lost;
+// the ones handled already are dropped by first.
+func (l *Listener) listen(ctx context.Context, walletID string) {
+	failures := 0
+	resume := time.Now()
+	since := resume
+	for {
+		started := time.Now()
+		err := l.Listen(ctx, walletID, since, func(notification nip47.Notification) {
+			if notification.CreatedAt.After(resume) {
+				resume = notification.CreatedAt
+			}
+			if l.first(walletID, notification) {
+				l.Handle(walletID, notification)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		since = resume.Add(-resumeOverlap)
+
+		if time.Since(started) > stableAfter {
+			failures = 0
+		}
+		failures++
+		delay := l.Retry.Delay(failures)
+		log.Printf("Lost notifications of %s, reconnecting in %s: %v", walletID, delay.Round(time.Second), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// first reports whether the notification has not been handled before
+func (l *Listener) first(walletID string, notification nip47.Notification) bool {
+	key := walletID + "/" + notification.Type + "/" + notification.Transaction.PaymentHash
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	if l.seen[key] {
+		return false
+	}
+
+	l.seen[key] = true
+	l.order = append(l.order, key)
+	if len(l.order) > seenSize {
+		delete(l.seen, l.order[0])
+		l.order = l.order[1:]
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"nwc_app/nip47"
+
+	"github.com/untreu2/go-nwc"
+)
+
+func received(paymentHash string, createdAt time.Time) nip47.Notification {
+	return nip47.Notification{
+		Type:        nip47.NotificationPaymentReceived,
+		Transaction: nip47.Transaction{InvoiceDetails: nwc.InvoiceDetails{PaymentHash: paymentHash}},
+		CreatedAt:   createdAt,
+	}
+}
+
+func TestListenerResumesAfterLastNotification(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := time.Now()
+	last := started.Add(10 * time.Minute)
+
+	var mu sync.Mutex
+	var sinces []time.Time
+	var handled []string
+
+	l := &Listener{
+		Wallets: []string{"WALLET"},
+		Listen: func(ctx context.Context, walletID string, since time.Time, handle func(nip47.Notification)) error {
+			mu.Lock()
+			sinces = append(sinces, since)
+			attempt := len(sinces)
+			mu.Unlock()
+
+			switch attempt {
+			case 1:
+				handle(received("a", started.Add(time.Minute)))
+				handle(received("b", last))
+				return errors.New("connection lost")
+			case 2:
+				// Nothing arrives before the connection drops again
+				return errors.New("connection lost")
+			default:
+				// The relay sends the last notification again along with the one missed
+				handle(received("b", last))
+				handle(received("c", last.Add(time.Second)))
+				cancel()
+				return ctx.Err()
+			}
+		},
+		Handle: func(walletID string, notification nip47.Notification) {
+			mu.Lock()
+			handled = append(handled, notification.Transaction.PaymentHash)
+			mu.Unlock()
+		},
+	}
+	l.Run(ctx)
+
+	if len(sinces) != 3 {
+		t.Fatalf("Listen() called %d times, want 3", len(sinces))
+	}
+	if sinces[0].Before(started) || sinces[0].After(time.Now()) {
+		t.Fatalf("first subscription since %s, want when listening began", sinces[0])
+	}
+	for i, since := range sinces[1:] {
+		if want := last.Add(-resumeOverlap); !since.Equal(want) {
+			t.Fatalf("subscription %d since %s, want %s", i+2, since, want)
+		}
+	}
+	if got := len(handled); got != 3 || handled[0] != "a" || handled[1] != "b" || handled[2] != "c" {
+		t.Fatalf("handled %v, want [a b c]", handled)
+	}
+}
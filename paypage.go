@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"nwc_app/invoices"
+	"nwc_app/payrequests"
+	"nwc_app/qr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// payPageQRSize is the width in pixels of the QR code on a payment page
+const payPageQRSize = 320
+
+// PayPageStatus is what a payment page polls to learn that it was paid or needs a new quote
+type PayPageStatus struct {
+	Status         string     `json:"status" example:"open"`
+	PaymentHash    string     `json:"payment_hash,omitempty"`
+	QuoteExpiresAt *time.Time `json:"quote_expires_at,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
+
+// payPageData fills payPageTemplate
+type payPageData struct {
+	Request    payrequests.Request
+	Error      string
+	Sats       int64
+	QRCode     template.URL
+	PayLink    template.URL
+	ExpiresIn  int
+	StatusPath string
+}
+
+// payPageTemplate is the payment page. It shows the invoice of an open request
+// and reloads once the request is paid or its quote expires, without any script
+// from elsewhere.
+var payPageTemplate = template.Must(template.New("pay").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if .ExpiresIn}}<meta http-equiv="refresh" content="{{.ExpiresIn}}">{{end}}
+<title>{{if .Request.Description}}{{.Request.Description}}{{else}}Payment request{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 0; padding: 2rem 1rem; text-align: center; color: #222; background: #f6f6f6; }
+main { max-width: 24rem; margin: 0 auto; padding: 1.5rem; background: #fff; border-radius: 0.75rem; }
+h1 { font-size: 1.25rem; margin: 0 0 0.5rem; }
+.amount { font-size: 2rem; font-weight: bold; margin: 0.5rem 0 0; }
+.sats, .note { color: #666; margin: 0.25rem 0; }
+img { width: 100%; max-width: 320px; image-rendering: pixelated; }
+.invoice { word-break: break-all; font-family: monospace; font-size: 0.7rem; color: #555; }
+.button { display: inline-block; margin: 1rem 0; padding: 0.75rem 1.5rem; border-radius: 0.5rem; background: #f7931a; color: #fff; text-decoration: none; font-weight: bold; }
+.state { font-size: 1.5rem; font-weight: bold; }
+.paid { color: #2e7d32; }
+.closed { color: #b71c1c; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{if .Request.Description}}{{.Request.Description}}{{else}}Payment request{{end}}</h1>
+{{if .Error}}
+<p class="state closed">{{.Error}}</p>
+{{else}}
+<p class="amount">&euro; {{printf "%.2f" .Request.EuroAmount}}</p>
+{{if eq .Request.Status "paid"}}
+<p class="state paid">Paid &#10003;</p>
+<p class="note">Thank you, the payment was received.</p>
+{{else if eq .Request.Status "expired"}}
+<p class="state closed">This payment request has expired.</p>
+{{else}}
+<p class="sats">{{.Sats}} sats</p>
+<a href="{{.PayLink}}"><img src="{{.QRCode}}" alt="Lightning invoice QR code"></a>
+<p><a class="button" href="{{.PayLink}}">Open in wallet</a></p>
+<p class="note">Scan with a Lightning wallet. The amount in sats is valid for <span id="expires">{{.ExpiresIn}}</span> seconds and is then quoted again.</p>
+<p class="invoice">{{.Request.Invoice}}</p>
+<script>
+(function () {
+  var hash = {{.Request.PaymentHash}};
+  setInterval(function () {
+    fetch({{.StatusPath}}, {cache: "no-store"}).then(function (r) { return r.json(); }).then(function (s) {
+      if (s.status !== "open" || s.payment_hash !== hash) { location.reload(); }
+    }).catch(function () {});
+  }, 3000);
+  var left = {{.ExpiresIn}}, counter = document.getElementById("expires");
+  setInterval(function () { if (left > 0) { left--; counter.textContent = left; } }, 1000);
+})();
+</script>
+{{end}}
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// @Summary      Payment page
+// @Description  HTML page showing the invoice of a hosted payment request as a QR code. The invoice is quoted again at the current rate once it expires, and the page shows when the request has been paid.
+// @Tags         payment-requests
+// @Produce      html
+// @Param        id  path  string  true  "Payment request ID"
+// @Success      200  {string}  string  "Payment page"
+// @Failure      404  {string}  string  "Payment request not found"
+// @Failure      429  {object}  ErrorResponse
+// @Failure      502  {string}  string  "No invoice could be created"
+// @Router       /pay/{id} [get]
+func payPageHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, ok := paymentRequests.Get(id); !ok {
+		renderPayPage(c, http.StatusNotFound, payPageData{Error: "This payment request does not exist."})
+		return
+	}
+
+	request, err := refreshPaymentRequest(c.Request.Context(), id, paymentQuoteTTL)
+	if err != nil {
+		log.Printf("Failed to quote payment request %s: %v", id, err)
+		renderPayPage(c, toAPIError(err).Status, payPageData{
+			Request: request,
+			Error:   "No invoice can be created right now, please try again in a moment.",
+		})
+		return
+	}
+
+	data := payPageData{Request: request}
+	if request.Status == payrequests.StatusOpen {
+		png, err := qr.PNG(qr.LightningURI(request.Invoice), payPageQRSize)
+		if err != nil {
+			log.Printf("Failed to render QR code of payment request %s: %v", id, err)
+			renderPayPage(c, http.StatusInternalServerError, payPageData{Request: request, Error: "The QR code could not be shown."})
+			return
+		}
+
+		data.Sats = (request.AmountMsats + 500) / 1000
+		data.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+		data.PayLink = template.URL("lightning:" + request.Invoice)
+		data.ExpiresIn = max(int(time.Until(*request.QuoteExpiry).Seconds())+1, 1)
+		data.StatusPath = fmt.Sprintf("/pay/%s/status", request.ID)
+	}
+
+	renderPayPage(c, http.StatusOK, data)
+}
+
+// renderPayPage writes the payment page; it is never cached as its invoice changes
+func renderPayPage(c *gin.Context, status int, data payPageData) {
+	var page bytes.Buffer
+	if err := payPageTemplate.Execute(&page, data); err != nil {
+		log.Printf("Failed to render payment page: %v", err)
+		c.String(http.StatusInternalServerError, "failed to render payment page")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", page.Bytes())
+}
+
+// @Summary      Payment page status
+// @Description  Reports whether a hosted payment request is open, paid or expired and which invoice it currently shows, for its payment page to poll
+// @Tags         payment-requests
+// @Produce      json
+// @Param        id  path  string  true  "Payment request ID"
+// @Success      200  {object}  PayPageStatus
+// @Failure      404  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Router       /pay/{id}/status [get]
+func payPageStatusHandler(c *gin.Context) {
+	request, ok := paymentRequests.Get(c.Param("id"))
+	if !ok {
+		respondError(c, notFound("payment request '%s' not found", c.Param("id")))
+		return
+	}
+
+	// Notice a payment of the current invoice without waiting for the watcher
+	if request.Status == payrequests.StatusOpen {
+		if invoice, ok := invoiceStore.Get(request.PaymentHash); ok {
+			if invoice, err := invoiceWatcher.Check(c.Request.Context(), invoice); err == nil && invoice.Status == invoices.StatusPaid {
+				settlePaymentRequest(invoice)
+				request, _ = paymentRequests.Get(request.ID)
+			}
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, PayPageStatus{
+		Status:         request.Status,
+		PaymentHash:    request.PaymentHash,
+		QuoteExpiresAt: request.QuoteExpiry,
+		PaidAt:         request.PaidAt,
+	})
+}
@@ -0,0 +1,162 @@
+// Package payrequests keeps hosted payment requests: links asking for a Euro
+// amount into a configured wallet, paid through an invoice quoted at the current rate
+package payrequests
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Request statuses
+const (
+	StatusOpen    = "open"
+	StatusPaid    = "paid"
+	StatusExpired = "expired"
+)
+
+// ErrNotOpen is returned when a request that was paid or has expired is quoted again
+var ErrNotOpen = errors.New("payment request is not open")
+
+// Request asks for EuroAmount to be paid into Recipient.
+// Its invoice is a quote at the rate of the moment it was created, replaced by a
+// fresh one once it expires unpaid. PaymentHashes lists every invoice issued so a
+// payment of any of them marks the request paid.
+type Request struct {
+	ID          string     `json:"id"`
+	Recipient   string     `json:"recipient"`
+	EuroAmount  float64    `json:"euro_amount"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Invoice     string     `json:"invoice,omitempty"`
+	PaymentHash string     `json:"payment_hash,omitempty"`
+	AmountMsats int64      `json:"amount_msats,omitempty"`
+	QuotedAt    *time.Time `json:"quoted_at,omitempty"`
+	QuoteExpiry *time.Time `json:"quote_expires_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	PaidMsats   int64      `json:"paid_msats,omitempty"`
+	// PaymentHashes are the hashes of all invoices issued for the request
+	PaymentHashes []string  `json:"payment_hashes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Quote is an invoice issued for a request
+type Quote struct {
+	Invoice     string
+	PaymentHash string
+	AmountMsats int64
+	ExpiresAt   time.Time
+}
+
+// NeedsQuote reports whether an open request has no invoice that can still be paid at now
+func (r Request) NeedsQuote(now time.Time) bool {
+	return r.Status == StatusOpen && (r.QuoteExpiry == nil || !now.Before(*r.QuoteExpiry))
+}
+
+// Expired reports whether the request may no longer be paid at now
+func (r Request) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// Store is the persistent list of payment requests
+type Store struct {
+	requests *store.Collection[Request]
+}
+
+// Open loads the payment requests stored in dir
+func Open(dir string) (*Store, error) {
+	requests, err := store.Open[Request](dir, "payment_requests")
+	if err != nil {
+		return nil, err
+	}
+	return &Store{requests: requests}, nil
+}
+
+// Create stores a new open request without a quote
+func (s *Store) Create(request Request) (Request, error) {
+	request.ID = store.NewID()
+	request.Status = StatusOpen
+	request.CreatedAt = time.Now().UTC()
+	request.UpdatedAt = request.CreatedAt
+
+	return request, s.requests.Put(request.ID, request)
+}
+
+// Get returns the request with the given ID
+func (s *Store) Get(id string) (Request, bool) {
+	return s.requests.Get(id)
+}
+
+// List returns all requests, newest first
+func (s *Store) List() []Request {
+	requests := s.requests.List()
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.After(requests[j].CreatedAt)
+	})
+	return requests
+}
+
+// Requote replaces the invoice of an open request
+func (s *Store) Requote(id string, quote Quote) (Request, error) {
+	return s.requests.Update(id, func(request *Request) error {
+		if request.Status != StatusOpen {
+			return ErrNotOpen
+		}
+		now := time.Now().UTC()
+		expiresAt := quote.ExpiresAt.UTC()
+		request.Invoice = quote.Invoice
+		request.PaymentHash = quote.PaymentHash
+		request.AmountMsats = quote.AmountMsats
+		request.QuotedAt = &now
+		request.QuoteExpiry = &expiresAt
+		request.PaymentHashes = append(request.PaymentHashes, quote.PaymentHash)
+		request.UpdatedAt = now
+		return nil
+	})
+}
+
+// Expire marks an open request as expired
+func (s *Store) Expire(id string) (Request, error) {
+	return s.requests.Update(id, func(request *Request) error {
+		if request.Status != StatusOpen {
+			return ErrNotOpen
+		}
+		request.Status = StatusExpired
+		request.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Settle marks the open request that issued the invoice with paymentHash as paid,
+// which is usually its current invoice but may be one it replaced.
+// It reports false when no open request issued the invoice.
+func (s *Store) Settle(paymentHash string, amountMsats int64, paidAt time.Time) (Request, bool, error) {
+	for _, request := range s.requests.List() {
+		if request.Status != StatusOpen || !slices.Contains(request.PaymentHashes, paymentHash) {
+			continue
+		}
+
+		request, err := s.requests.Update(request.ID, func(request *Request) error {
+			if request.Status != StatusOpen {
+				return ErrNotOpen
+			}
+			paidAt = paidAt.UTC()
+			request.Status = StatusPaid
+			request.PaidMsats = amountMsats
+			request.PaidAt = &paidAt
+			request.UpdatedAt = time.Now().UTC()
+			return nil
+		})
+		if errors.Is(err, ErrNotOpen) {
+			return request, false, nil
+		}
+		return request, err == nil, err
+	}
+
+	return Request{}, false, nil
+}
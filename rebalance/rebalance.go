@@ -0,0 +1,234 @@
+// Package rebalance moves funds from wallets holding more than their configured
+// range to wallets holding less, and keeps the history of those runs
+package rebalance
+
+import (
+	"sort"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Run statuses
+const (
+	StatusRunning = "running"
+	// StatusPlanned is a dry run, which only works out the transfers
+	StatusPlanned = "planned"
+	// StatusBalanced is a run that found every wallet within its range
+	StatusBalanced        = "balanced"
+	StatusCompleted       = "completed"
+	StatusPartiallyFailed = "partially_failed"
+	StatusFailed          = "failed"
+)
+
+// Transfer statuses
+const (
+	TransferPlanned   = "planned"
+	TransferSucceeded = "succeeded"
+	TransferFailed    = "failed"
+)
+
+// WalletState is the balance of a wallet when a run started next to its range.
+// Error is set when the balance could not be read, which leaves the wallet out of the run.
+type WalletState struct {
+	Wallet       string `json:"wallet"`
+	BalanceMsats int64  `json:"balance_msats"`
+	MinMsats     int64  `json:"min_msats,omitempty"`
+	MaxMsats     int64  `json:"max_msats,omitempty"`
+	TargetMsats  int64  `json:"target_msats"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Transfer is one payment of a run from a wallet with a surplus to one short of funds.
+// LedgerID points to the ledger entry of the payment once it was made.
+type Transfer struct {
+	Sender      string `json:"sender"`
+	Recipient   string `json:"recipient"`
+	AmountMsats int64  `json:"amount_msats"`
+	Status      string `json:"status"`
+	LedgerID    string `json:"ledger_id,omitempty"`
+	FeesPaid    int64  `json:"fees_paid,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// ErrorCode is the API error code of Error
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Run is one pass of the rebalancer
+type Run struct {
+	ID         string        `json:"id,omitempty"`
+	Status     string        `json:"status"`
+	DryRun     bool          `json:"dry_run"`
+	Wallets    []WalletState `json:"wallets"`
+	Transfers  []Transfer    `json:"transfers"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+}
+
+// Limits cap what a single run moves. Zero values are not enforced.
+type Limits struct {
+	MaxTransfers int   `json:"max_transfers,omitempty"`
+	MaxRunMsats  int64 `json:"max_run_msats,omitempty"`
+	// MinTransferMsats skips transfers too small to be worth their fee
+	MinTransferMsats int64 `json:"min_transfer_msats,omitempty"`
+}
+
+// position is how far a wallet is from its target
+type position struct {
+	wallet string
+	msats  int64
+}
+
+// Plan works out the transfers that bring wallets back into their range.
+// Wallets above their maximum give down to their target to wallets below their
+// minimum, which are filled up to their target, largest gaps first.
+func Plan(wallets []WalletState, limits Limits) []Transfer {
+	var surplus, deficit []position
+	for _, state := range wallets {
+		switch {
+		case state.Error != "":
+		case state.MaxMsats > 0 && state.BalanceMsats > state.MaxMsats:
+			surplus = append(surplus, position{state.Wallet, state.BalanceMsats - state.TargetMsats})
+		case state.MinMsats > 0 && state.BalanceMsats < state.MinMsats:
+			deficit = append(deficit, position{state.Wallet, state.TargetMsats - state.BalanceMsats})
+		}
+	}
+	sortPositions(surplus)
+	sortPositions(deficit)
+
+	transfers := []Transfer{}
+	moved := int64(0)
+	for len(surplus) > 0 && len(deficit) > 0 {
+		if limits.MaxTransfers > 0 && len(transfers) >= limits.MaxTransfers {
+			break
+		}
+
+		amount := min(surplus[0].msats, deficit[0].msats)
+		if limits.MaxRunMsats > 0 {
+			left := limits.MaxRunMsats - moved
+			if left <= 0 || left < limits.MinTransferMsats {
+				break
+			}
+			amount = min(amount, left)
+		}
+		if amount < limits.MinTransferMsats {
+			// The smaller gap is not worth a transfer, the other may still pair up with the next wallet
+			if surplus[0].msats < deficit[0].msats {
+				surplus = surplus[1:]
+			} else {
+				deficit = deficit[1:]
+			}
+			continue
+		}
+
+		transfers = append(transfers, Transfer{
+			Sender:      surplus[0].wallet,
+			Recipient:   deficit[0].wallet,
+			AmountMsats: amount,
+			Status:      TransferPlanned,
+		})
+		moved += amount
+
+		surplus[0].msats -= amount
+		deficit[0].msats -= amount
+		if surplus[0].msats == 0 {
+			surplus = surplus[1:]
+		}
+		if deficit[0].msats == 0 {
+			deficit = deficit[1:]
+		}
+	}
+
+	return transfers
+}
+
+// sortPositions orders positions by their distance from target, largest first
+func sortPositions(positions []position) {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].msats != positions[j].msats {
+			return positions[i].msats > positions[j].msats
+		}
+		return positions[i].wallet < positions[j].wallet
+	})
+}
+
+// Summarize returns the status of a finished run from its transfers
+func Summarize(transfers []Transfer) string {
+	if len(transfers) == 0 {
+		return StatusBalanced
+	}
+
+	failed := 0
+	for _, transfer := range transfers {
+		if transfer.Status == TransferFailed {
+			failed++
+		}
+	}
+	switch failed {
+	case 0:
+		return StatusCompleted
+	case len(transfers):
+		return StatusFailed
+	default:
+		return StatusPartiallyFailed
+	}
+}
+
+// Store is the persistent history of runs that moved funds
+type Store struct {
+	runs *store.Collection[Run]
+}
+
+// Open loads the runs stored in dir.
+// Runs still going when the service stopped are marked failed, as their outcome is unknown.
+func Open(dir string) (*Store, error) {
+	runs, err := store.Open[Run](dir, "rebalances")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs.List() {
+		if run.Status != StatusRunning {
+			continue
+		}
+		if _, err := runs.Update(run.ID, func(run *Run) error {
+			run.Status = StatusFailed
+			for i := range run.Transfers {
+				if run.Transfers[i].Status == TransferPlanned {
+					run.Transfers[i].Status = TransferFailed
+					run.Transfers[i].Error = "interrupted by a restart, check the ledger before paying again"
+				}
+			}
+			run.FinishedAt = time.Now().UTC()
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Store{runs: runs}, nil
+}
+
+// Create stores a new run
+func (s *Store) Create(run Run) (Run, error) {
+	run.ID = store.NewID()
+	return run, s.runs.Put(run.ID, run)
+}
+
+// Save stores the progress of a run
+func (s *Store) Save(run Run) error {
+	return s.runs.Put(run.ID, run)
+}
+
+// Get returns the run with the given ID
+func (s *Store) Get(id string) (Run, bool) {
+	return s.runs.Get(id)
+}
+
+// List returns all runs, newest first
+func (s *Store) List() []Run {
+	runs := s.runs.List()
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return runs
+}
@@ -0,0 +1,141 @@
+package rebalance
+
+import (
+	"reflect"
+	"testing"
+)
+
+// over is a wallet holding more than its maximum
+func over(wallet string, balance, target int64) WalletState {
+	return WalletState{Wallet: wallet, BalanceMsats: balance, MaxMsats: target + 1000, TargetMsats: target}
+}
+
+// under is a wallet holding less than its minimum
+func under(wallet string, balance, target int64) WalletState {
+	return WalletState{Wallet: wallet, BalanceMsats: balance, MinMsats: target - 1000, TargetMsats: target}
+}
+
+func planned(sender, recipient string, amountMsats int64) Transfer {
+	return Transfer{Sender: sender, Recipient: recipient, AmountMsats: amountMsats, Status: TransferPlanned}
+}
+
+func TestPlan(t *testing.T) {
+	// Surpluses of 5000 (A) and 2000 (C), deficits of 4000 (B) and 3000 (D)
+	wallets := []WalletState{over("C", 12000, 10000), under("D", 7000, 10000), over("A", 15000, 10000), under("B", 6000, 10000)}
+	failing := func(state WalletState) WalletState {
+		state.Error = "wallet did not answer"
+		return state
+	}
+
+	tests := []struct {
+		name    string
+		wallets []WalletState
+		limits  Limits
+		want    []Transfer
+	}{
+		{
+			name:    "largest gaps first",
+			wallets: wallets,
+			want:    []Transfer{planned("A", "B", 4000), planned("A", "D", 1000), planned("C", "D", 2000)},
+		},
+		{
+			name: "within range",
+			wallets: []WalletState{
+				{Wallet: "A", BalanceMsats: 5000, MinMsats: 1000, MaxMsats: 9000, TargetMsats: 5000},
+				// At the edges of the range is still within it
+				{Wallet: "B", BalanceMsats: 9000, MinMsats: 1000, MaxMsats: 9000, TargetMsats: 5000},
+				{Wallet: "C", BalanceMsats: 1000, MinMsats: 1000, MaxMsats: 9000, TargetMsats: 5000},
+			},
+			want: []Transfer{},
+		},
+		{
+			name: "no range",
+			wallets: []WalletState{
+				{Wallet: "A", BalanceMsats: 1 << 40, TargetMsats: 5000},
+				under("B", 0, 5000),
+			},
+			want: []Transfer{},
+		},
+		{
+			name:    "nobody to give",
+			wallets: []WalletState{under("A", 0, 5000), under("B", 0, 5000)},
+			want:    []Transfer{},
+		},
+		{
+			name:    "max transfers",
+			wallets: wallets,
+			limits:  Limits{MaxTransfers: 2},
+			want:    []Transfer{planned("A", "B", 4000), planned("A", "D", 1000)},
+		},
+		{
+			name:    "max run msats",
+			wallets: wallets,
+			limits:  Limits{MaxRunMsats: 4500},
+			want:    []Transfer{planned("A", "B", 4000), planned("A", "D", 500)},
+		},
+		{
+			name:    "max run msats at a transfer",
+			wallets: wallets,
+			limits:  Limits{MaxRunMsats: 4000},
+			want:    []Transfer{planned("A", "B", 4000)},
+		},
+		{
+			// What is left of the run is too small to be worth a transfer
+			name:    "max run msats below the minimum transfer",
+			wallets: wallets,
+			limits:  Limits{MaxRunMsats: 4500, MinTransferMsats: 1000},
+			want:    []Transfer{planned("A", "B", 4000)},
+		},
+		{
+			name:    "both caps",
+			wallets: wallets,
+			limits:  Limits{MaxTransfers: 1, MaxRunMsats: 3000},
+			want:    []Transfer{planned("A", "B", 3000)},
+		},
+		{
+			// The 1000 A has left after B would only be a 1000 transfer to D
+			name:    "skips a surplus below the minimum transfer",
+			wallets: wallets,
+			limits:  Limits{MinTransferMsats: 1500},
+			want:    []Transfer{planned("A", "B", 4000), planned("C", "D", 2000)},
+		},
+		{
+			name:    "skips a deficit below the minimum transfer",
+			wallets: []WalletState{over("A", 15000, 10000), under("B", 7000, 10000), {Wallet: "D", BalanceMsats: 9000, MinMsats: 9500, TargetMsats: 10000}},
+			limits:  Limits{MinTransferMsats: 1500},
+			want:    []Transfer{planned("A", "B", 3000)},
+		},
+		{
+			name:    "every gap below the minimum transfer",
+			wallets: wallets,
+			limits:  Limits{MinTransferMsats: 10000},
+			want:    []Transfer{},
+		},
+		{
+			name:    "minimum transfer reached exactly",
+			wallets: wallets,
+			limits:  Limits{MinTransferMsats: 1000},
+			want:    []Transfer{planned("A", "B", 4000), planned("A", "D", 1000), planned("C", "D", 2000)},
+		},
+		{
+			// Wallets whose balance could not be read are left out, whatever they report
+			name:    "wallets with errors",
+			wallets: []WalletState{failing(over("A", 15000, 10000)), under("B", 6000, 10000), over("C", 12000, 10000), failing(under("D", 0, 10000))},
+			want:    []Transfer{planned("C", "B", 2000)},
+		},
+		{
+			name:    "only wallets with errors",
+			wallets: []WalletState{failing(over("A", 15000, 10000)), failing(under("B", 6000, 10000))},
+			want:    []Transfer{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Plan(test.wallets, test.limits)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Plan() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
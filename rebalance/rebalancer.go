@@ -0,0 +1,168 @@
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"nwc_app/wallet"
+)
+
+// ErrRunning is returned when a run is asked for while another one is going
+var ErrRunning = errors.New("a rebalance is already running")
+
+// Payment is what a transfer paid
+type Payment struct {
+	LedgerID string
+	FeesPaid int64
+}
+
+// BalanceFunc reads the balance of a wallet in msats
+type BalanceFunc func(ctx context.Context, walletID string) (int64, error)
+
+// PayFunc makes the payment of a transfer
+// It may return the payment along with an error when the payment was made but failed a check afterwards
+type PayFunc func(ctx context.Context, transfer Transfer) (*Payment, error)
+
+// Rebalancer keeps the wallets with a configured range within it
+type Rebalancer struct {
+	Store   *Store
+	Ranges  map[string]wallet.RebalanceRange
+	Balance BalanceFunc
+	Pay     PayFunc
+	// ErrorCode, when set, classifies the errors of failed transfers
+	ErrorCode func(err error) string
+	Limits    Limits
+	// Interval between runs; zero disables periodic runs
+	Interval time.Duration
+	// DryRun makes periodic runs only log the transfers they would make
+	DryRun bool
+
+	mu sync.Mutex
+}
+
+// Run rebalances every Interval until ctx is cancelled
+func (r *Rebalancer) Run(ctx context.Context) {
+	if r.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		run, err := r.RunOnce(ctx, r.DryRun)
+		if err != nil {
+			log.Printf("Rebalance skipped: %v", err)
+			continue
+		}
+		if run.DryRun {
+			for _, transfer := range run.Transfers {
+				log.Printf("Rebalance dry run: would move %d msat from %s to %s", transfer.AmountMsats, transfer.Sender, transfer.Recipient)
+			}
+		}
+	}
+}
+
+// RunOnce reads the balances of the wallets with a range, plans the transfers
+// that bring them back into it and, unless dryRun is set, makes them one after another.
+// Only runs that move funds are stored.
+func (r *Rebalancer) RunOnce(ctx context.Context, dryRun bool) (Run, error) {
+	if !r.mu.TryLock() {
+		return Run{}, ErrRunning
+	}
+	defer r.mu.Unlock()
+
+	run := Run{
+		Status:    StatusRunning,
+		DryRun:    dryRun,
+		Wallets:   r.walletStates(ctx),
+		StartedAt: time.Now().UTC(),
+	}
+	run.Transfers = Plan(run.Wallets, r.Limits)
+
+	if dryRun {
+		run.Status = StatusPlanned
+		run.FinishedAt = time.Now().UTC()
+		return run, nil
+	}
+	if len(run.Transfers) == 0 {
+		run.Status = StatusBalanced
+		run.FinishedAt = time.Now().UTC()
+		return run, nil
+	}
+
+	// Store the plan first, so a crash during a payment leaves a trace of it
+	run, err := r.Store.Create(run)
+	if err != nil {
+		return run, err
+	}
+
+	for i := range run.Transfers {
+		transfer := &run.Transfers[i]
+		payment, err := r.Pay(ctx, *transfer)
+		if err != nil {
+			log.Printf("Rebalance %s of %d msat from %s to %s failed: %v", run.ID, transfer.AmountMsats, transfer.Sender, transfer.Recipient, err)
+			transfer.Status = TransferFailed
+			transfer.Error = err.Error()
+			if r.ErrorCode != nil {
+				transfer.ErrorCode = r.ErrorCode(err)
+			}
+		} else {
+			log.Printf("Rebalance %s moved %d msat from %s to %s", run.ID, transfer.AmountMsats, transfer.Sender, transfer.Recipient)
+			transfer.Status = TransferSucceeded
+		}
+		// A payment can fail after it was made, e.g. when it paid more in fees than allowed
+		if payment != nil {
+			transfer.LedgerID = payment.LedgerID
+			transfer.FeesPaid = payment.FeesPaid
+		}
+
+		if err := r.Store.Save(run); err != nil {
+			log.Printf("Failed to store rebalance %s: %v", run.ID, err)
+		}
+	}
+
+	run.Status = Summarize(run.Transfers)
+	run.FinishedAt = time.Now().UTC()
+	return run, r.Store.Save(run)
+}
+
+// walletStates reads the balance of every wallet with a range, ordered by wallet ID
+func (r *Rebalancer) walletStates(ctx context.Context) []WalletState {
+	states := []WalletState{}
+	for walletID, walletRange := range r.Ranges {
+		if walletRange.IsZero() {
+			continue
+		}
+
+		state := WalletState{
+			Wallet:      walletID,
+			MinMsats:    walletRange.MinMsats,
+			MaxMsats:    walletRange.MaxMsats,
+			TargetMsats: walletRange.Target(),
+		}
+		balance, err := r.Balance(ctx, walletID)
+		if err != nil {
+			log.Printf("Leaving %s out of the rebalance: %v", walletID, err)
+			state.Error = err.Error()
+		} else {
+			state.BalanceMsats = balance
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Wallet < states[j].Wallet
+	})
+	return states
+}
@@ -0,0 +1,39 @@
+// Package retry spaces out attempts at operations that failed for transient reasons
+package retry
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Policy is how often and how quickly a failed operation is tried again
+type Policy struct {
+	// MaxAttempts includes the first attempt; 1 disables retries
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay returns how long to wait after the given failed attempt, counting from 1.
+// The delay doubles with every attempt up to MaxDelay and is jittered between half
+// and all of it, so clients that failed together do not retry together.
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// Retry reports whether another attempt is allowed after the given failed attempt
+func (p Policy) Retry(attempt int) bool {
+	return attempt < p.MaxAttempts
+}
@@ -0,0 +1,161 @@
+package schedules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five field cron expression: minute, hour, day of month, month and day of week
+type Cron struct {
+	minutes, hours, days, months, weekdays uint64
+	// Restricted day fields are combined with OR, as in Vixie cron
+	anyDay, anyWeekday bool
+}
+
+// cronMacros are the shorthands accepted in place of the five fields
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseCron parses a cron expression such as "0 9 1 * *" or "@monthly".
+// Fields accept *, lists, ranges, steps and month and weekday names; 7 is also Sunday.
+func ParseCron(expression string) (*Cron, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expression)
+	}
+
+	var cron Cron
+	var err error
+	if cron.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if cron.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if cron.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if cron.months, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if cron.weekdays, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+	// Vixie cron treats a field starting with *, such as */2, as unrestricted
+	cron.anyDay = strings.HasPrefix(fields[2], "*")
+	cron.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	return &cron, nil
+}
+
+// parseCronField returns the set of values a field matches as a bit mask
+// names, when given, are accepted for the values starting at min
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		valueRange, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		low, high := min, max
+		if valueRange != "*" {
+			lowText, highText, isRange := strings.Cut(valueRange, "-")
+			var err error
+			if low, err = parseCronValue(lowText, min, max, names); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = parseCronValue(highText, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q", valueRange)
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+
+	return set, nil
+}
+
+// parseCronValue parses a single number or name within min and max
+func parseCronValue(text string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(text, name) {
+			return min + i, nil
+		}
+	}
+
+	value, err := strconv.Atoi(text)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("value %q is not between %d and %d", text, min, max)
+	}
+	return value, nil
+}
+
+// Next returns the first time after t the expression matches, in t's location.
+// It returns the zero time when there is none within five years, e.g. for 30 February.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchesDay reports whether t falls on a day the day of month and day of week fields allow
+func (c *Cron) matchesDay(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,131 @@
+package schedules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "must have 5 fields"},
+		{"0 9 * *", "must have 5 fields"},
+		{"0 9 * * * *", "must have 5 fields"},
+		{"@fortnightly", "must have 5 fields"},
+		{"60 * * * *", "invalid minute field"},
+		{"-1 * * * *", "invalid minute field"},
+		{"* 24 * * *", "invalid hour field"},
+		{"* * 0 * *", "invalid day of month field"},
+		{"* * 32 * *", "invalid day of month field"},
+		{"* * * 13 *", "invalid month field"},
+		{"* * * foo *", "invalid month field"},
+		{"* * * * 8", "invalid day of week field"},
+		{"* * * * sunday", "invalid day of week field"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/-5 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"30-10 * * * *", "invalid range"},
+		{"* * * nov-feb *", "invalid range"},
+		{"1- * * * *", "invalid minute field"},
+		{"1,,2 * * * *", "invalid minute field"},
+		{"a * * * *", "invalid minute field"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := ParseCron(test.expression)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("ParseCron(%q) error = %v, want it to mention %q", test.expression, err, test.want)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, time.January, 15, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		from       time.Time
+		want       time.Time
+	}{
+		{"* * * * *", from, time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", from, time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0,20,40 * * * *", from, time.Date(2025, 1, 15, 10, 40, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", from, time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", from, time.Date(2025, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"0 9 1 * *", from, time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 9 31 * *", from, time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"0 9 31 * *", time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", from, time.Time{}},
+		{"0 12 * jun-aug *", from, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * JAN-DEC/6 *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 8 * * mon-fri", from, time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * sat,sun", from, time.Date(2025, 1, 18, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", from, time.Date(2025, 1, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 5-7", from, time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)},
+		// Restricted day of month and day of week match either, as in Vixie cron
+		{"0 8 20 * mon", from, time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC)},
+		{"0 8 17 * mon", from, time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)},
+		// A day field starting with * is combined with AND, as in Vixie cron
+		{"0 8 */2 * mon", from, time.Date(2025, 1, 27, 8, 0, 0, 0, time.UTC)},
+		{"0 8 13 * */3", from, time.Date(2025, 4, 13, 8, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", from, time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@Yearly", from, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"  0 0 1 1 *  ", from, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"59 23 31 12 *", time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			cron, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", test.expression, err)
+			}
+			if got := cron.Next(test.from); !got.Equal(test.want) {
+				t.Fatalf("Next(%s) = %s, want %s", test.from, got, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)
+	endsAt := time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     time.Time
+		wantErr  bool
+	}{
+		{"cron", Schedule{Cron: "0 * * * *"}, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC), false},
+		{"interval", Schedule{Interval: "90m"}, time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), false},
+		{"ends at the next run", Schedule{Interval: "90m", EndsAt: &endsAt}, time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), false},
+		{"ended", Schedule{Interval: "2h", EndsAt: &endsAt}, time.Time{}, false},
+		{"invalid cron", Schedule{Cron: "0 * *"}, time.Time{}, true},
+		{"invalid interval", Schedule{Interval: "monthly"}, time.Time{}, true},
+		{"negative interval", Schedule{Interval: "-1h"}, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.schedule.Next(from)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Next() error = %v, want error %t", err, test.wantErr)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("Next() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
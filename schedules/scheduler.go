@@ -0,0 +1,126 @@
+package schedules
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// missedGrace is how late a run may start before it counts as missed, e.g. while the service was down
+const missedGrace = time.Minute
+
+// maxMissedCount bounds the missed runs counted for a schedule that was down for long
+const maxMissedCount = 1000
+
+// Payment is what a run paid
+type Payment struct {
+	LedgerID    string
+	AmountMsats int64
+	EuroAmount  float64
+	FeesPaid    int64
+}
+
+// PayFunc makes the payment of a schedule
+// It may return the payment along with an error when the payment was made but failed a check afterwards
+type PayFunc func(ctx context.Context, schedule Schedule) (*Payment, error)
+
+// Scheduler runs schedules when they fall due
+type Scheduler struct {
+	Store    *Store
+	Pay      PayFunc
+	Interval time.Duration
+}
+
+// Run checks for due schedules every Interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs every schedule due at now, one after another
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	for _, schedule := range s.Store.Due(now) {
+		if err := s.run(ctx, schedule, now); err != nil {
+			log.Printf("Failed to run schedule %s: %v", schedule.ID, err)
+		}
+	}
+}
+
+// run makes a due schedule's payment, applying its missed run policy when the run is late
+func (s *Scheduler) run(ctx context.Context, schedule Schedule, now time.Time) error {
+	scheduledAt := *schedule.NextRunAt
+
+	// Count the runs that fell due up to now and find the next one after now
+	missed := 0
+	next, err := schedule.Next(scheduledAt)
+	if err != nil {
+		return err
+	}
+	for !next.IsZero() && !next.After(now) {
+		missed++
+		if missed == maxMissedCount {
+			next, err = schedule.Next(now)
+		} else {
+			next, err = schedule.Next(next)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	late := now.Sub(scheduledAt) > missedGrace
+
+	// Move the schedule on first, so a crash during the payment cannot repeat it
+	if _, err := s.Store.Advance(schedule.ID, now, next); err != nil {
+		return err
+	}
+
+	if late && schedule.MissedRuns != MissedRunOnce {
+		log.Printf("Skipping %d missed runs of schedule %s", missed+1, schedule.ID)
+		_, err := s.Store.StartRun(Run{
+			ScheduleID:  schedule.ID,
+			Status:      RunSkipped,
+			ScheduledAt: scheduledAt,
+			Missed:      missed + 1,
+		})
+		return err
+	}
+
+	run := Run{ScheduleID: schedule.ID, ScheduledAt: scheduledAt}
+	if late {
+		run.Missed = missed + 1
+	}
+	run, err = s.Store.StartRun(run)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.Pay(ctx, schedule)
+	if err != nil {
+		log.Printf("Scheduled payment %s from %s to %s failed: %v", schedule.ID, schedule.Sender, schedule.Recipient, err)
+		run.Status = RunFailed
+		run.Error = err.Error()
+	} else {
+		log.Printf("Scheduled payment %s from %s to %s succeeded", schedule.ID, schedule.Sender, schedule.Recipient)
+		run.Status = RunSucceeded
+	}
+	// A payment can fail after it was made, e.g. when it paid more in fees than allowed
+	if payment != nil {
+		run.LedgerID = payment.LedgerID
+		run.AmountMsats = payment.AmountMsats
+		run.EuroAmount = payment.EuroAmount
+		run.FeesPaid = payment.FeesPaid
+	}
+
+	_, err = s.Store.FinishRun(run)
+	return err
+}
@@ -0,0 +1,243 @@
+// Package schedules keeps recurring payments and runs them when they fall due
+package schedules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"nwc_app/store"
+)
+
+// Schedule statuses
+const (
+	StatusActive    = "active"
+	StatusEnded     = "ended"
+	StatusCancelled = "cancelled"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// Missed run policies decide what happens to runs that fell due while the service was down
+const (
+	// MissedSkip records missed runs as skipped and waits for the next one
+	MissedSkip = "skip"
+	// MissedRunOnce makes a single payment for all missed runs
+	MissedRunOnce = "run_once"
+)
+
+// Currencies an amount can be given in
+const (
+	CurrencyEUR  = "EUR"
+	CurrencySAT  = "SAT"
+	CurrencyMSAT = "MSAT"
+)
+
+// Schedule is a recurring payment, due either on a cron expression or at a fixed interval.
+// Times are in UTC.
+type Schedule struct {
+	ID            string     `json:"id"`
+	Sender        string     `json:"sender"`
+	Recipient     string     `json:"recipient"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Cron          string     `json:"cron,omitempty"`
+	Interval      string     `json:"interval,omitempty"`
+	MissedRuns    string     `json:"missed_runs"`
+	MaxFeeMsats   *int64     `json:"max_fee_msats,omitempty"`
+	MaxFeePercent *float64   `json:"max_fee_percent,omitempty"`
+	Status        string     `json:"status"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Next returns the first time after t the schedule falls due, or the zero time if it never
+// does again before it ends
+func (s Schedule) Next(t time.Time) (time.Time, error) {
+	var next time.Time
+	if s.Cron != "" {
+		cron, err := ParseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next = cron.Next(t.UTC())
+	} else {
+		interval, err := time.ParseDuration(s.Interval)
+		if err != nil || interval <= 0 {
+			return time.Time{}, fmt.Errorf("invalid interval %q", s.Interval)
+		}
+		next = t.UTC().Add(interval)
+	}
+
+	if s.EndsAt != nil && next.After(*s.EndsAt) {
+		return time.Time{}, nil
+	}
+	return next, nil
+}
+
+// Run is one execution of a schedule. LedgerID points to the payment it made.
+type Run struct {
+	ID          string    `json:"id"`
+	ScheduleID  string    `json:"schedule_id"`
+	Status      string    `json:"status"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	// Missed counts the runs a late run stands in for
+	Missed      int       `json:"missed,omitempty"`
+	LedgerID    string    `json:"ledger_id,omitempty"`
+	AmountMsats int64     `json:"amount_msats,omitempty"`
+	EuroAmount  float64   `json:"euro_amount,omitempty"`
+	FeesPaid    int64     `json:"fees_paid,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at,omitempty"`
+}
+
+// ErrNotActive is returned when a schedule that has ended or was cancelled is changed
+var ErrNotActive = errors.New("schedule is not active")
+
+// Store is the persistent list of schedules and their runs
+type Store struct {
+	schedules *store.Collection[Schedule]
+	runs      *store.Collection[Run]
+}
+
+// Open loads the schedules stored in dir.
+// Runs still going when the service stopped are marked failed, as their outcome is unknown.
+func Open(dir string) (*Store, error) {
+	schedules, err := store.Open[Schedule](dir, "schedules")
+	if err != nil {
+		return nil, err
+	}
+	runs, err := store.Open[Run](dir, "schedule_runs")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs.List() {
+		if run.Status != RunRunning {
+			continue
+		}
+		if _, err := runs.Update(run.ID, func(run *Run) error {
+			run.Status = RunFailed
+			run.Error = "interrupted by a restart, check the ledger before paying again"
+			run.FinishedAt = time.Now().UTC()
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Store{schedules: schedules, runs: runs}, nil
+}
+
+// Create stores a new active schedule due first at its NextRunAt
+func (s *Store) Create(schedule Schedule) (Schedule, error) {
+	schedule.ID = store.NewID()
+	schedule.Status = StatusActive
+	schedule.CreatedAt = time.Now().UTC()
+	schedule.UpdatedAt = schedule.CreatedAt
+
+	return schedule, s.schedules.Put(schedule.ID, schedule)
+}
+
+// Get returns the schedule with the given ID
+func (s *Store) Get(id string) (Schedule, bool) {
+	return s.schedules.Get(id)
+}
+
+// List returns all schedules, oldest first
+func (s *Store) List() []Schedule {
+	schedules := s.schedules.List()
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules
+}
+
+// Due returns the active schedules whose next run is at or before now
+func (s *Store) Due(now time.Time) []Schedule {
+	var due []Schedule
+	for _, schedule := range s.List() {
+		if schedule.Status == StatusActive && schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			due = append(due, schedule)
+		}
+	}
+	return due
+}
+
+// Cancel stops an active schedule
+func (s *Store) Cancel(id string) (Schedule, error) {
+	return s.schedules.Update(id, func(schedule *Schedule) error {
+		if schedule.Status != StatusActive {
+			return ErrNotActive
+		}
+		schedule.Status = StatusCancelled
+		schedule.NextRunAt = nil
+		schedule.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Advance moves an active schedule on to its next run, or ends it when there is none.
+// It is stored before a payment is made so a crash cannot repeat the payment.
+func (s *Store) Advance(id string, ranAt time.Time, next time.Time) (Schedule, error) {
+	return s.schedules.Update(id, func(schedule *Schedule) error {
+		if schedule.Status != StatusActive {
+			return ErrNotActive
+		}
+		ranAt = ranAt.UTC()
+		schedule.LastRunAt = &ranAt
+		if next.IsZero() {
+			schedule.Status = StatusEnded
+			schedule.NextRunAt = nil
+		} else {
+			next = next.UTC()
+			schedule.NextRunAt = &next
+		}
+		schedule.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// StartRun records a run that is about to be made
+func (s *Store) StartRun(run Run) (Run, error) {
+	run.ID = store.NewID()
+	run.StartedAt = time.Now().UTC()
+	if run.Status == "" {
+		run.Status = RunRunning
+	}
+	if run.Status != RunRunning {
+		run.FinishedAt = run.StartedAt
+	}
+	return run, s.runs.Put(run.ID, run)
+}
+
+// FinishRun records the outcome of a run
+func (s *Store) FinishRun(run Run) (Run, error) {
+	run.FinishedAt = time.Now().UTC()
+	return run, s.runs.Put(run.ID, run)
+}
+
+// Runs returns the runs of a schedule, oldest first
+func (s *Store) Runs(scheduleID string) []Run {
+	var runs []Run
+	for _, run := range s.runs.List() {
+		if run.ScheduleID == scheduleID {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs
+}
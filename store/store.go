@@ -0,0 +1,342 @@
+// Package store provides collections of records persisted as JSON files
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// minCompaction is the number of journaled records below which a collection is never compacted
+const minCompaction = 1000
+
+// Collection is a set of records keyed by ID, all held in memory.
+//
+// It is stored as a JSON snapshot, <name>.json, and a journal, <name>.journal, of the
+// records written since, one JSON line per write. A write only appends the records it
+// changed to the journal; once the journal holds more records than the collection,
+// and at least minCompaction, the snapshot is rewritten and the journal emptied.
+// Writes therefore cost the size of what changed, amortised, while memory and start up
+// time grow with the number of records: collections that grow without bound, such as the
+// ledger, move old records elsewhere.
+type Collection[T any] struct {
+	path    string
+	journal string
+	mu      sync.RWMutex
+	items   map[string]T
+
+	log     *os.File
+	entries int
+}
+
+// journalEntry is the new value of a record, or its deletion; each line of the journal
+// holds the entries of one write
+type journalEntry[T any] struct {
+	ID      string `json:"id"`
+	Item    *T     `json:"item,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Open loads the collection stored as <name>.json and <name>.journal in dir, creating dir if needed
+func Open[T any](dir, name string) (*Collection[T], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	c := &Collection[T]{
+		path:    filepath.Join(dir, name+".json"),
+		journal: filepath.Join(dir, name+".journal"),
+		items:   make(map[string]T),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", c.path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &c.items); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", c.path, err)
+		}
+	}
+
+	if err := c.replay(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// replay applies the journal to the records loaded from the snapshot.
+// A last line without its newline was cut short by a crash, so the write it belonged
+// to never completed; it is dropped so the next write starts on a line of its own.
+func (c *Collection[T]) replay() error {
+	data, err := os.ReadFile(c.journal)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", c.journal, err)
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	if partial := lines[len(lines)-1]; len(partial) > 0 {
+		if err := os.Truncate(c.journal, int64(len(data)-len(partial))); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", c.journal, err)
+		}
+	}
+
+	for i, line := range lines[:len(lines)-1] {
+		var entries []journalEntry[T]
+		if err := json.Unmarshal(line, &entries); err != nil {
+			return fmt.Errorf("failed to parse %s line %d: %w", c.journal, i+1, err)
+		}
+
+		for _, entry := range entries {
+			if entry.Deleted || entry.Item == nil {
+				delete(c.items, entry.ID)
+			} else {
+				c.items[entry.ID] = *entry.Item
+			}
+		}
+		c.entries += len(entries)
+	}
+	return nil
+}
+
+// Get returns the record with the given ID
+func (c *Collection[T]) Get(id string) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, ok := c.items[id]
+	return item, ok
+}
+
+// List returns all records in no particular order
+func (c *Collection[T]) List() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := make([]T, 0, len(c.items))
+	for _, item := range c.items {
+		items = append(items, item)
+	}
+	return items
+}
+
+// Len returns the number of records
+func (c *Collection[T]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// Put inserts or replaces the record with the given ID
+func (c *Collection[T]) Put(id string, item T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[id] = item
+	return c.write(map[string]*T{id: &item})
+}
+
+// Update applies fn to the record with the given ID and stores the result.
+// Nothing is stored when fn returns an error.
+func (c *Collection[T]) Update(id string, fn func(item *T) error) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[id]
+	if !ok {
+		return item, ErrNotFound
+	}
+	if err := fn(&item); err != nil {
+		return item, err
+	}
+
+	c.items[id] = item
+	return item, c.write(map[string]*T{id: &item})
+}
+
+// Delete removes the record with the given ID
+func (c *Collection[T]) Delete(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[id]; !ok {
+		return ErrNotFound
+	}
+	delete(c.items, id)
+	return c.write(map[string]*T{id: nil})
+}
+
+// Tx is the view of a collection inside a transaction.
+// It reads through to the collection and keeps the changes until the transaction ends.
+type Tx[T any] struct {
+	items   map[string]T
+	changes map[string]*T
+}
+
+// Get returns the record with the given ID as changed so far
+func (tx *Tx[T]) Get(id string) (T, bool) {
+	if changed, ok := tx.changes[id]; ok {
+		if changed == nil {
+			var zero T
+			return zero, false
+		}
+		return *changed, true
+	}
+	item, ok := tx.items[id]
+	return item, ok
+}
+
+// Put inserts or replaces the record with the given ID
+func (tx *Tx[T]) Put(id string, item T) {
+	tx.changes[id] = &item
+}
+
+// Delete removes the record with the given ID, if it exists
+func (tx *Tx[T]) Delete(id string) {
+	tx.changes[id] = nil
+}
+
+// Range calls fn for every record as changed so far, in no particular order, until fn returns false
+func (tx *Tx[T]) Range(fn func(id string, item T) bool) {
+	for id, item := range tx.items {
+		if _, changed := tx.changes[id]; changed {
+			continue
+		}
+		if !fn(id, item) {
+			return
+		}
+	}
+	for id, item := range tx.changes {
+		if item != nil && !fn(id, *item) {
+			return
+		}
+	}
+}
+
+// Len returns the number of records as changed so far
+func (tx *Tx[T]) Len() int {
+	n := len(tx.items)
+	for id, item := range tx.changes {
+		_, existed := tx.items[id]
+		switch {
+		case item == nil && existed:
+			n--
+		case item != nil && !existed:
+			n++
+		}
+	}
+	return n
+}
+
+// Transaction runs fn with exclusive access to all records.
+// The changes made through tx are stored together when fn returns without error.
+func (c *Collection[T]) Transaction(fn func(tx *Tx[T]) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx := &Tx[T]{items: c.items, changes: make(map[string]*T)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.changes) == 0 {
+		return nil
+	}
+
+	for id, item := range tx.changes {
+		if item == nil {
+			delete(c.items, id)
+		} else {
+			c.items[id] = *item
+		}
+	}
+	return c.write(tx.changes)
+}
+
+// write appends changed records to the journal as a single line, so a crash keeps
+// all of them or none, and compacts the collection once the journal has grown too long
+func (c *Collection[T]) write(changes map[string]*T) error {
+	entries := make([]journalEntry[T], 0, len(changes))
+	for id, item := range changes {
+		entries = append(entries, journalEntry[T]{ID: id, Item: item, Deleted: item == nil})
+	}
+	line, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", c.journal, err)
+	}
+	line = append(line, '\n')
+
+	if c.log == nil {
+		log, err := os.OpenFile(c.journal, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", c.journal, err)
+		}
+		c.log = log
+	}
+	if _, err := c.log.Write(line); err != nil {
+		return fmt.Errorf("failed to write %s: %w", c.journal, err)
+	}
+	c.entries += len(changes)
+
+	if c.entries >= minCompaction && c.entries > len(c.items) {
+		return c.compact()
+	}
+	return nil
+}
+
+// compact writes the records to a new snapshot and empties the journal.
+// The snapshot is written to a temporary file and renamed over the old one so a
+// crash never leaves a half written file behind; until the journal is emptied,
+// replaying it over the new snapshot gives the same records.
+func (c *Collection[T]) compact() error {
+	data, err := json.MarshalIndent(c.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", c.path, err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", c.path, err)
+	}
+
+	if err := c.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to empty %s: %w", c.journal, err)
+	}
+	c.entries = 0
+	return nil
+}
+
+// Close releases the journal file until the next write opens it again
+func (c *Collection[T]) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.log == nil {
+		return nil
+	}
+	err := c.log.Close()
+	c.log = nil
+	return err
+}
+
+// NewID returns a random 128 bit identifier encoded as hex
+func NewID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func open(t *testing.T, dir string) *Collection[record] {
+	t.Helper()
+
+	c, err := Open[record](dir, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCollectionPersists(t *testing.T) {
+	dir := t.TempDir()
+	c := open(t, dir)
+
+	c.Put("a", record{Name: "a"})
+	c.Put("b", record{Name: "b"})
+	c.Update("a", func(r *record) error { r.Count = 2; return nil })
+	c.Delete("b")
+	c.Close()
+
+	reopened := open(t, dir)
+	if got, ok := reopened.Get("a"); !ok || got.Count != 2 {
+		t.Fatalf("Get(a) = %+v, %t, want count 2", got, ok)
+	}
+	if _, ok := reopened.Get("b"); ok {
+		t.Fatal("deleted record came back")
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", reopened.Len())
+	}
+}
+
+func TestCollectionTransaction(t *testing.T) {
+	dir := t.TempDir()
+	c := open(t, dir)
+	c.Put("a", record{Name: "a"})
+	c.Put("b", record{Name: "b"})
+
+	failed := errors.New("rolled back")
+	err := c.Transaction(func(tx *Tx[record]) error {
+		tx.Delete("a")
+		tx.Put("c", record{Name: "c"})
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Transaction() error = %v, want %v", err, failed)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("failed transaction deleted a record")
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("failed transaction added a record")
+	}
+
+	err = c.Transaction(func(tx *Tx[record]) error {
+		tx.Delete("a")
+		tx.Put("c", record{Name: "c"})
+		tx.Put("b", record{Name: "b", Count: 1})
+
+		if _, ok := tx.Get("a"); ok {
+			t.Error("deleted record visible in the transaction")
+		}
+		if got, _ := tx.Get("b"); got.Count != 1 {
+			t.Errorf("Get(b) = %+v, want the changed record", got)
+		}
+		if tx.Len() != 2 {
+			t.Errorf("Len() = %d, want 2", tx.Len())
+		}
+		seen := 0
+		tx.Range(func(id string, _ record) bool {
+			if id == "a" {
+				t.Error("Range visited a deleted record")
+			}
+			seen++
+			return true
+		})
+		if seen != 2 {
+			t.Errorf("Range visited %d records, want 2", seen)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	reopened := open(t, dir)
+	if _, ok := reopened.Get("a"); ok {
+		t.Fatal("deleted record came back")
+	}
+	if got, _ := reopened.Get("b"); got.Count != 1 {
+		t.Fatalf("Get(b) = %+v, want count 1", got)
+	}
+}
+
+func TestCollectionCompacts(t *testing.T) {
+	dir := t.TempDir()
+	c := open(t, dir)
+
+	for i := 0; i < minCompaction+10; i++ {
+		c.Put("a", record{Name: "a", Count: i})
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "records.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.entries >= minCompaction || info.Size() > 1000 {
+		t.Fatalf("journal holds %d records, %d bytes, want it compacted", c.entries, info.Size())
+	}
+	c.Close()
+
+	if got, _ := open(t, dir).Get("a"); got.Count != minCompaction+9 {
+		t.Fatalf("Get(a) = %+v, want the last write", got)
+	}
+}
+
+func TestCollectionIgnoresTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	c := open(t, dir)
+	c.Put("a", record{Name: "a"})
+	c.Close()
+
+	journal := filepath.Join(dir, "records.journal")
+	f, err := os.OpenFile(journal, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`[{"id":"b","item":{"na`)
+	f.Close()
+
+	reopened := open(t, dir)
+	if _, ok := reopened.Get("b"); ok {
+		t.Fatal("torn write was applied")
+	}
+	reopened.Put("c", record{Name: "c"})
+	reopened.Close()
+
+	again := open(t, dir)
+	if _, ok := again.Get("c"); !ok {
+		t.Fatal("write after a torn write was lost")
+	}
+	if _, ok := again.Get("a"); !ok {
+		t.Fatal("write before a torn write was lost")
+	}
+}
+
+func TestOpenReadsSnapshotOnly(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "records.json"), []byte(`{"a":{"name":"a","count":1}}`), 0o600)
+
+	if got, ok := open(t, dir).Get("a"); !ok || got.Count != 1 {
+		t.Fatalf("Get(a) = %+v, %t, want the record of the snapshot", got, ok)
+	}
+}
@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds the per wallet settings from the wallet config file
+type Config struct {
+	Spending         SpendingPolicy         `json:"spending"`
+	Fees             FeePolicy              `json:"fees"`
+	LightningAddress LightningAddressConfig `json:"lightning_address"`
+	Rebalance        RebalanceRange         `json:"rebalance"`
+	Alerts           AlertConfig            `json:"alerts"`
+}
+
+// SpendingPolicy limits how much a wallet may send.
+// Zero values mean no limit.
+type SpendingPolicy struct {
+	MaxPaymentMsats int64   `json:"max_payment_msats,omitempty"`
+	MaxPaymentEur   float64 `json:"max_payment_eur,omitempty"`
+	Daily           Budget  `json:"daily"`
+	Weekly          Budget  `json:"weekly"`
+	Monthly         Budget  `json:"monthly"`
+}
+
+// UsesEur reports whether any limit of the policy is set in Euro
+func (p SpendingPolicy) UsesEur() bool {
+	return p.MaxPaymentEur > 0 || p.Daily.MaxEur > 0 || p.Weekly.MaxEur > 0 || p.Monthly.MaxEur > 0
+}
+
+// Budget caps the payments a wallet makes within one calendar window
+type Budget struct {
+	MaxMsats    int64   `json:"max_msats,omitempty"`
+	MaxEur      float64 `json:"max_eur,omitempty"`
+	MaxPayments int     `json:"max_payments,omitempty"`
+}
+
+// IsZero reports whether the budget sets no limit at all
+func (b Budget) IsZero() bool {
+	return b.MaxMsats == 0 && b.MaxEur == 0 && b.MaxPayments == 0
+}
+
+// LightningAddressConfig controls how a wallet receives payments at
+// name@domain through this server's LNURL-pay endpoints
+type LightningAddressConfig struct {
+	// Name defaults to the wallet ID in lower case without its WALLET_ prefix
+	Name             string `json:"name,omitempty"`
+	Disabled         bool   `json:"disabled,omitempty"`
+	MinSendableMsats int64  `json:"min_sendable_msats,omitempty"`
+	MaxSendableMsats int64  `json:"max_sendable_msats,omitempty"`
+	CommentAllowed   int    `json:"comment_allowed,omitempty"`
+}
+
+// AddressName returns the name part of the wallet's Lightning Address
+func (c LightningAddressConfig) AddressName(walletID string) string {
+	if c.Name != "" {
+		return strings.ToLower(c.Name)
+	}
+	return strings.TrimPrefix(strings.ToLower(walletID), "wallet_")
+}
+
+// Sendable returns the smallest and largest amount in msats the wallet accepts,
+// 1 sat to 1,000,000 sats unless configured
+func (c LightningAddressConfig) Sendable() (int64, int64) {
+	minSendable, maxSendable := c.MinSendableMsats, c.MaxSendableMsats
+	if minSendable <= 0 {
+		minSendable = 1_000
+	}
+	if maxSendable <= 0 {
+		maxSendable = 1_000_000_000
+	}
+	return minSendable, maxSendable
+}
+
+// RebalanceRange is the balance the rebalancer keeps a wallet within.
+// A wallet above MaxMsats gives its surplus to wallets below their MinMsats; zero
+// values are not enforced. Transfers aim for TargetMsats, the middle of the range by default.
+type RebalanceRange struct {
+	MinMsats    int64 `json:"min_msats,omitempty"`
+	MaxMsats    int64 `json:"max_msats,omitempty"`
+	TargetMsats int64 `json:"target_msats,omitempty"`
+}
+
+// IsZero reports whether the wallet takes no part in rebalancing
+func (r RebalanceRange) IsZero() bool {
+	return r.MinMsats == 0 && r.MaxMsats == 0
+}
+
+// Target returns the balance transfers to and from the wallet aim for
+func (r RebalanceRange) Target() int64 {
+	switch {
+	case r.TargetMsats > 0:
+		return r.TargetMsats
+	case r.MinMsats > 0 && r.MaxMsats > 0:
+		return r.MinMsats + (r.MaxMsats-r.MinMsats)/2
+	case r.MaxMsats > 0:
+		return r.MaxMsats
+	default:
+		return r.MinMsats
+	}
+}
+
+// Validate checks that the range and its target make sense
+func (r RebalanceRange) Validate() error {
+	if r.MinMsats < 0 || r.MaxMsats < 0 || r.TargetMsats < 0 {
+		return errors.New("rebalance amounts must not be negative")
+	}
+	if r.MaxMsats > 0 && r.MinMsats > r.MaxMsats {
+		return errors.New("rebalance min_msats must not be above max_msats")
+	}
+	if r.TargetMsats > 0 && (r.TargetMsats < r.MinMsats || (r.MaxMsats > 0 && r.TargetMsats > r.MaxMsats)) {
+		return errors.New("rebalance target_msats must be within min_msats and max_msats")
+	}
+	return nil
+}
+
+// AlertConfig sets when the wallet monitor reports a wallet.
+// Zero values are not enforced.
+type AlertConfig struct {
+	LowBalanceMsats int64 `json:"low_balance_msats,omitempty"`
+}
+
+// FeePolicy caps the routing fee a payment may cost.
+// Nil values are not enforced.
+type FeePolicy struct {
+	MaxFeeMsats   *int64   `json:"max_fee_msats,omitempty"`
+	MaxFeePercent *float64 `json:"max_fee_percent,omitempty"`
+}
+
+// Merge returns the policy with the limits set in override replacing its own
+func (p FeePolicy) Merge(override FeePolicy) FeePolicy {
+	if override.MaxFeeMsats != nil {
+		p.MaxFeeMsats = override.MaxFeeMsats
+	}
+	if override.MaxFeePercent != nil {
+		p.MaxFeePercent = override.MaxFeePercent
+	}
+	return p
+}
+
+// Ceiling returns the highest fee allowed for a payment of amountMsats
+// and false when the policy sets no limit
+func (p FeePolicy) Ceiling(amountMsats int64) (int64, bool) {
+	ceiling, limited := int64(0), false
+	if p.MaxFeeMsats != nil {
+		ceiling, limited = *p.MaxFeeMsats, true
+	}
+	if p.MaxFeePercent != nil {
+		percent := int64(float64(amountMsats) * *p.MaxFeePercent / 100)
+		if !limited || percent < ceiling {
+			ceiling, limited = percent, true
+		}
+	}
+	return max(ceiling, 0), limited
+}
+
+// LoadFeePolicy loads the global fee limits from NWC_MAX_FEE_MSATS and NWC_MAX_FEE_PERCENT
+func LoadFeePolicy() (FeePolicy, error) {
+	var policy FeePolicy
+
+	if value := LoadSetting("NWC_MAX_FEE_MSATS"); value != "" {
+		msats, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || msats < 0 {
+			return policy, fmt.Errorf("invalid NWC_MAX_FEE_MSATS %q", value)
+		}
+		policy.MaxFeeMsats = &msats
+	}
+	if value := LoadSetting("NWC_MAX_FEE_PERCENT"); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent < 0 {
+			return policy, fmt.Errorf("invalid NWC_MAX_FEE_PERCENT %q", value)
+		}
+		policy.MaxFeePercent = &percent
+	}
+
+	return policy, nil
+}
+
+// LoadWalletConfigs loads per wallet settings from the JSON file named by
+// NWC_WALLET_CONFIG (wallets.json by default), keyed by wallet ID.
+// A missing file means no wallet has extra settings.
+func LoadWalletConfigs() (map[string]Config, error) {
+	path := LoadSetting("NWC_WALLET_CONFIG")
+	if path == "" {
+		path = "wallets.json"
+	}
+
+	configs := make(map[string]Config)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No wallet config file at %s, wallets have no spending policies.", path)
+		return configs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse wallet config %s: %w", path, err)
+	}
+
+	return configs, nil
+}
@@ -3,12 +3,18 @@ package wallet
 
 import (
 	"log"
+	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// uriScheme is the prefix every Nostr Wallet Connect URI starts with
+const uriScheme = "nostr+walletconnect:"
+
 // LoadWalletURIs loads wallet URIs from the .env file
-// Returns a map with keys being wallet identifiers and values being wallet URIs
+// Returns a map with keys being wallet identifiers and values being wallet URIs.
+// Entries that are not NWC URIs (API keys and other settings) are skipped.
 func LoadWalletURIs() (map[string]string, error) {
 	// Create a map to store wallet URIs
 	walletURIs := make(map[string]string)
@@ -18,27 +24,34 @@ func LoadWalletURIs() (map[string]string, error) {
 	if err != nil {
 		log.Printf("Warning: Error loading .env file: %v. Using empty wallet map.", err)
 	}
-	
-	// Copy all entries from the env map to the walletURIs map
+
+	// Copy wallet entries from the env map to the walletURIs map
 	for k, v := range env {
+		if !strings.HasPrefix(v, uriScheme) {
+			continue
+		}
 		walletURIs[k] = v
 	}
-	
+
 	return walletURIs, nil
 }
 
+// LoadAPIKey returns the API key configured through NWC_API_KEY
 func LoadAPIKey() (string, error) {
-	apiKey := ""
-	// Read the .env file
+	return LoadSetting("NWC_API_KEY"), nil
+}
+
+// LoadSetting returns a configuration value by name.
+// Process environment variables take precedence over entries in the .env file.
+func LoadSetting(name string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
 	env, err := godotenv.Read(".env")
 	if err != nil {
-		log.Printf("Warning: Error loading .env file: %v. Using empty wallet map.", err)
+		return ""
 	}
-	
-	// Copy all entries from the env map to the walletURIs map
-	for _, v := range env {
-		apiKey = v
-	}
-	
-	return apiKey, nil
+
+	return env[name]
 }
@@ -0,0 +1,175 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/retry"
+	"nwc_app/store"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-NWC-Event"
+	DeliveryHeader  = "X-NWC-Delivery"
+	TimestampHeader = "X-NWC-Timestamp"
+	SignatureHeader = "X-NWC-Signature"
+)
+
+// Sign returns the signature of a delivery: the hex encoded HMAC-SHA256 of
+// TIMESTAMP.BODY keyed with the webhook secret, where TIMESTAMP is in Unix seconds
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher queues published events for the webhooks subscribed to them and
+// posts the queued deliveries, retrying those that fail according to Policy
+type Dispatcher struct {
+	Store  *Store
+	Client *http.Client
+	Policy retry.Policy
+	// Interval between looks for deliveries whose retry is due
+	Interval time.Duration
+
+	once sync.Once
+	wake chan struct{}
+}
+
+// Listen queues every event received on the channel until it is closed
+func (d *Dispatcher) Listen(published <-chan events.Event) {
+	for event := range published {
+		deliveries, err := d.Store.Enqueue(event)
+		if err != nil {
+			log.Printf("Failed to queue %s event %s for webhooks: %v", event.Type, event.ID, err)
+		}
+		if len(deliveries) > 0 {
+			d.Wake()
+		}
+	}
+}
+
+// Wake makes Run deliver the due deliveries without waiting for the next interval
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wakeup() <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) wakeup() chan struct{} {
+	d.once.Do(func() {
+		d.wake = make(chan struct{}, 1)
+	})
+	return d.wake
+}
+
+// Run delivers due deliveries every Interval, or sooner when woken, until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, delivery := range d.Store.Due(time.Now()) {
+			if ctx.Err() != nil {
+				return
+			}
+			d.deliver(ctx, delivery)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wakeup():
+		}
+	}
+}
+
+// deliver posts a delivery to its webhook once and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery) {
+	webhook, ok := d.Store.Get(delivery.WebhookID)
+	if !ok {
+		// The webhook was deleted after the delivery was queued; finish it so it is not due forever
+		attempt := Attempt{At: time.Now().UTC(), Error: "webhook no longer exists"}
+		if _, err := d.Store.Record(delivery.ID, attempt, false, nil); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	attempt := d.post(ctx, webhook, delivery)
+	succeeded := attempt.Error == ""
+	number := len(delivery.Attempts) + 1
+
+	var next *time.Time
+	if !succeeded && d.Policy.Retry(number) {
+		at := attempt.At.Add(d.Policy.Delay(number))
+		next = &at
+	}
+
+	switch {
+	case succeeded:
+		log.Printf("Delivered %s event %s to webhook %s", delivery.EventType, delivery.EventID, webhook.ID)
+	case next != nil:
+		log.Printf("Webhook %s delivery %s attempt %d failed, retrying at %s: %s", webhook.ID, delivery.ID, number, next.Format(time.RFC3339), attempt.Error)
+	default:
+		log.Printf("Webhook %s delivery %s failed after %d attempts: %s", webhook.ID, delivery.ID, number, attempt.Error)
+	}
+
+	if _, err := d.Store.Record(delivery.ID, attempt, succeeded, next); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// post sends the payload of a delivery, signed with the webhook secret.
+// Any 2xx answer accepts it.
+func (d *Dispatcher) post(ctx context.Context, webhook Webhook, delivery Delivery) (attempt Attempt) {
+	start := time.Now()
+	attempt.At = start.UTC()
+	defer func() {
+		attempt.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	timestamp := start.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint answered %s", response.Status)
+	}
+	return attempt
+}
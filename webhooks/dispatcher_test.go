@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/retry"
+)
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	const want = "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := Sign("secret", 1700000000, []byte(`{"id":"1"}`)); got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+}
+
+// endpoint is a webhook receiver answering with the queued statuses, then 200
+type endpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func newEndpoint(t *testing.T, statuses ...int) *endpoint {
+	e := &endpoint{statuses: statuses}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.received = append(e.received, r)
+		e.bodies = append(e.bodies, body)
+		status := http.StatusOK
+		if len(e.statuses) > 0 {
+			status, e.statuses = e.statuses[0], e.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func newDispatcher(t *testing.T, maxAttempts int) *Dispatcher {
+	t.Helper()
+
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Dispatcher{
+		Store:    store,
+		Client:   &http.Client{Timeout: 5 * time.Second},
+		Policy:   retry.Policy{MaxAttempts: maxAttempts},
+		Interval: time.Hour,
+	}
+}
+
+// deliverDue delivers every due delivery once, as a run of the dispatcher does
+func deliverDue(d *Dispatcher) {
+	for _, delivery := range d.Store.Due(time.Now()) {
+		d.deliver(context.Background(), delivery)
+	}
+}
+
+func TestDispatcherDeliversSigned(t *testing.T) {
+	endpoint := newEndpoint(t)
+	d := newDispatcher(t, 3)
+
+	webhook, err := d.Store.Create(Webhook{URL: endpoint.URL, Events: []string{events.TypePaymentSucceeded}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := events.Event{ID: "event", Type: events.TypePaymentSucceeded, Wallet: "WALLET", Data: map[string]any{"amount_msats": 1000}}
+	deliveries, err := d.Store.Enqueue(event)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Enqueue() = %d deliveries, %v, want 1", len(deliveries), err)
+	}
+	deliverDue(d)
+
+	if len(endpoint.received) != 1 {
+		t.Fatalf("endpoint received %d requests, want 1", len(endpoint.received))
+	}
+	request, body := endpoint.received[0], endpoint.bodies[0]
+	timestamp, err := strconv.ParseInt(request.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid %s header: %v", TimestampHeader, err)
+	}
+	if got := request.Header.Get(SignatureHeader); got != Sign(webhook.Secret, timestamp, body) {
+		t.Fatalf("signature %q does not match the body and timestamp", got)
+	}
+	if request.Header.Get(EventHeader) != event.Type || request.Header.Get(DeliveryHeader) != deliveries[0].ID {
+		t.Fatalf("headers %v, want the event type and delivery ID", request.Header)
+	}
+	var sent events.Event
+	if err := json.Unmarshal(body, &sent); err != nil || sent.ID != event.ID {
+		t.Fatalf("body %s, want the event", body)
+	}
+
+	delivery, _ := d.Store.Delivery(deliveries[0].ID)
+	if delivery.Status != StatusDelivered || delivery.DeliveredAt == nil || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("delivery = %+v, want it delivered on the first attempt", delivery)
+	}
+	if due := d.Store.Due(time.Now()); len(due) != 0 {
+		t.Fatalf("%d deliveries still due", len(due))
+	}
+}
+
+func TestDispatcherRetriesThenGivesUp(t *testing.T) {
+	endpoint := newEndpoint(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable)
+	d := newDispatcher(t, 3)
+
+	d.Store.Create(Webhook{URL: endpoint.URL, Events: []string{AllEvents}})
+	deliveries, _ := d.Store.Enqueue(events.Event{ID: "event", Type: events.TypePaymentFailed})
+	id := deliveries[0].ID
+
+	deliverDue(d)
+	delivery, _ := d.Store.Delivery(id)
+	if delivery.Status != StatusPending || delivery.NextAttemptAt == nil || len(delivery.Attempts) != 1 {
+		t.Fatalf("after a failed attempt delivery = %+v, want it pending with a next attempt", delivery)
+	}
+	if delivery.Attempts[0].StatusCode != http.StatusInternalServerError || delivery.Attempts[0].Error == "" {
+		t.Fatalf("attempt = %+v, want the 500 recorded", delivery.Attempts[0])
+	}
+
+	deliverDue(d)
+	deliverDue(d)
+	delivery, _ = d.Store.Delivery(id)
+	if delivery.Status != StatusFailed || delivery.NextAttemptAt != nil || len(delivery.Attempts) != 3 {
+		t.Fatalf("after the last attempt delivery = %+v, want it failed after 3 attempts", delivery)
+	}
+
+	deliverDue(d)
+	if len(endpoint.received) != 3 {
+		t.Fatalf("endpoint received %d requests, want no more after giving up", len(endpoint.received))
+	}
+}
+
+func TestDispatcherRetrySucceeds(t *testing.T) {
+	endpoint := newEndpoint(t, http.StatusInternalServerError)
+	d := newDispatcher(t, 3)
+
+	d.Store.Create(Webhook{URL: endpoint.URL, Events: []string{AllEvents}})
+	deliveries, _ := d.Store.Enqueue(events.Event{ID: "event", Type: events.TypePaymentFailed})
+
+	deliverDue(d)
+	deliverDue(d)
+	delivery, _ := d.Store.Delivery(deliveries[0].ID)
+	if delivery.Status != StatusDelivered || len(delivery.Attempts) != 2 {
+		t.Fatalf("delivery = %+v, want it delivered on the second attempt", delivery)
+	}
+}
+
+func TestDispatcherDeletedWebhook(t *testing.T) {
+	endpoint := newEndpoint(t)
+	d := newDispatcher(t, 3)
+
+	webhook, _ := d.Store.Create(Webhook{URL: endpoint.URL, Events: []string{AllEvents}})
+	deliveries, _ := d.Store.Enqueue(events.Event{ID: "event", Type: events.TypePaymentFailed})
+
+	// The webhook goes away while its delivery is queued, as when Enqueue races Delete
+	if err := d.Store.webhooks.Delete(webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	deliverDue(d)
+
+	delivery, _ := d.Store.Delivery(deliveries[0].ID)
+	if delivery.Status != StatusFailed || len(delivery.Attempts) != 1 {
+		t.Fatalf("delivery = %+v, want it failed", delivery)
+	}
+	if due := d.Store.Due(time.Now()); len(due) != 0 {
+		t.Fatalf("%d deliveries still due", len(due))
+	}
+	if len(endpoint.received) != 0 {
+		t.Fatal("delivery of a deleted webhook was sent")
+	}
+}
+
+func TestDispatcherReopen(t *testing.T) {
+	endpoint := newEndpoint(t)
+	dir := t.TempDir()
+
+	store, _ := Open(dir)
+	store.Create(Webhook{URL: endpoint.URL, Events: []string{AllEvents}})
+	deliveries, _ := store.Enqueue(events.Event{ID: "event", Type: events.TypePaymentFailed})
+	store.webhooks.Close()
+	store.deliveries.Close()
+
+	// Queued deliveries survive a restart
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Dispatcher{Store: reopened, Client: http.DefaultClient, Policy: retry.Policy{MaxAttempts: 3}}
+	deliverDue(d)
+
+	if delivery, _ := reopened.Delivery(deliveries[0].ID); delivery.Status != StatusDelivered {
+		t.Fatalf("delivery = %+v, want it delivered after reopening", delivery)
+	}
+}
@@ -0,0 +1,260 @@
+// Package webhooks delivers events to HTTP endpoints registered by an administrator.
+// Deliveries are queued in the store and retried with backoff until the endpoint accepts them.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/store"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusFailed is a delivery that used up its attempts
+	StatusFailed = "failed"
+)
+
+// AllEvents subscribes a webhook to every event type
+const AllEvents = "*"
+
+// ErrPending is returned when a delivery that is still being retried is replayed
+var ErrPending = errors.New("delivery is still pending")
+
+// Webhook is an endpoint receiving the events it subscribed to.
+// Wallets, when set, limits it to the events of those wallets.
+type Webhook struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Wallets     []string `json:"wallets,omitempty"`
+	Description string   `json:"description,omitempty"`
+	// Secret signs the deliveries; it is only shown when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the webhook subscribed to the event
+func (w Webhook) Matches(event events.Event) bool {
+	if !slices.Contains(w.Events, AllEvents) && !slices.Contains(w.Events, event.Type) {
+		return false
+	}
+	return len(w.Wallets) == 0 || slices.Contains(w.Wallets, event.Wallet)
+}
+
+// Attempt is a single try at delivering an event
+type Attempt struct {
+	At time.Time `json:"at"`
+	// StatusCode is the HTTP status the endpoint answered with, if it answered
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Delivery is an event queued for a webhook together with its attempts.
+// Payload is the event as sent, so a replay sends exactly the same body.
+type Delivery struct {
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhook_id"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Wallet    string          `json:"wallet,omitempty"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	Status    string          `json:"status"`
+	Attempts  []Attempt       `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried next
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	// ReplayOf is the delivery this one replays
+	ReplayOf  string    `json:"replay_of,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store is the persistent list of webhooks and their delivery queue
+type Store struct {
+	webhooks   *store.Collection[Webhook]
+	deliveries *store.Collection[Delivery]
+}
+
+// Open loads the webhooks and deliveries stored in dir.
+// Pending deliveries are picked up again where they left off.
+func Open(dir string) (*Store, error) {
+	webhooks, err := store.Open[Webhook](dir, "webhooks")
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := store.Open[Delivery](dir, "webhook_deliveries")
+	if err != nil {
+		return nil, err
+	}
+	return &Store{webhooks: webhooks, deliveries: deliveries}, nil
+}
+
+// Create stores a new webhook with a freshly generated secret
+func (s *Store) Create(webhook Webhook) (Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return webhook, err
+	}
+
+	webhook.ID = store.NewID()
+	webhook.Secret = hex.EncodeToString(secret)
+	webhook.CreatedAt = time.Now().UTC()
+	return webhook, s.webhooks.Put(webhook.ID, webhook)
+}
+
+// Get returns the webhook with the given ID
+func (s *Store) Get(id string) (Webhook, bool) {
+	return s.webhooks.Get(id)
+}
+
+// List returns all webhooks, newest first
+func (s *Store) List() []Webhook {
+	webhooks := s.webhooks.List()
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.After(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// Delete removes a webhook and its deliveries
+func (s *Store) Delete(id string) error {
+	if err := s.webhooks.Delete(id); err != nil {
+		return err
+	}
+	return s.deliveries.Transaction(func(tx *store.Tx[Delivery]) error {
+		tx.Range(func(deliveryID string, delivery Delivery) bool {
+			if delivery.WebhookID == id {
+				tx.Delete(deliveryID)
+			}
+			return true
+		})
+		return nil
+	})
+}
+
+// Enqueue queues an event for every webhook that subscribed to it
+func (s *Store) Enqueue(event events.Event) ([]Delivery, error) {
+	var payload json.RawMessage
+	var deliveries []Delivery
+	for _, webhook := range s.webhooks.List() {
+		if !webhook.Matches(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				return nil, err
+			}
+		}
+
+		delivery, err := s.queue(Delivery{
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Wallet:    event.Wallet,
+			Payload:   payload,
+		})
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Replay queues a finished delivery again as a new delivery of the same payload
+func (s *Store) Replay(id string) (Delivery, error) {
+	original, ok := s.deliveries.Get(id)
+	if !ok {
+		return Delivery{}, store.ErrNotFound
+	}
+	if original.Status == StatusPending {
+		return Delivery{}, ErrPending
+	}
+
+	return s.queue(Delivery{
+		WebhookID: original.WebhookID,
+		EventID:   original.EventID,
+		EventType: original.EventType,
+		Wallet:    original.Wallet,
+		Payload:   original.Payload,
+		ReplayOf:  original.ID,
+	})
+}
+
+// queue stores a new delivery due right away
+func (s *Store) queue(delivery Delivery) (Delivery, error) {
+	now := time.Now().UTC()
+	delivery.ID = store.NewID()
+	delivery.Status = StatusPending
+	delivery.Attempts = []Attempt{}
+	delivery.NextAttemptAt = &now
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	return delivery, s.deliveries.Put(delivery.ID, delivery)
+}
+
+// Delivery returns the delivery with the given ID
+func (s *Store) Delivery(id string) (Delivery, bool) {
+	return s.deliveries.Get(id)
+}
+
+// Deliveries returns the deliveries of a webhook, newest first
+func (s *Store) Deliveries(webhookID string) []Delivery {
+	var deliveries []Delivery
+	for _, delivery := range s.deliveries.List() {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	return deliveries
+}
+
+// Due returns the pending deliveries whose next attempt is due at now, oldest first
+func (s *Store) Due(now time.Time) []Delivery {
+	var due []Delivery
+	for _, delivery := range s.deliveries.List() {
+		if delivery.Status == StatusPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	return due
+}
+
+// Record adds an attempt to a delivery. A nil next finishes the delivery:
+// it is delivered when the attempt succeeded and failed otherwise.
+func (s *Store) Record(id string, attempt Attempt, succeeded bool, next *time.Time) (Delivery, error) {
+	return s.deliveries.Update(id, func(delivery *Delivery) error {
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.NextAttemptAt = nil
+		switch {
+		case succeeded:
+			at := attempt.At
+			delivery.Status = StatusDelivered
+			delivery.DeliveredAt = &at
+		case next != nil:
+			at := next.UTC()
+			delivery.NextAttemptAt = &at
+		default:
+			delivery.Status = StatusFailed
+		}
+		delivery.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/store"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook Webhook
+		event   events.Event
+		want    bool
+	}{
+		{"event type", Webhook{Events: []string{events.TypePaymentFailed}}, events.Event{Type: events.TypePaymentFailed}, true},
+		{"other event type", Webhook{Events: []string{events.TypePaymentFailed}}, events.Event{Type: events.TypePaymentSucceeded}, false},
+		{"all events", Webhook{Events: []string{AllEvents}}, events.Event{Type: events.TypePaymentSucceeded}, true},
+		{"wallet", Webhook{Events: []string{AllEvents}, Wallets: []string{"A"}}, events.Event{Type: events.TypePaymentFailed, Wallet: "A"}, true},
+		{"other wallet", Webhook{Events: []string{AllEvents}, Wallets: []string{"A"}}, events.Event{Type: events.TypePaymentFailed, Wallet: "B"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.webhook.Matches(test.event); got != test.want {
+				t.Fatalf("Matches() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(Webhook{URL: "http://localhost", Events: []string{AllEvents}})
+	queue := func() string {
+		deliveries, err := s.Enqueue(events.Event{ID: store.NewID(), Type: events.TypePaymentFailed})
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("Enqueue() = %d deliveries, %v, want 1", len(deliveries), err)
+		}
+		return deliveries[0].ID
+	}
+	now := time.Now().UTC()
+	next := now.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		succeeded  bool
+		next       *time.Time
+		wantStatus string
+		wantNext   *time.Time
+	}{
+		{"succeeded", true, nil, StatusDelivered, nil},
+		{"retry", false, &next, StatusPending, &next},
+		{"give up", false, nil, StatusFailed, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delivery, err := s.Record(queue(), Attempt{At: now}, test.succeeded, test.next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delivery.Status != test.wantStatus || len(delivery.Attempts) != 1 {
+				t.Fatalf("delivery = %+v, want %s after one attempt", delivery, test.wantStatus)
+			}
+			if (delivery.NextAttemptAt == nil) != (test.wantNext == nil) || (test.wantNext != nil && !delivery.NextAttemptAt.Equal(*test.wantNext)) {
+				t.Fatalf("next attempt at %v, want %v", delivery.NextAttemptAt, test.wantNext)
+			}
+			if (delivery.DeliveredAt != nil) != test.succeeded {
+				t.Fatalf("delivered at %v, want it set only when delivered", delivery.DeliveredAt)
+			}
+		})
+	}
+
+	if _, err := s.Record("missing", Attempt{At: now}, true, nil); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Record() of a missing delivery error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(Webhook{URL: "http://localhost", Events: []string{AllEvents}})
+	deliveries, _ := s.Enqueue(events.Event{ID: "event", Type: events.TypePaymentFailed, Wallet: "A"})
+	original := deliveries[0]
+
+	if _, err := s.Replay(original.ID); !errors.Is(err, ErrPending) {
+		t.Fatalf("Replay() of a pending delivery error = %v, want ErrPending", err)
+	}
+	if _, err := s.Replay("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Replay() of a missing delivery error = %v, want ErrNotFound", err)
+	}
+
+	s.Record(original.ID, Attempt{At: time.Now()}, false, nil)
+	replay, err := s.Replay(original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID == original.ID || replay.ReplayOf != original.ID || replay.Status != StatusPending || len(replay.Attempts) != 0 {
+		t.Fatalf("replay = %+v, want a new pending delivery of %s", replay, original.ID)
+	}
+	if string(replay.Payload) != string(original.Payload) || replay.WebhookID != original.WebhookID || replay.Wallet != "A" {
+		t.Fatalf("replay = %+v, want the payload and webhook of the original", replay)
+	}
+	if due := s.Due(time.Now()); len(due) != 1 || due[0].ID != replay.ID {
+		t.Fatalf("Due() = %+v, want only the replay", due)
+	}
+}
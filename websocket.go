@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"nwc_app/events"
+	"nwc_app/middleware"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/gin-gonic/gin"
+)
+
+// WebSocket connection limits
+const (
+	// wsMaxMessageSize caps a single message, the same as a signed request body
+	wsMaxMessageSize = 1 << 20
+	// wsMaxInFlight is how many requests a connection may have running at once
+	wsMaxInFlight = 8
+	// wsPingInterval is how often an idle connection is checked, which also keeps NAT mappings open
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+// wsUnproxied are routes that hold their connection open and cannot be called over a WebSocket
+var wsUnproxied = []string{"/ws", "/events"}
+
+// wsHeaders are the headers a request message may set; credentials and forwarding
+// headers always come from the connection
+var wsHeaders = []string{"Accept", "Content-Type", "Idempotency-Key"}
+
+// WebSocket message types
+const (
+	WSRequest      = "request"
+	WSResponse     = "response"
+	WSSubscribe    = "subscribe"
+	WSSubscribed   = "subscribed"
+	WSUnsubscribe  = "unsubscribe"
+	WSUnsubscribed = "unsubscribed"
+	WSEvent        = "event"
+	WSPing         = "ping"
+	WSPong         = "pong"
+	WSError        = "error"
+)
+
+// WSMessage is a message from a client over the WebSocket connection.
+// A request calls a REST endpoint; a subscribe asks for events like GET /events.
+type WSMessage struct {
+	// ID is echoed in the reply so the client can match it to its message
+	ID   string `json:"id,omitempty" example:"1"`
+	Type string `json:"type" example:"request"`
+	// Method, Path, Headers and Body describe the REST request
+	Method  string            `json:"method,omitempty" example:"POST"`
+	Path    string            `json:"path,omitempty" example:"/nwc_payment"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty" swaggertype:"object"`
+	// Events, Wallets and LastEventID select and resume the events of a subscription
+	Events      []string `json:"events,omitempty" example:"payment.succeeded"`
+	Wallets     []string `json:"wallets,omitempty" example:"WALLET_VRATA_KRKE"`
+	LastEventID string   `json:"last_event_id,omitempty"`
+}
+
+// WSReply is a message from the server over the WebSocket connection
+type WSReply struct {
+	ID   string `json:"id,omitempty" example:"1"`
+	Type string `json:"type" example:"response"`
+	// Status and Body are the HTTP status and body of a response
+	Status int            `json:"status,omitempty" example:"200"`
+	Body   any            `json:"body,omitempty"`
+	Event  *events.Event  `json:"event,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
+}
+
+// wsSession is an open WebSocket connection with the principal that opened it
+type wsSession struct {
+	conn      *websocket.Conn
+	ctx       context.Context
+	upgrade   *http.Request
+	principal *middleware.Principal
+	inFlight  chan struct{}
+
+	mu          sync.Mutex
+	unsubscribe func()
+}
+
+// @Summary      WebSocket API
+// @Description  Upgrades to a WebSocket connection carrying JSON messages, authenticated once when it opens.
+// @Description  A message of type request calls any REST endpoint with the credentials of the connection, as {"id", "type": "request", "method", "path", "body"}, and is answered by a response with the same id, the HTTP status and body. Requests run concurrently, up to 8 at a time.
+// @Description  A message of type subscribe, with optional events, wallets and last_event_id, receives events like GET /events as messages of type event until an unsubscribe. A ping is answered with a pong.
+// @Tags         events
+// @Param        api_key  query  string  false  "API Key for authentication (not needed for HMAC or NIP-98 signed requests)"
+// @Success      101  {string}  string  "Switching Protocols"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      426  {string}  string  "Not a WebSocket request"
+// @Failure      429  {object}  ErrorResponse
+// @Router       /ws [get]
+func webSocketHandler(c *gin.Context) {
+	conn, err := websocket.Accept(c.Writer, c.Request, &websocket.AcceptOptions{
+		// Browsers never attach the credentials on their own, so any origin may connect
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	session := &wsSession{
+		conn:      conn,
+		ctx:       ctx,
+		upgrade:   c.Request,
+		principal: middleware.GetPrincipal(c),
+		inFlight:  make(chan struct{}, wsMaxInFlight),
+	}
+	defer session.stopSubscription()
+	go session.keepAlive()
+
+	for {
+		messageType, data, err := conn.Read(ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway && !errors.Is(err, context.Canceled) {
+				log.Printf("WebSocket connection of %s closed: %v", session.principal.ID, err)
+			}
+			return
+		}
+
+		var message WSMessage
+		if messageType != websocket.MessageText || json.Unmarshal(data, &message) != nil {
+			session.send(wsError("", invalidRequest("messages must be JSON text")))
+			continue
+		}
+		session.handle(message)
+	}
+}
+
+// handle answers a message; requests are made in the background so a slow
+// payment does not hold up the rest of the connection
+func (s *wsSession) handle(message WSMessage) {
+	switch message.Type {
+	case WSRequest:
+		select {
+		case s.inFlight <- struct{}{}:
+		default:
+			s.send(wsError(message.ID, &APIError{
+				Status:    http.StatusTooManyRequests,
+				Code:      "rate_limited",
+				Message:   "too many requests in flight on this connection",
+				Retriable: true,
+			}))
+			return
+		}
+		go func() {
+			defer func() { <-s.inFlight }()
+			s.send(s.proxy(message))
+		}()
+	case WSSubscribe:
+		s.subscribe(message)
+	case WSUnsubscribe:
+		s.stopSubscription()
+		s.send(WSReply{ID: message.ID, Type: WSUnsubscribed})
+	case WSPing:
+		s.send(WSReply{ID: message.ID, Type: WSPong})
+	default:
+		s.send(wsError(message.ID, invalidRequest("unknown message type %q", message.Type)))
+	}
+}
+
+// proxy makes a request message through the router, so it passes the same
+// permission checks, rate limits and handlers as the REST API
+func (s *wsSession) proxy(message WSMessage) WSReply {
+	target, err := url.Parse(message.Path)
+	if err != nil || target.IsAbs() || target.Host != "" || !strings.HasPrefix(target.Path, "/") {
+		return wsError(message.ID, invalidRequest("path must be an absolute path such as /nwc_payment"))
+	}
+	if slices.Contains(wsUnproxied, target.Path) {
+		return wsError(message.ID, invalidRequest("%s cannot be called over a WebSocket", target.Path))
+	}
+
+	for name := range message.Headers {
+		if !slices.Contains(wsHeaders, http.CanonicalHeaderKey(name)) {
+			return wsError(message.ID, invalidRequest("header %s cannot be set over a WebSocket", name))
+		}
+	}
+
+	method := strings.ToUpper(message.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader = http.NoBody
+	if len(message.Body) > 0 {
+		body = bytes.NewReader(message.Body)
+	}
+
+	// The request outlives a dropped connection so a payment is never abandoned halfway;
+	// its outcome can be looked up or resumed from the event log after reconnecting
+	ctx := middleware.WithPrincipal(context.WithoutCancel(s.ctx), s.principal)
+	request, err := http.NewRequestWithContext(ctx, method, target.RequestURI(), body)
+	if err != nil {
+		return wsError(message.ID, invalidRequest("invalid request: %v", err))
+	}
+	request.RemoteAddr = s.upgrade.RemoteAddr
+	for _, name := range []string{"X-Forwarded-For", "X-Real-IP", "User-Agent"} {
+		if value := s.upgrade.Header.Get(name); value != "" {
+			request.Header.Set(name, value)
+		}
+	}
+	for name, value := range message.Headers {
+		request.Header.Set(name, value)
+	}
+	if len(message.Body) > 0 && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	recorder := httptest.NewRecorder()
+	apiRouter.ServeHTTP(recorder, request)
+
+	reply := WSReply{ID: message.ID, Type: WSResponse, Status: recorder.Code}
+	switch response := recorder.Body.Bytes(); {
+	case len(response) == 0:
+	case json.Valid(response):
+		reply.Body = json.RawMessage(response)
+	default:
+		reply.Body = string(response)
+	}
+	return reply
+}
+
+// subscribe replaces the subscription of the connection with one for the events
+// the message selects, first sending those missed since its last_event_id
+func (s *wsSession) subscribe(message WSMessage) {
+	filter, err := newEventFilter(s.principal, message.Events, message.Wallets)
+	if err != nil {
+		s.send(wsError(message.ID, err))
+		return
+	}
+
+	s.stopSubscription()
+	published, unsubscribe := eventBus.Subscribe(100)
+	s.mu.Lock()
+	s.unsubscribe = unsubscribe
+	s.mu.Unlock()
+
+	s.send(WSReply{ID: message.ID, Type: WSSubscribed})
+
+	go func() {
+		var cursor eventCursor
+		send := func(event events.Event) {
+			if cursor.advance(event) && filter.matches(event) {
+				s.send(WSReply{Type: WSEvent, Event: &event})
+			}
+		}
+
+		for _, event := range missedEvents(message.LastEventID) {
+			send(event)
+		}
+		for event := range published {
+			send(event)
+		}
+	}()
+}
+
+// stopSubscription ends the subscription of the connection, if any
+func (s *wsSession) stopSubscription() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+}
+
+// keepAlive pings the client until the connection closes, closing it when a ping goes unanswered
+func (s *wsSession) keepAlive() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, wsWriteTimeout)
+		err := s.conn.Ping(ctx)
+		cancel()
+		if err != nil {
+			s.conn.Close(websocket.StatusGoingAway, "ping timed out")
+			return
+		}
+	}
+}
+
+// send writes a reply; replies to a connection that has gone are dropped
+func (s *wsSession) send(reply WSReply) {
+	ctx, cancel := context.WithTimeout(s.ctx, wsWriteTimeout)
+	defer cancel()
+
+	if err := wsjson.Write(ctx, s.conn, reply); err != nil && s.ctx.Err() == nil {
+		log.Printf("Failed to write to WebSocket of %s: %v", s.principal.ID, err)
+	}
+}
+
+// wsError is the reply reporting an error
+func wsError(id string, err error) WSReply {
+	apiErr := toAPIError(err)
+	body := errorResponse(apiErr)
+	return WSReply{ID: id, Type: WSError, Status: apiErr.Status, Error: &body}
+}